@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/chainreactors/neutron/common"
+	"github.com/chainreactors/neutron/common/dsl"
+	"golang.org/x/term"
+)
+
+const usage = `Usage: dsl [-r <response_file>] [-var key=value]... [expression]...
+
+Evaluates the expressions and exits, or starts a REPL without expressions.
+Expressions may use {{placeholders}} like matchers, e.g. contains(body, '{{token}}').
+
+REPL commands:
+  name = expression   evaluate and assign to a variable
+  :help [function]    signature of a function, or this help
+  :funcs [prefix]     list the helper functions
+  :vars               list the variables
+  :load <file>        load a saved http response (status line, headers, body) as variables
+  :complete <expr>    list the functions and variables completing the last name of the expression,
+                      tab completes the name before the cursor in a terminal
+  :quit               exit
+`
+
+var reAssignment = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\s*=([^=].*)$`)
+
+type variablesFlag map[string]interface{}
+
+func (v variablesFlag) String() string {
+	return fmt.Sprint(map[string]interface{}(v))
+}
+
+func (v variablesFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid variable %q, expected key=value", value)
+	}
+	v[parts[0]] = parts[1]
+	return nil
+}
+
+func main() {
+	vars := variablesFlag{}
+	responseFile := flag.String("r", "", "Saved http response loaded as variables (body, header, status_code...)")
+	flag.Var(vars, "var", "Variable as key=value, can be repeated")
+	flag.Usage = func() {
+		fmt.Print(usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *responseFile != "" {
+		if err := loadResponse(*responseFile, vars); err != nil {
+			fmt.Printf("Error loading %s: %s\n", *responseFile, err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if flag.NArg() > 0 {
+		for _, expression := range flag.Args() {
+			result, err := evaluate(expression, vars)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err.Error())
+				os.Exit(1)
+			}
+			fmt.Println(format(result))
+		}
+		return
+	}
+	repl(vars)
+}
+
+// repl reads the expressions with a line editor in a terminal, tab completes the function and
+// variable names. Piped input is read line by line.
+func repl(vars map[string]interface{}) {
+	fmt.Println("neutron dsl, :help for the commands")
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		if state, err := term.MakeRaw(fd); err == nil {
+			defer term.Restore(fd, state)
+			terminal := term.NewTerminal(struct {
+				io.Reader
+				io.Writer
+			}{os.Stdin, os.Stdout}, "dsl> ")
+			terminal.AutoCompleteCallback = completer(vars, func(candidates []string) {
+				fmt.Fprintln(terminal, strings.Join(candidates, " "))
+			})
+			run(terminal.ReadLine, terminal, vars)
+			return
+		}
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	run(func() (string, error) {
+		fmt.Print("dsl> ")
+		if !scanner.Scan() {
+			return "", io.EOF
+		}
+		return scanner.Text(), nil
+	}, os.Stdout, vars)
+}
+
+// run evaluates the lines until the input ends or :quit, the results are written to out
+func run(readLine func() (string, error), out io.Writer, vars map[string]interface{}) {
+	for {
+		line, err := readLine()
+		if err != nil {
+			fmt.Fprintln(out)
+			return
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			if !command(out, line, vars) {
+				return
+			}
+			continue
+		}
+
+		if matches := reAssignment.FindStringSubmatch(line); matches != nil {
+			result, err := evaluate(matches[2], vars)
+			if err != nil {
+				fmt.Fprintf(out, "Error: %s\n", err.Error())
+				continue
+			}
+			vars[matches[1]] = result
+			fmt.Fprintf(out, "%s = %s\n", matches[1], format(result))
+			continue
+		}
+		result, err := evaluate(line, vars)
+		if err != nil {
+			fmt.Fprintf(out, "Error: %s\n", err.Error())
+			continue
+		}
+		fmt.Fprintln(out, format(result))
+	}
+}
+
+// command runs a REPL command, it returns false to exit
+func command(out io.Writer, line string, vars map[string]interface{}) bool {
+	fields := strings.Fields(line)
+	var arg string
+	if len(fields) > 1 {
+		arg = strings.TrimSpace(strings.TrimPrefix(line, fields[0]))
+	}
+	switch fields[0] {
+	case ":quit", ":q", ":exit":
+		return false
+	case ":help", ":h":
+		if arg == "" {
+			fmt.Fprint(out, usage)
+			return true
+		}
+		signatures, ok := dsl.GetFunctionSignatures(arg)
+		if !ok {
+			fmt.Fprintf(out, "Unknown function %s\n", arg)
+			return true
+		}
+		for _, signature := range signatures {
+			fmt.Fprintln(out, signature)
+		}
+	case ":funcs":
+		for _, name := range common.HelperFunctionNames() {
+			if strings.HasPrefix(name, arg) {
+				fmt.Fprintln(out, name)
+			}
+		}
+	case ":vars":
+		names := make([]string, 0, len(vars))
+		for name := range vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(out, "%s = %s\n", name, truncate(format(vars[name]), 80))
+		}
+	case ":load":
+		if err := loadResponse(arg, vars); err != nil {
+			fmt.Fprintf(out, "Error loading %s: %s\n", arg, err.Error())
+			return true
+		}
+		fmt.Fprintf(out, "Loaded %s, status_code = %v\n", arg, vars["status_code"])
+	case ":complete":
+		fmt.Fprintln(out, strings.Join(complete(lastIdentifier(arg), vars), " "))
+	default:
+		fmt.Fprintf(out, "Unknown command %s, :help for the commands\n", fields[0])
+	}
+	return true
+}
+
+// evaluate resolves the {{placeholders}} of the expression and evaluates it with the variables,
+// unknown functions and wrong arguments are reported before the evaluation
+func evaluate(expression string, vars map[string]interface{}) (interface{}, error) {
+	expression = common.ResolvePlaceholders(expression)
+	if _, isDSL, err := common.InspectExpression(expression); err != nil {
+		return nil, err
+	} else if !isDSL {
+		return nil, fmt.Errorf("invalid dsl expression: %s", expression)
+	}
+	return common.Eval(expression, vars)
+}
+
+func format(result interface{}) string {
+	switch v := result.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	default:
+		return common.ToString(v)
+	}
+}
+
+func truncate(s string, length int) string {
+	s = strings.NewReplacer("\r", "\\r", "\n", "\\n").Replace(s)
+	if len(s) > length {
+		return s[:length] + "..."
+	}
+	return s
+}
+
+// complete returns the function and variable names starting with the prefix
+func complete(prefix string, vars map[string]interface{}) []string {
+	var candidates []string
+	for _, name := range common.HelperFunctionNames() {
+		if strings.HasPrefix(name, prefix) {
+			candidates = append(candidates, name+"(")
+		}
+	}
+	for name := range vars {
+		if strings.HasPrefix(name, prefix) {
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// completer returns the tab completion of the line editor. The name before the cursor is completed
+// up to the longest common prefix of its candidates, the candidates are listed when it can't be extended.
+func completer(vars map[string]interface{}, list func(candidates []string)) func(line string, pos int, key rune) (string, int, bool) {
+	return func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		prefix := lastIdentifier(line[:pos])
+		candidates := complete(prefix, vars)
+		if len(candidates) == 0 {
+			return line, pos, true
+		}
+		completion := candidates[0]
+		for _, candidate := range candidates[1:] {
+			for !strings.HasPrefix(candidate, completion) {
+				completion = completion[:len(completion)-1]
+			}
+		}
+		if completion == prefix {
+			list(candidates)
+			return line, pos, true
+		}
+		return line[:pos] + completion[len(prefix):] + line[pos:], pos + len(completion) - len(prefix), true
+	}
+}
+
+// lastIdentifier returns the identifier being typed at the end of a partial expression
+func lastIdentifier(line string) string {
+	start := len(line)
+	for start > 0 {
+		c := line[start-1]
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			break
+		}
+		start--
+	}
+	return line[start:]
+}
+
+// loadResponse sets the variables of a saved http response like the http matchers see them,
+// a file that isn't a response is loaded as the body
+func loadResponse(filename string, vars map[string]interface{}) error {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	vars["response"] = string(content)
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(content)), nil)
+	if err != nil {
+		vars["body"] = string(content)
+		vars["content_length"] = len(content)
+		return nil
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	for _, cookie := range resp.Cookies() {
+		vars[strings.ToLower(cookie.Name)] = cookie.Value
+	}
+	var allHeaders strings.Builder
+	for k, v := range resp.Header {
+		k = strings.ToLower(strings.Replace(strings.TrimSpace(k), "-", "_", -1))
+		vars[k] = strings.Join(v, " ")
+		allHeaders.WriteString(fmt.Sprintf("%s: %s\r\n", k, v))
+	}
+	vars["all_headers"] = allHeaders.String()
+	vars["header"] = resp.Header
+	vars["status_code"] = resp.StatusCode
+	vars["proto"] = resp.Proto
+	vars["body"] = string(body)
+	vars["content_length"] = len(body)
+	return nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	vars := map[string]interface{}{"body": "", "body_length": 0, "status_code": 200}
+	tests := []struct {
+		expression string
+		expected   []string
+	}{
+		{"contains(bo", []string{"body", "body_length"}},
+		{`to_upper(body) == "A" && status_c`, []string{"status_code"}},
+		{"base64_d", []string{"base64_decode("}},
+		{"len(body) + to_upp", []string{"to_upper("}},
+		{"unknown_name", nil},
+	}
+	for _, test := range tests {
+		if got := complete(lastIdentifier(test.expression), vars); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.expression, test.expected, got)
+		}
+	}
+}
+
+func TestCompleter(t *testing.T) {
+	vars := map[string]interface{}{"body": "", "body_length": 0, "status_code": 200}
+	var listed []string
+	callback := completer(vars, func(candidates []string) {
+		listed = candidates
+	})
+	tests := []struct {
+		line   string
+		pos    int
+		key    rune
+		ok     bool
+		result string
+		// cursor is the position of the cursor after the completion
+		cursor int
+		listed []string
+	}{
+		{line: "contains(bo", pos: 11, key: '\t', ok: true, result: "contains(body", cursor: 13},
+		// body can't be extended, its candidates are listed
+		{line: "contains(body", pos: 13, key: '\t', ok: true, result: "contains(body", cursor: 13, listed: []string{"body", "body_length"}},
+		{line: "base64_d", pos: 8, key: '\t', ok: true, result: "base64_decode(", cursor: 14},
+		// the name before the cursor is completed, the rest of the line is kept
+		{line: "len(status_c) > 1", pos: 12, key: '\t', ok: true, result: "len(status_code) > 1", cursor: 15},
+		{line: "unknown_name", pos: 12, key: '\t', ok: true, result: "unknown_name", cursor: 12},
+		{line: "status_c", pos: 8, key: 'o', ok: false},
+	}
+	for _, test := range tests {
+		listed = nil
+		result, cursor, ok := callback(test.line, test.pos, test.key)
+		if ok != test.ok {
+			t.Errorf("%q %q: expected ok %v, got %v", test.line, test.key, test.ok, ok)
+			continue
+		}
+		if ok && (result != test.result || cursor != test.cursor) {
+			t.Errorf("%q: expected %q at %d, got %q at %d", test.line, test.result, test.cursor, result, cursor)
+		}
+		if !reflect.DeepEqual(listed, test.listed) {
+			t.Errorf("%q: expected the candidates %v, got %v", test.line, test.listed, listed)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	lines := []string{"user = to_upper(token)", "", "len(user)", "to_uper(user)", ":vars", ":quit", "unread"}
+	readLine := func() (string, error) {
+		if len(lines) == 0 {
+			return "", io.EOF
+		}
+		line := lines[0]
+		lines = lines[1:]
+		return line, nil
+	}
+	var out bytes.Buffer
+	vars := map[string]interface{}{"token": "admin"}
+	run(readLine, &out, vars)
+
+	expected := "user = ADMIN\n5\nError: unknown function to_uper, did you mean to_upper\ntoken = admin\nuser = ADMIN\n"
+	if out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+	if len(lines) != 1 {
+		t.Errorf("expected :quit to stop reading, %d lines left", len(lines))
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	vars := map[string]interface{}{"body": "welcome admin", "token": "admin", "status_code": 200}
+	tests := []struct {
+		expression string
+		expected   interface{}
+		valid      bool
+	}{
+		{`contains(body, "{{token}}")`, true, true},
+		{"status_code == 200 && len(body) > 5", true, true},
+		{"to_upper(token)", "ADMIN", true},
+		{"to_uper(token)", nil, false},
+		{"md5()", nil, false},
+	}
+	for _, test := range tests {
+		result, err := evaluate(test.expression, vars)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: expected valid %v, got %v", test.expression, test.valid, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.expression, test.expected, result)
+		}
+	}
+
+	for line, expected := range map[string][]string{
+		"user = to_upper(token)": {"user", " to_upper(token)"},
+		"status_code == 200":     nil,
+		"1user = 1":              nil,
+	} {
+		var got []string
+		if matches := reAssignment.FindStringSubmatch(line); matches != nil {
+			got = matches[1:]
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected the assignment %v, got %v", line, expected, got)
+		}
+	}
+}
+
+func TestLoadResponse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dsl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	response := filepath.Join(dir, "response.txt")
+	raw := "HTTP/1.1 403 Forbidden\r\nContent-Type: text/html\r\nSet-Cookie: SID=abc; Path=/\r\nX-Powered-By: PHP\r\n\r\naccess denied"
+	if err := ioutil.WriteFile(response, []byte(raw), 0600); err != nil {
+		t.Fatal(err)
+	}
+	body := filepath.Join(dir, "body.html")
+	if err := ioutil.WriteFile(body, []byte("<html></html>"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	vars := map[string]interface{}{}
+	if err := loadResponse(response, vars); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"status_code":    403,
+		"body":           "access denied",
+		"content_length": 13,
+		"content_type":   "text/html",
+		"x_powered_by":   "PHP",
+		"sid":            "abc",
+		"proto":          "HTTP/1.1",
+		"response":       raw,
+	}
+	for name, value := range expected {
+		if vars[name] != value {
+			t.Errorf("expected %s %v, got %v", name, value, vars[name])
+		}
+	}
+	if result, err := evaluate(`status_code == 403 && contains(all_headers, "x_powered_by")`, vars); err != nil || result != true {
+		t.Errorf("expected the loaded response to match, got %v %v", result, err)
+	}
+
+	vars = map[string]interface{}{}
+	if err := loadResponse(body, vars); err != nil {
+		t.Fatal(err)
+	}
+	if vars["body"] != "<html></html>" || vars["status_code"] != nil {
+		t.Errorf("expected a file that isn't a response to be loaded as the body, got %v", vars)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/chainreactors/logs"
 	"github.com/chainreactors/neutron/common"
+	"github.com/chainreactors/neutron/input"
 	"github.com/chainreactors/neutron/protocols"
 	http2 "github.com/chainreactors/neutron/protocols/http"
 	"github.com/chainreactors/neutron/templates"
@@ -17,16 +18,19 @@ import (
 	"time"
 )
 
-var ExecuterOptions *protocols.ExecuterOptions
+// ExecuterOptions are the options shared by the templates, each template compiles a copy
+var ExecuterOptions = &protocols.ExecuterOptions{Options: &protocols.Options{Timeout: 5}}
 
 func main() {
 	// 定义命令行参数
 	proxyAddr := flag.String("proxy", "", "Proxy address (e.g., http://127.0.0.1:8080)")
 	debug := flag.Bool("debug", false, "Enable debug mode")
+	list := flag.String("l", "", "Target list file (urls, host:ports, cidrs, nmap xml, masscan json), - for stdin")
+	explain := flag.Bool("explain", false, "Explain which matchers and extractors matched on every response")
 	flag.Parse()
 
-	if len(flag.Args()) < 2 {
-		fmt.Println("Usage: shot [-proxy <proxy_address>] <path_or_file> <target_url>")
+	if len(flag.Args()) < 1 || (len(flag.Args()) < 2 && *list == "") {
+		fmt.Println("Usage: shot [-proxy <proxy_address>] [-l <targets_file>] [-explain] <path_or_file> [target_url]")
 		return
 	}
 	if *debug {
@@ -37,8 +41,21 @@ func main() {
 		spew.Config.DisableCapacities = true       // 不显示容量信息
 		spew.Config.SortKeys = true                // 对 map 按键排序
 	}
+	ExecuterOptions.Options.Explain = *explain
 	targetPath := flag.Arg(0)
-	targetURL := flag.Arg(1)
+	provider := input.NewProvider()
+	if flag.Arg(1) != "" {
+		if err := provider.AddLine(flag.Arg(1)); err != nil {
+			fmt.Printf("Invalid target: %s\n", err.Error())
+			return
+		}
+	}
+	if *list != "" {
+		if err := provider.AddFile(*list); err != nil {
+			fmt.Printf("Error reading targets: %s\n", err.Error())
+			return
+		}
+	}
 
 	if *proxyAddr != "" {
 		fmt.Println("Using proxy:", *proxyAddr)
@@ -80,20 +97,32 @@ func main() {
 			continue
 		}
 
-		err = t.Compile(ExecuterOptions)
+		// the template variables are set on the options, they must not leak into the next template
+		options := *ExecuterOptions
+		err = t.Compile(&options)
 		if err != nil {
 			fmt.Printf("Error compiling %s: %s\n", yamlFile, err.Error())
 			continue
 		}
 
 		fmt.Printf("Load success for %s\n", yamlFile)
-		start := time.Now()
-		res, err := t.Execute(targetURL, nil)
-		if err == nil {
-			fmt.Println("execute finish:", res)
-		} else {
-			fmt.Println("Error: ", err.Error())
+		for _, target := range provider.Inputs() {
+			start := time.Now()
+			ctx := target.ScanContext(nil)
+			res, err := t.ExecuteContext(ctx)
+			for _, trace := range ctx.Traces() {
+				fmt.Print(trace.String())
+			}
+			if res != nil {
+				// already printed with the traces
+				res.Trace = nil
+			}
+			if err == nil {
+				fmt.Println("execute finish:", target.String(), res)
+			} else {
+				fmt.Println("Error: ", target.String(), err.Error())
+			}
+			fmt.Println("Execution time:", time.Since(start))
 		}
-		fmt.Println("Execution time:", time.Since(start))
 	}
 }
@@ -34,6 +34,15 @@ func main() {
 				fmt.Printf("Error unmarshalling %s: %s\n", path, err.Error())
 				return nil
 			}
+			var failed bool
+			for _, issue := range t.ValidateDSL() {
+				fmt.Printf("%s:%s\n", path, issue.Error())
+				failed = failed || !issue.Warning
+			}
+			if failed {
+				fmt.Printf("Error compiling %s: invalid dsl\n", path)
+				return nil
+			}
 			err = t.Compile(ExecuterOptions)
 			if err != nil {
 				fmt.Printf("Error compiling %s: %s\n", path, err.Error())
@@ -17,9 +17,14 @@ const (
 )
 
 var (
+	// HelperFunctions and FunctionNames are the dsl helper functions at init, they are built once,
+	// see HelperFunctionNames for the functions registered at runtime
 	HelperFunctions map[string]govaluate.ExpressionFunction
 	FunctionNames   []string
 	EvalError       = errors.New("failed to evaluate while adding headers to request")
+
+	// plainEngine parses expressions without helper functions, see isExpression
+	plainEngine = dsl.NewEngineWithFunctions(nil, dsl.DefaultExpressionCacheSize)
 )
 
 func init() {
@@ -27,13 +32,25 @@ func init() {
 	FunctionNames = dsl.GetFunctionNames(HelperFunctions)
 }
 
+// HelperFunctionNames returns the names of the dsl helper functions, including the functions
+// registered at runtime
+func HelperFunctionNames() []string {
+	return dsl.GetFunctionNames(dsl.HelperFunctions())
+}
+
+// HasFunction returns true if a dsl helper function with this name is registered
+func HasFunction(name string) bool {
+	return dsl.DefaultEngine().HasFunction(name)
+}
+
+// CompileExpression compiles a dsl expression through the shared expression cache
+func CompileExpression(expression string) (*govaluate.EvaluableExpression, error) {
+	return dsl.CompileExpression(expression)
+}
+
 // Eval compiles the given expression and evaluate it with the given values preserving the return type
 func Eval(expression string, values map[string]interface{}) (interface{}, error) {
-	compiled, err := govaluate.NewEvaluableExpressionWithFunctions(expression, HelperFunctions)
-	if err != nil {
-		return nil, err
-	}
-	return compiled.Evaluate(values)
+	return dsl.EvalExpr(expression, values)
 }
 
 // Evaluate checks if the match contains a dynamic variable, for each
@@ -70,7 +87,7 @@ func evaluate(data string, base map[string]interface{}) (string, error) {
 		// replace variable placeholders with base values
 		expression = Replace(expression, base)
 		// turns expressions (either helper functions+base values or base values)
-		compiled, err := govaluate.NewEvaluableExpressionWithFunctions(expression, HelperFunctions)
+		compiled, err := CompileExpression(expression)
 		if err != nil {
 			continue
 		}
@@ -145,15 +162,15 @@ func FindExpressions(data, OpenMarker, CloseMarker string, base map[string]inter
 }
 
 func isExpression(data string, base map[string]interface{}) bool {
-	if _, err := govaluate.NewEvaluableExpression(data); err == nil {
+	if _, err := plainEngine.Compile(data); err == nil {
 		if StringsContains(getFunctionsNames(base), data) {
 			return true
-		} else if StringsContains(dsl.FunctionNames, data) {
+		} else if HasFunction(data) {
 			return true
 		}
 		return false
 	}
-	_, err := govaluate.NewEvaluableExpressionWithFunctions(data, HelperFunctions)
+	_, err := CompileExpression(data)
 	return err == nil
 }
 
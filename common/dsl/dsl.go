@@ -40,10 +40,11 @@ import (
 )
 
 var (
-	// FunctionNames is a list of function names for expression evaluation usages
+	// FunctionNames is a list of function names for expression evaluation usages, it is built once
+	// at init, the functions registered at runtime are returned by HelperFunctions
 	FunctionNames []string
 
-	// DefaultHelperFunctions is a pre-compiled list of govaluate DSL functions
+	// DefaultHelperFunctions is a pre-compiled list of govaluate DSL functions, it is built once at init
 	DefaultHelperFunctions map[string]govaluate.ExpressionFunction
 
 	funcSignatureRegex = regexp.MustCompile(`(\w+)\s*\((?:([\w\d,\s]+)\s+([.\w\d{}&*]+))?\)([\s.\w\d{}&*]+)?`)
@@ -53,7 +54,8 @@ var (
 	ErrParsingArg = errors.New("error parsing argument value")
 
 	DefaultCacheSize = 250
-	//resultCache      gcache.Cache[string, interface{}] = gcache.New[string, interface{}](DefaultCacheSize).Build()
+	// resultCache keeps the results of the cacheable functions
+	resultCache = newLRUCache(DefaultCacheSize)
 
 	// For shiro check
 	java_gadget_shiro, _ = base64.StdEncoding.DecodeString("rO0ABXNyADJvcmcuYXBhY2hlLnNoaXJvLnN1YmplY3QuU2ltcGxlUHJpbmNpcGFsQ29sbGVjdGlvbqh/WCXGowhKAwABTAAPcmVhbG1QcmluY2lwYWxzdAAPTGphdmEvdXRpbC9NYXA7eHBwdwEAeA==")
@@ -386,6 +388,18 @@ func init() {
 		hasher.Write([]byte(fmt.Sprint(args[0])))
 		return fmt.Sprintf("%d", int32(hasher.Sum32())), nil
 	}))
+	MustAddFunction(NewWithPositionalArgs("simhash", 1, false, func(args ...interface{}) (interface{}, error) {
+		return strconv.FormatUint(SimHash(toString(args[0])), 16), nil
+	}))
+	MustAddFunction(NewWithPositionalArgs("simhash_similarity", 2, false, func(args ...interface{}) (interface{}, error) {
+		return SimHashSimilarity(toString(args[0]), toString(args[1])), nil
+	}))
+	MustAddFunction(NewWithPositionalArgs("levenshtein_ratio", 2, false, func(args ...interface{}) (interface{}, error) {
+		return LevenshteinRatio(toString(args[0]), toString(args[1])), nil
+	}))
+	MustAddFunction(NewWithPositionalArgs("line_similarity", 2, false, func(args ...interface{}) (interface{}, error) {
+		return LineSimilarity(toString(args[0]), toString(args[1])), nil
+	}))
 	MustAddFunction(NewWithPositionalArgs("contains", 2, false, func(args ...interface{}) (interface{}, error) {
 		return strings.Contains(toString(args[0]), toString(args[1])), nil
 	}))
@@ -892,6 +906,17 @@ func init() {
 		}
 		return nil, fmt.Errorf("%v could not be converted to int", argStr)
 	}))
+	// placeholder is the value of a {{placeholder}} outside of a string literal, numeric strings are
+	// numbers as when the placeholder was replaced by its text, e.g. status_code == {{code}}
+	MustAddFunction(NewWithPositionalArgs("placeholder", 1, false, func(args ...interface{}) (interface{}, error) {
+		if value, ok := args[0].(string); ok {
+			value = strings.TrimSpace(value)
+			if number, err := strconv.ParseFloat(value, 64); err == nil && govalidator.IsFloat(value) {
+				return number, nil
+			}
+		}
+		return args[0], nil
+	}))
 	MustAddFunction(NewWithPositionalArgs("to_string", 1, false, func(args ...interface{}) (interface{}, error) {
 		return toString(args[0]), nil
 	}))
@@ -1142,6 +1167,60 @@ func init() {
 
 		return string(minified), nil
 	}))
+	MustAddFunction(NewWithPositionalArgs("json_path", 2, false, func(args ...interface{}) (interface{}, error) {
+		decoder := json.NewDecoder(strings.NewReader(toString(args[0])))
+		decoder.UseNumber()
+		var node interface{}
+		if err := decoder.Decode(&node); err != nil {
+			return nil, err
+		}
+		path := strings.TrimPrefix(toString(args[1]), ".")
+		if path != "" {
+			for _, key := range strings.Split(path, ".") {
+				switch v := node.(type) {
+				case map[string]interface{}:
+					value, ok := v[key]
+					if !ok {
+						return nil, fmt.Errorf("json path %s not found", path)
+					}
+					node = value
+				case []interface{}:
+					index, err := strconv.Atoi(key)
+					if err != nil || index < 0 || index >= len(v) {
+						return nil, fmt.Errorf("json path %s not found", path)
+					}
+					node = v[index]
+				default:
+					return nil, fmt.Errorf("json path %s not found", path)
+				}
+			}
+		}
+		switch v := node.(type) {
+		case string:
+			return v, nil
+		case json.Number:
+			return v.String(), nil
+		case nil:
+			return "", nil
+		}
+		data, err := json.Marshal(node)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}))
+	MustAddFunction(NewWithPositionalArgs("jwt_decode", 1, false, func(args ...interface{}) (interface{}, error) {
+		// the claims are decoded without verifying the signature
+		parts := strings.Split(strings.TrimSpace(toString(args[0])), ".")
+		if len(parts) != 3 {
+			return nil, errors.New("invalid jwt")
+		}
+		claims, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+		if err != nil {
+			return nil, err
+		}
+		return string(claims), nil
+	}))
 	MustAddFunction(NewWithPositionalArgs("json_prettify", 1, false, func(args ...interface{}) (interface{}, error) {
 		var buf bytes.Buffer
 
@@ -1291,14 +1370,20 @@ func NewWithPositionalArgs(name string, numberOfArgs int, cacheable bool, expr g
 	return function
 }
 
-// HelperFunctions returns the dsl helper functions
+// HelperFunctions returns the dsl helper functions, including the functions registered at runtime
 func HelperFunctions() map[string]govaluate.ExpressionFunction {
+	registerMu.RLock()
+	defer registerMu.RUnlock()
 	helperFunctions := make(map[string]govaluate.ExpressionFunction)
 
 	for _, function := range functions {
 		helperFunctions[function.Name] = function.Exec
-		// for backwards compatibility
-		helperFunctions[strings.Replace(function.Name, "_", "", -1)] = function.Exec
+	}
+	// for backwards compatibility, an existing function keeps its name
+	for _, function := range functions {
+		if alias := strings.Replace(function.Name, "_", "", -1); helperFunctions[alias] == nil {
+			helperFunctions[alias] = function.Exec
+		}
 	}
 
 	return helperFunctions
@@ -1,6 +1,7 @@
 package dsl
 
 import (
+	"container/list"
 	"regexp"
 	"sync"
 
@@ -8,56 +9,169 @@ import (
 )
 
 var (
-	defaultEngine *Engine
-	RegexStore    = sync.Map{}
+	defaultEngine     *Engine
+	defaultEngineOnce sync.Once
+	RegexStore        = sync.Map{}
+
+	// DefaultExpressionCacheSize is the number of compiled expressions kept by an engine
+	DefaultExpressionCacheSize = 4096
 )
 
+// Engine compiles dsl expressions, the compiled expressions are kept in a bounded LRU cache.
+// An Engine is safe for concurrent use.
 type Engine struct {
+	// HelperFunctions are the functions of the engine, use SetHelperFunctions to replace them
 	HelperFunctions map[string]govaluate.ExpressionFunction
+	// ExpressionStore are expressions compiled by the caller, they are used before the cache.
+	//
+	// Deprecated: the engine doesn't store its compiled expressions here anymore, use Compile.
 	ExpressionStore map[string]*govaluate.EvaluableExpression
-	exprmux         sync.RWMutex
+	functionsMu     sync.RWMutex
+	cache           *lruCache
 }
 
+// NewEngine creates an engine with the helper functions, including the functions registered at runtime
 func NewEngine() (*Engine, error) {
-	engine := &Engine{
-		HelperFunctions: DefaultHelperFunctions,
+	return NewEngineWithFunctions(HelperFunctions(), DefaultExpressionCacheSize), nil
+}
+
+// NewEngineWithFunctions creates an engine with custom helper functions, nil functions only parse
+// operators, variables and literals
+func NewEngineWithFunctions(functions map[string]govaluate.ExpressionFunction, size int) *Engine {
+	return &Engine{
+		HelperFunctions: functions,
 		ExpressionStore: make(map[string]*govaluate.EvaluableExpression),
+		cache:           newLRUCache(size),
 	}
-	return engine, nil
 }
 
-func (e *Engine) EvalExpr(expr string, vars map[string]interface{}) (interface{}, error) {
-	e.exprmux.Lock()
-	defer e.exprmux.Unlock()
+// SetHelperFunctions replaces the functions of the engine and drops the compiled expressions.
+// The expressions compiled before keep the functions they were compiled with.
+func (e *Engine) SetHelperFunctions(functions map[string]govaluate.ExpressionFunction) {
+	e.functionsMu.Lock()
+	e.HelperFunctions = functions
+	e.functionsMu.Unlock()
+	e.cache.purge()
+}
+
+// HasFunction returns true if the engine has a function with this name
+func (e *Engine) HasFunction(name string) bool {
+	e.functionsMu.RLock()
+	defer e.functionsMu.RUnlock()
+	_, ok := e.HelperFunctions[name]
+	return ok
+}
+
+// Compile returns the compiled expression, compile errors are cached too
+func (e *Engine) Compile(expr string) (*govaluate.EvaluableExpression, error) {
+	if value, ok := e.cache.get(expr); ok {
+		entry := value.(*cacheEntry)
+		return entry.compiled, entry.err
+	}
+	e.functionsMu.RLock()
+	if compiled, ok := e.ExpressionStore[expr]; ok {
+		e.functionsMu.RUnlock()
+		return compiled, nil
+	}
 	compiled, err := govaluate.NewEvaluableExpressionWithFunctions(expr, e.HelperFunctions)
+	e.functionsMu.RUnlock()
+	e.cache.add(expr, &cacheEntry{compiled: compiled, err: err})
+	return compiled, err
+}
+
+// EvalExpr compiles the expression through the cache and evaluates it
+func (e *Engine) EvalExpr(expr string, vars map[string]interface{}) (interface{}, error) {
+	compiled, err := e.Compile(expr)
 	if err != nil {
 		return nil, err
 	}
-
-	e.ExpressionStore[expr] = compiled
-
 	return compiled.Evaluate(vars)
 }
 
+// EvalExprFromCache is kept for compatibility, EvalExpr always uses the cache
 func (e *Engine) EvalExprFromCache(expr string, vars map[string]interface{}) (interface{}, error) {
-	compiled, ok := e.ExpressionStore[expr]
-	if !ok {
-		return e.EvalExpr(expr, vars)
-	}
+	return e.EvalExpr(expr, vars)
+}
 
-	return compiled.Evaluate(vars)
+// Purge drops all the compiled expressions
+func (e *Engine) Purge() {
+	e.cache.purge()
+}
+
+// DefaultEngine returns the engine of the default helper functions
+func DefaultEngine() *Engine {
+	defaultEngineOnce.Do(func() {
+		defaultEngine, _ = NewEngine()
+	})
+	return defaultEngine
+}
+
+// CompileExpression compiles an expression with the default engine
+func CompileExpression(expr string) (*govaluate.EvaluableExpression, error) {
+	return DefaultEngine().Compile(expr)
 }
 
 func EvalExpr(expr string, vars map[string]interface{}) (interface{}, error) {
-	if defaultEngine == nil {
-		var err error
-		defaultEngine, err = NewEngine()
-		if err != nil {
-			return nil, err
-		}
+	return DefaultEngine().EvalExpr(expr, vars)
+}
+
+type cacheEntry struct {
+	compiled *govaluate.EvaluableExpression
+	err      error
+}
+
+// lruCache is a bounded LRU cache, it is safe for concurrent use
+type lruCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cacheItem struct {
+	key   string
+	value interface{}
+}
+
+func newLRUCache(size int) *lruCache {
+	if size <= 0 {
+		size = DefaultExpressionCacheSize
+	}
+	return &lruCache{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (c *lruCache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
 	}
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheItem).value, true
+}
+
+func (c *lruCache) add(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*cacheItem).value = value
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheItem{key: key, value: value})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheItem).key)
+	}
+}
 
-	return defaultEngine.EvalExprFromCache(expr, vars)
+func (c *lruCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
 }
 
 func Regex(regxp string) (*regexp.Regexp, error) {
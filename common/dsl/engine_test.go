@@ -0,0 +1,87 @@
+package dsl
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Knetic/govaluate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineCacheBounds(t *testing.T) {
+	engine := NewEngineWithFunctions(DefaultHelperFunctions, 2)
+	first, err := engine.Compile("1 + 1")
+	require.Nil(t, err)
+	_, err = engine.Compile("2 + 2")
+	require.Nil(t, err)
+
+	// using 1 + 1 makes 2 + 2 the least recently used expression
+	compiled, err := engine.Compile("1 + 1")
+	require.Nil(t, err)
+	require.True(t, first == compiled, "1 + 1 was compiled again")
+	_, err = engine.Compile("3 + 3")
+	require.Nil(t, err)
+
+	require.Equal(t, 2, engine.cache.order.Len())
+	require.Len(t, engine.cache.entries, 2)
+	_, ok := engine.cache.get("2 + 2")
+	require.False(t, ok, "the least recently used expression was kept")
+	_, ok = engine.cache.get("1 + 1")
+	require.True(t, ok, "the recently used expression was evicted")
+
+	// compile errors are cached and count towards the bound
+	_, err = engine.Compile("undefined_function()")
+	require.NotNil(t, err)
+	_, err = engine.Compile("undefined_function()")
+	require.NotNil(t, err)
+	require.Equal(t, 2, engine.cache.order.Len())
+
+	engine.Purge()
+	require.Equal(t, 0, engine.cache.order.Len())
+	require.Len(t, engine.cache.entries, 0)
+}
+
+func TestEngineConcurrentCompile(t *testing.T) {
+	engine := NewEngineWithFunctions(DefaultHelperFunctions, 16)
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				n := (i + j) % 32
+				result, err := engine.EvalExpr(fmt.Sprintf("to_number('%d') + a", n), map[string]interface{}{"a": 1})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if result != float64(n+1) {
+					t.Errorf("expected %d, got %v", n+1, result)
+					return
+				}
+				if j%25 == 0 {
+					engine.SetHelperFunctions(DefaultHelperFunctions)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	require.True(t, engine.cache.order.Len() <= 16, "the cache grew over its bound")
+	require.Equal(t, engine.cache.order.Len(), len(engine.cache.entries))
+}
+
+func TestEngineExpressionStore(t *testing.T) {
+	engine, err := NewEngine()
+	require.Nil(t, err)
+	stored, err := govaluate.NewEvaluableExpression("1 + 1")
+	require.Nil(t, err)
+	engine.ExpressionStore["stored"] = stored
+
+	compiled, err := engine.Compile("stored")
+	require.Nil(t, err)
+	require.True(t, compiled == stored, "the stored expression wasn't used")
+	result, err := engine.EvalExprFromCache("stored", nil)
+	require.Nil(t, err)
+	require.Equal(t, float64(2), result)
+}
@@ -54,19 +54,19 @@ func (d dslFunction) Exec(args ...interface{}) (interface{}, error) {
 		return d.ExpressionFunction(args...)
 	}
 
-	//functionHash := d.hash()
-	//if result, err := resultCache.Get(functionHash); err == nil {
-	//	return result, nil
-	//}
+	functionHash := d.hash(args...)
+	if result, ok := resultCache.get(functionHash); ok {
+		return result, nil
+	}
 
 	result, err := d.ExpressionFunction(args...)
-	//if err == nil {
-	//	_ = resultCache.Set(functionHash, result)
-	//}
+	if err == nil {
+		resultCache.add(functionHash, result)
+	}
 
 	return result, err
 }
 
 func (d dslFunction) hash(args ...interface{}) string {
-	return fmt.Sprintf(d.Name, args...)
+	return fmt.Sprintf("%s%#v", d.Name, args)
 }
@@ -0,0 +1,80 @@
+package dsl
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Knetic/govaluate"
+)
+
+var (
+	reFunctionName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// registerMu guards the functions, hooksMu the registration hooks
+	registerMu    sync.RWMutex
+	hooksMu       sync.Mutex
+	registerHooks []func(functions map[string]govaluate.ExpressionFunction)
+)
+
+// RegisterFunction adds a helper function at runtime, e.g. from an application embedding the engine:
+//
+//	dsl.RegisterFunction(dsl.NewWithPositionalArgs("acme_decrypt", 1, false, decrypt))
+//	dsl.RegisterFunction(dsl.NewWithSingleSignature("license_lookup", "(serial string) string", true, lookup))
+//
+// The default engine gets a new copy of the helper functions, the expressions compiled before keep
+// the functions they were compiled with. DefaultHelperFunctions and FunctionNames are built once and
+// don't change, use HelperFunctions to get the registered functions too. Cacheable functions results
+// are cached by arguments.
+func RegisterFunction(function dslFunction) error {
+	if !reFunctionName.MatchString(function.Name) {
+		return fmt.Errorf("invalid helper function name: %q", function.Name)
+	}
+	if function.ExpressionFunction == nil {
+		return fmt.Errorf("helper function %s has no implementation", function.Name)
+	}
+
+	// the default engine is built from the helper functions, it must exist before locking
+	engine := DefaultEngine()
+	if err := addFunction(function); err != nil {
+		return err
+	}
+
+	// registrations are serialized so the engine and the hooks get the functions in order
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	helperFunctions := HelperFunctions()
+	engine.SetHelperFunctions(helperFunctions)
+	for _, hook := range registerHooks {
+		hook(helperFunctions)
+	}
+	return nil
+}
+
+// addFunction adds the function under the lock, the names and the aliases of the functions are reserved
+func addFunction(function dslFunction) error {
+	registerMu.Lock()
+	defer registerMu.Unlock()
+	for _, f := range functions {
+		if f.Name == function.Name || strings.Replace(f.Name, "_", "", -1) == function.Name {
+			return fmt.Errorf("duplicate helper function key defined: %s", function.Name)
+		}
+	}
+	functions = append(functions, function)
+	return nil
+}
+
+// MustRegisterFunction registers a helper function and panics on error
+func MustRegisterFunction(function dslFunction) {
+	if err := RegisterFunction(function); err != nil {
+		panic(err)
+	}
+}
+
+// OnRegisterFunction calls the hook with the new helper functions after every registration
+func OnRegisterFunction(hook func(functions map[string]govaluate.ExpressionFunction)) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	registerHooks = append(registerHooks, hook)
+}
@@ -0,0 +1,50 @@
+package dsl
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterFunction(t *testing.T) {
+	defaults := len(DefaultHelperFunctions)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("test_register_%d", i)
+			if err := RegisterFunction(NewWithPositionalArgs(name, 0, false, func(args ...interface{}) (interface{}, error) {
+				return i, nil
+			})); err != nil {
+				t.Error(err)
+			}
+		}(i)
+		// the functions are read while they are registered
+		go func() {
+			defer wg.Done()
+			_ = GetFunctionNames(HelperFunctions())
+			_, _ = EvalExpr("base64('a')", nil)
+			_, _ = GetFunctionSignatures("base64")
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < 8; i++ {
+		result, err := EvalExpr(fmt.Sprintf("test_register_%d()", i), nil)
+		require.Nil(t, err)
+		require.Equal(t, i, result)
+		_, ok := HelperFunctions()[fmt.Sprintf("testregister%d", i)]
+		require.True(t, ok, "the alias of test_register_%d is missing", i)
+	}
+	require.Len(t, DefaultHelperFunctions, defaults, "the default helper functions changed")
+
+	for _, name := range []string{"test_register_0", "base64", "tonumber", "invalid-name"} {
+		err := RegisterFunction(NewWithPositionalArgs(name, 0, false, func(args ...interface{}) (interface{}, error) {
+			return nil, nil
+		}))
+		require.NotNil(t, err, "%s was registered", name)
+	}
+}
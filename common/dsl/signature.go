@@ -0,0 +1,85 @@
+package dsl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// lookupFunction returns the function registered with this name, or with this name without underscores
+func lookupFunction(name string) (dslFunction, bool) {
+	registerMu.RLock()
+	defer registerMu.RUnlock()
+	for _, function := range functions {
+		if function.Name == name {
+			return function, true
+		}
+	}
+	for _, function := range functions {
+		if strings.Replace(function.Name, "_", "", -1) == name {
+			return function, true
+		}
+	}
+	return dslFunction{}, false
+}
+
+// GetFunctionSignatures returns the signatures of a helper function
+func GetFunctionSignatures(name string) ([]string, bool) {
+	function, ok := lookupFunction(name)
+	if !ok {
+		return nil, false
+	}
+	return function.GetSignatures(), true
+}
+
+// CheckArguments returns an error if no signature of the helper function accepts this number of arguments.
+// Functions without signatures accept any number of arguments.
+func CheckArguments(name string, count int) error {
+	function, ok := lookupFunction(name)
+	if !ok {
+		return fmt.Errorf("unknown function %s", name)
+	}
+	if function.NumberOfArgs > 0 {
+		if count != function.NumberOfArgs {
+			return fmt.Errorf("%s expects %d arguments, got %d, signature %q", name, function.NumberOfArgs, count, function.GetSignatures()[0])
+		}
+		return nil
+	}
+	if len(function.Signatures) == 0 {
+		return nil
+	}
+	for _, signature := range function.Signatures {
+		min, max := signatureArity(signature)
+		if count >= min && (max < 0 || count <= max) {
+			return nil
+		}
+	}
+	return fmt.Errorf("no signature of %s accepts %d arguments, signatures %q", name, count, function.GetSignatures())
+}
+
+// signatureArity returns the minimum and maximum (-1 for variadic) number of arguments of a signature
+// such as "(input string, separator string, optionalChunkSize) []string"
+func signatureArity(signature string) (int, int) {
+	start := strings.Index(signature, "(")
+	end := strings.Index(signature, ")")
+	if start < 0 || end < start {
+		return 0, -1
+	}
+	params := strings.TrimSpace(signature[start+1 : end])
+	if params == "" {
+		return 0, 0
+	}
+	var min, max int
+	for _, param := range strings.Split(params, ",") {
+		param = strings.TrimSpace(param)
+		switch {
+		case strings.Contains(param, "..."):
+			return min, -1
+		case strings.HasPrefix(param, "optional"):
+			max++
+		default:
+			min++
+			max++
+		}
+	}
+	return min, max
+}
@@ -0,0 +1,113 @@
+package dsl
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// maxLevenshteinLength is the number of leading runes compared by LevenshteinRatio,
+// the distance is quadratic so large bodies are truncated
+const maxLevenshteinLength = 4096
+
+// SimHash returns the 64 bits simhash of the words of s
+func SimHash(s string) uint64 {
+	var weights [64]int
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		hasher := fnv.New64a()
+		hasher.Write([]byte(word))
+		sum := hasher.Sum64()
+		for i := range weights {
+			if sum&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+	var hash uint64
+	for i, weight := range weights {
+		if weight > 0 {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+// SimHashSimilarity returns the similarity ratio of the simhashes of a and b, between 0 and 1
+func SimHashSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	return 1 - float64(bits.OnesCount64(SimHash(a)^SimHash(b)))/64
+}
+
+// LevenshteinRatio returns 1 - distance/length of the longest string, between 0 and 1
+func LevenshteinRatio(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) > maxLevenshteinLength {
+		ra = ra[:maxLevenshteinLength]
+	}
+	if len(rb) > maxLevenshteinLength {
+		rb = rb[:maxLevenshteinLength]
+	}
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(minInt(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return 1 - float64(previous[len(rb)])/float64(longest)
+}
+
+// LineSimilarity returns the ratio of lines shared by a and b, 2*common/(lines of a + lines of b)
+func LineSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	linesA, linesB := strings.Split(a, "\n"), strings.Split(b, "\n")
+	counts := make(map[string]int, len(linesA))
+	for _, line := range linesA {
+		counts[strings.TrimSpace(line)]++
+	}
+	var common int
+	for _, line := range linesB {
+		line = strings.TrimSpace(line)
+		if counts[line] > 0 {
+			counts[line]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(linesA)+len(linesB))
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,55 @@
+package dsl
+
+import (
+	"hash/fnv"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimilarity(t *testing.T) {
+	long := strings.Repeat("a", maxLevenshteinLength)
+	tests := []struct {
+		algorithm  string
+		similarity func(a, b string) float64
+		a, b       string
+		expected   float64
+	}{
+		{"levenshtein", LevenshteinRatio, "kitten", "sitting", 1 - 3.0/7},
+		{"levenshtein", LevenshteinRatio, "abc", "", 0},
+		{"levenshtein", LevenshteinRatio, "", "", 1},
+		{"levenshtein", LevenshteinRatio, "héllo", "hello", 0.8},
+		// the runes after maxLevenshteinLength are ignored
+		{"levenshtein", LevenshteinRatio, long + "abc", long + "xyz", 1},
+		{"lines", LineSimilarity, "a\nb\nc", "a\nb\nd", 2 * 2.0 / 6},
+		{"lines", LineSimilarity, "a\nb", "b\na", 1},
+		{"lines", LineSimilarity, "a  \n\tb", "a\nb", 1},
+		{"lines", LineSimilarity, "a\na", "a\nb", 0.5},
+		{"simhash", SimHashSimilarity, "welcome admin", "welcome admin", 1},
+		// simhash hashes the words, the order and the separators don't matter
+		{"simhash", SimHashSimilarity, "welcome admin", "admin, welcome!", 1},
+		{"simhash", SimHashSimilarity, "", "", 1},
+	}
+	for _, test := range tests {
+		got := test.similarity(test.a, test.b)
+		require.True(t, math.Abs(got-test.expected) < 1e-9, "%s(%q, %q): expected %v, got %v", test.algorithm, test.a, test.b, test.expected, got)
+		require.Equal(t, got, test.similarity(test.b, test.a), "%s(%q, %q) is not symmetric", test.algorithm, test.a, test.b)
+	}
+}
+
+func TestSimHash(t *testing.T) {
+	// a single word is its own fnv hash
+	hasher := fnv.New64a()
+	hasher.Write([]byte("hello"))
+	require.Equal(t, hasher.Sum64(), SimHash("<hello>"))
+	require.Equal(t, uint64(0), SimHash(""))
+
+	page := "<html><title>admin panel</title><body>welcome admin, you have 3 new messages</body></html>"
+	changed := strings.Replace(page, "3", "4", 1)
+	other := "<html><title>404 not found</title><body>the requested url was not found on this server</body></html>"
+	similar, different := SimHashSimilarity(page, changed), SimHashSimilarity(page, other)
+	require.True(t, similar > different, "a small change %v is less similar than another page %v", similar, different)
+	require.True(t, similar >= 0.8, "a small change is %v similar", similar)
+}
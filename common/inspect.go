@@ -0,0 +1,126 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/Knetic/govaluate"
+	"github.com/chainreactors/neutron/common/dsl"
+)
+
+// stubFunction stands for any function while an expression is inspected
+func stubFunction(args ...interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+// InspectExpression checks the functions of a dsl expression against their signatures and returns
+// the variables it uses. dsl is false if the expression doesn't parse even with its unknown functions
+// defined, e.g. a {{7*'7'}} template injection payload of a request, it is then not evaluated at runtime.
+func InspectExpression(expression string) (variables []string, isDSL bool, err error) {
+	calls := scanFunctionCalls(expression)
+	stubs := make(map[string]govaluate.ExpressionFunction, len(calls))
+	for _, call := range calls {
+		stubs[call] = stubFunction
+	}
+	compiled, parseErr := govaluate.NewEvaluableExpressionWithFunctions(expression, stubs)
+	if parseErr != nil {
+		return nil, false, nil
+	}
+
+	tokens := compiled.Tokens()
+	var index int
+	for i, token := range tokens {
+		switch token.Kind {
+		case govaluate.VARIABLE:
+			variables = append(variables, token.Value.(string))
+		case govaluate.FUNCTION:
+			if index >= len(calls) {
+				continue
+			}
+			name := calls[index]
+			index++
+			if !HasFunction(name) {
+				return variables, true, unknownFunctionError(name)
+			}
+			if err := dsl.CheckArguments(name, countArguments(tokens[i+1:])); err != nil {
+				return variables, true, err
+			}
+		}
+	}
+	return variables, true, nil
+}
+
+// scanFunctionCalls returns the names of the function calls in order, method calls of
+// other languages (e.g. config.items()) are not dsl calls
+func scanFunctionCalls(expression string) []string {
+	var calls []string
+	for i := 0; i < len(expression); i++ {
+		c := expression[i]
+		if c == '"' || c == '\'' {
+			i = closingQuote(expression, i) - 1
+			continue
+		}
+		if !isIdentifierStart(c) || (i > 0 && (isIdentifierChar(expression[i-1]) || expression[i-1] == '.')) {
+			continue
+		}
+		end := i
+		for end < len(expression) && isIdentifierChar(expression[end]) {
+			end++
+		}
+		next := end
+		for next < len(expression) && expression[next] == ' ' {
+			next++
+		}
+		if next < len(expression) && expression[next] == '(' {
+			calls = append(calls, expression[i:end])
+		}
+		i = end - 1
+	}
+	return calls
+}
+
+// countArguments counts the arguments of the call starting at its opening clause
+func countArguments(tokens []govaluate.ExpressionToken) int {
+	var depth, count int
+	for i, token := range tokens {
+		switch token.Kind {
+		case govaluate.CLAUSE:
+			depth++
+			if depth == 1 && i+1 < len(tokens) && tokens[i+1].Kind != govaluate.CLAUSE_CLOSE {
+				count = 1
+			}
+		case govaluate.CLAUSE_CLOSE:
+			depth--
+			if depth == 0 {
+				return count
+			}
+		case govaluate.SEPARATOR:
+			if depth == 1 {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// unknownFunctionError suggests the closest function name
+func unknownFunctionError(name string) error {
+	var closest string
+	var best float64
+	for _, function := range HelperFunctionNames() {
+		if ratio := dsl.LevenshteinRatio(name, function); ratio > best {
+			closest, best = function, ratio
+		}
+	}
+	if best >= 0.6 {
+		return fmt.Errorf("unknown function %s, did you mean %s", name, closest)
+	}
+	return fmt.Errorf("unknown function %s", name)
+}
+
+func isIdentifierStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentifierChar(c byte) bool {
+	return isIdentifierStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,118 @@
+package common
+
+import (
+	"regexp"
+	"strings"
+)
+
+// reHyphenVariable matches variables govaluate would parse as a subtraction, e.g. template-id
+var reHyphenVariable = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(-[A-Za-z0-9_]+)+$`)
+
+// ResolvePlaceholders rewrites the {{placeholders}} of a dsl expression into sub expressions, so that
+// the expression is compiled once and the placeholders are resolved through the parameters:
+//
+//	contains(body, "user: {{username}}") => contains(body, concat("user: ", (username)))
+//	status_code == {{code}}             => status_code == placeholder((code))
+//
+// Placeholders outside of string literals are numbers if their value is numeric, as when they were
+// replaced by their text.
+func ResolvePlaceholders(expression string) string {
+	if !strings.Contains(expression, ParenthesisOpen) && !strings.Contains(expression, General) {
+		return expression
+	}
+	builder := &strings.Builder{}
+	for i := 0; i < len(expression); {
+		switch c := expression[i]; {
+		case c == '"' || c == '\'':
+			end := closingQuote(expression, i)
+			builder.WriteString(resolveLiteral(expression[i:end], c))
+			i = end
+		default:
+			if inner, end, ok := placeholderAt(expression, i); ok {
+				builder.WriteString("placeholder(" + placeholderExpression(inner) + ")")
+				i = end
+				continue
+			}
+			builder.WriteByte(c)
+			i++
+		}
+	}
+	return builder.String()
+}
+
+// placeholderAt returns the inner expression of the placeholder starting at i, and the index after it
+func placeholderAt(expression string, i int) (string, int, bool) {
+	for _, markers := range [][2]string{{ParenthesisOpen, ParenthesisClose}, {General, General}} {
+		if !strings.HasPrefix(expression[i:], markers[0]) {
+			continue
+		}
+		start := i + len(markers[0])
+		end := strings.Index(expression[start:], markers[1])
+		if end <= 0 {
+			return "", 0, false
+		}
+		return strings.TrimSpace(expression[start : start+end]), start + end + len(markers[1]), true
+	}
+	return "", 0, false
+}
+
+// closingQuote returns the index after the string literal starting at i, quotes of placeholders are skipped
+func closingQuote(expression string, i int) int {
+	quote := expression[i]
+	for j := i + 1; j < len(expression); j++ {
+		switch expression[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		default:
+			if _, end, ok := placeholderAt(expression, j); ok {
+				j = end - 1
+			}
+		}
+	}
+	return len(expression)
+}
+
+// resolveLiteral turns a string literal containing placeholders into a concat of its parts
+func resolveLiteral(literal string, quote byte) string {
+	if len(literal) < 2 || literal[len(literal)-1] != quote {
+		return literal
+	}
+	content := literal[1 : len(literal)-1]
+	var parts []string
+	var text strings.Builder
+	for i := 0; i < len(content); {
+		if content[i] == '\\' && i+1 < len(content) {
+			text.WriteString(content[i : i+2])
+			i += 2
+			continue
+		}
+		if inner, end, ok := placeholderAt(content, i); ok {
+			if text.Len() > 0 {
+				parts = append(parts, string(quote)+text.String()+string(quote))
+				text.Reset()
+			}
+			parts = append(parts, placeholderExpression(inner))
+			i = end
+			continue
+		}
+		text.WriteByte(content[i])
+		i++
+	}
+	if len(parts) == 0 {
+		return literal
+	}
+	if text.Len() > 0 {
+		parts = append(parts, string(quote)+text.String()+string(quote))
+	}
+	return "concat(" + strings.Join(parts, ", ") + ")"
+}
+
+// placeholderExpression returns the placeholder as a sub expression, hyphenated variables are escaped
+func placeholderExpression(inner string) string {
+	if reHyphenVariable.MatchString(inner) {
+		return "[" + inner + "]"
+	}
+	return "(" + inner + ")"
+}
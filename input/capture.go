@@ -0,0 +1,106 @@
+package input
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/chainreactors/neutron/protocols"
+	"github.com/chainreactors/neutron/protocols/http"
+)
+
+type harLog struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method  string `json:"method"`
+				URL     string `json:"url"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				PostData *struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+				} `json:"postData"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// addHAR reads the requests of a HAR capture
+func (p *Provider) addHAR(r io.Reader) error {
+	var har harLog
+	if err := json.NewDecoder(r).Decode(&har); err != nil {
+		return err
+	}
+	for _, entry := range har.Log.Entries {
+		headers := make(map[string]string, len(entry.Request.Headers))
+		for _, header := range entry.Request.Headers {
+			// duplicated headers (cookies of http2 captures) are joined
+			if value, ok := headers[header.Name]; ok {
+				separator := ", "
+				if strings.EqualFold(header.Name, "Cookie") {
+					separator = "; "
+				}
+				headers[header.Name] = value + separator + header.Value
+				continue
+			}
+			headers[header.Name] = header.Value
+		}
+		var body string
+		if entry.Request.PostData != nil {
+			body = entry.Request.PostData.Text
+			if _, ok := headers["Content-Type"]; !ok && entry.Request.PostData.MimeType != "" {
+				headers["Content-Type"] = entry.Request.PostData.MimeType
+			}
+		}
+		p.Add(NewRequestInput(protocols.NewBaseRequest(entry.Request.Method, entry.Request.URL, headers, body)))
+	}
+	return nil
+}
+
+type burpItems struct {
+	Items []struct {
+		URL      string `xml:"url"`
+		Protocol string `xml:"protocol"`
+		Host     string `xml:"host"`
+		Port     string `xml:"port"`
+		Request  struct {
+			Base64 bool   `xml:"base64,attr"`
+			Data   string `xml:",chardata"`
+		} `xml:"request"`
+	} `xml:"item"`
+}
+
+// addBurp reads the requests of a Burp "save items" xml export
+func (p *Provider) addBurp(r io.Reader) error {
+	var items burpItems
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	if err := decoder.Decode(&items); err != nil {
+		return err
+	}
+	for _, item := range items.Items {
+		raw := item.Request.Data
+		if item.Request.Base64 {
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(raw))
+			if err != nil {
+				continue
+			}
+			raw = string(decoded)
+		}
+		baseURL := item.Protocol + "://" + item.Host
+		if item.Port != "" && !(item.Protocol == SchemeHTTP && item.Port == "80") && !(item.Protocol == SchemeHTTPS && item.Port == "443") {
+			baseURL += ":" + item.Port
+		}
+		req, err := http.ParseRawRequest(raw, baseURL)
+		if err != nil {
+			continue
+		}
+		p.Add(NewRequestInput(req))
+	}
+	return nil
+}
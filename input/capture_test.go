@@ -0,0 +1,106 @@
+package input
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chainreactors/neutron/protocols"
+)
+
+func TestAddCapture(t *testing.T) {
+	burpRequest := "POST /login?next=%2F HTTP/1.1\r\nHost: example.com:8443\r\nCookie: sid=1\r\nContent-Type: application/x-www-form-urlencoded\r\nContent-Length: 7\r\n\r\nuser=ad"
+	tests := []struct {
+		name     string
+		data     string
+		expected []*protocols.BaseRequest
+	}{
+		{
+			name: "har",
+			data: `{"log": {"version": "1.2", "entries": [
+{"request": {"method": "post", "url": "https://example.com/api?id=1",
+	"headers": [{"name": ":authority", "value": "example.com"}, {"name": "Host", "value": "example.com"},
+		{"name": "Cookie", "value": "a=1"}, {"name": "Cookie", "value": "b=2"},
+		{"name": "Accept", "value": "text/html"}, {"name": "Accept", "value": "*/*"}],
+	"postData": {"mimeType": "application/json", "text": "{\"id\":1}"}}},
+{"request": {"method": "GET", "url": "http://example.com/", "headers": [{"name": "Content-Type", "value": "text/plain"}]}}
+]}}`,
+			expected: []*protocols.BaseRequest{
+				{
+					Method:  "POST",
+					URL:     "https://example.com/api?id=1",
+					Headers: map[string]string{"Cookie": "a=1; b=2", "Accept": "text/html, */*", "Content-Type": "application/json"},
+					Body:    `{"id":1}`,
+				},
+				{Method: "GET", URL: "http://example.com/", Headers: map[string]string{"Content-Type": "text/plain"}},
+			},
+		},
+		{
+			name: "burp",
+			data: `<?xml version="1.0"?>
+<!DOCTYPE items [
+<!ELEMENT items (item*)>
+]>
+<items burpVersion="2023.1">
+  <item>
+    <url><![CDATA[https://example.com:8443/login?next=%2F]]></url>
+    <host ip="10.0.0.1">example.com</host>
+    <port>8443</port>
+    <protocol>https</protocol>
+    <request base64="true"><![CDATA[` + base64.StdEncoding.EncodeToString([]byte(burpRequest)) + `]]></request>
+  </item>
+  <item>
+    <url><![CDATA[http://example.com/]]></url>
+    <host ip="10.0.0.1">example.com</host>
+    <port>80</port>
+    <protocol>http</protocol>
+    <request base64="false"><![CDATA[GET / HTTP/1.1
+Host: example.com
+Accept: */*
+
+]]></request>
+  </item>
+  <item>
+    <host ip="10.0.0.1">example.com</host>
+    <port>80</port>
+    <protocol>http</protocol>
+    <request base64="true"><![CDATA[not base64]]></request>
+  </item>
+</items>`,
+			expected: []*protocols.BaseRequest{
+				{
+					Method:  "POST",
+					URL:     "https://example.com:8443/login?next=%2F",
+					Headers: map[string]string{"Cookie": "sid=1", "Content-Type": "application/x-www-form-urlencoded"},
+					Body:    "user=ad",
+				},
+				{Method: "GET", URL: "http://example.com/", Headers: map[string]string{"Accept": "*/*"}},
+			},
+		},
+	}
+	for _, test := range tests {
+		p := NewProvider()
+		if err := p.AddReader(strings.NewReader(test.data)); err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		inputs := p.Inputs()
+		if len(inputs) != len(test.expected) {
+			t.Errorf("%s: expected %d requests, got %v", test.name, len(test.expected), inputStrings(p))
+			continue
+		}
+		for i, in := range inputs {
+			if in.Request == nil {
+				t.Errorf("%s %d: the input has no request", test.name, i)
+				continue
+			}
+			if !reflect.DeepEqual(in.Request, test.expected[i]) {
+				t.Errorf("%s %d: expected %+v, got %+v", test.name, i, test.expected[i], in.Request)
+			}
+			if in.String() != test.expected[i].URL {
+				t.Errorf("%s %d: expected the target %s, got %s", test.name, i, test.expected[i].URL, in.String())
+			}
+		}
+	}
+}
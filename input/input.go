@@ -0,0 +1,142 @@
+package input
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/chainreactors/neutron/protocols"
+)
+
+const (
+	SchemeHTTP  = "http"
+	SchemeHTTPS = "https"
+	SchemeTCP   = "tcp"
+)
+
+var (
+	httpPorts  = map[string]bool{"80": true, "81": true, "591": true, "2080": true, "3000": true, "5000": true, "7001": true, "8000": true, "8008": true, "8080": true, "8081": true, "8088": true, "8888": true, "9000": true, "9090": true}
+	httpsPorts = map[string]bool{"443": true, "832": true, "981": true, "2083": true, "4443": true, "5001": true, "7443": true, "8443": true, "9443": true}
+)
+
+// Input is a single normalised scan target
+type Input struct {
+	// Raw is the original value the input was parsed from
+	Raw string
+	// Scheme is the guessed scheme, http, https or tcp
+	Scheme string
+	Host   string
+	// Port is empty when neither the input nor the scheme defines one
+	Port string
+	// Path and RawQuery are only kept for url inputs
+	Path     string
+	RawQuery string
+	// Request is the captured request of HAR and Burp inputs
+	Request *protocols.BaseRequest
+}
+
+// NewInput creates an input from host, port and an optional scheme, the scheme is guessed when empty
+func NewInput(raw, scheme, host, port string) *Input {
+	in := &Input{Raw: raw, Scheme: strings.ToLower(scheme), Host: strings.Trim(host, "[]"), Port: port}
+	if in.Scheme == "" {
+		in.Scheme = GuessScheme(port)
+	}
+	return in
+}
+
+// NewRequestInput creates an input from a captured request
+func NewRequestInput(req *protocols.BaseRequest) *Input {
+	parsed, err := url.Parse(req.URL)
+	if err != nil || parsed.Host == "" {
+		return nil
+	}
+	in := NewInput(req.URL, parsed.Scheme, parsed.Hostname(), parsed.Port())
+	in.Path = parsed.Path
+	in.Request = req
+	return in
+}
+
+// GuessScheme guesses the scheme of a service from its port
+func GuessScheme(port string) string {
+	switch {
+	case port == "":
+		return SchemeHTTP
+	case httpsPorts[port]:
+		return SchemeHTTPS
+	case httpPorts[port]:
+		return SchemeHTTP
+	default:
+		return SchemeTCP
+	}
+}
+
+// IsHTTP returns true if the input looks like a web service
+func (i *Input) IsHTTP() bool {
+	return i.Scheme == SchemeHTTP || i.Scheme == SchemeHTTPS
+}
+
+// Address returns the host:port of the input, the port defaults to the scheme port
+func (i *Input) Address() string {
+	port := i.Port
+	if port == "" {
+		switch i.Scheme {
+		case SchemeHTTPS:
+			port = "443"
+		case SchemeHTTP:
+			port = "80"
+		default:
+			return i.Host
+		}
+	}
+	return net.JoinHostPort(i.Host, port)
+}
+
+// URL returns the url of the input, tcp inputs are returned with the http scheme
+func (i *Input) URL() string {
+	scheme := i.Scheme
+	if !i.IsHTTP() {
+		scheme = SchemeHTTP
+	}
+	host := i.Host
+	if i.Port != "" {
+		host = net.JoinHostPort(i.Host, i.Port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	u := &url.URL{Scheme: scheme, Host: host, Path: i.Path, RawQuery: i.RawQuery}
+	return u.String()
+}
+
+// String returns the target to pass to templates, an url for web services and host:port otherwise
+func (i *Input) String() string {
+	if i.Request != nil {
+		return i.Request.URL
+	}
+	if i.IsHTTP() {
+		return i.URL()
+	}
+	return i.Address()
+}
+
+// ScanContext creates the scan context of the input
+func (i *Input) ScanContext(payloads map[string]interface{}) *protocols.ScanContext {
+	if i.Request != nil {
+		return protocols.NewScanContextWithRequest(i.Request, payloads)
+	}
+	return protocols.NewScanContext(i.String(), payloads)
+}
+
+// key is the deduplication key of the input, captured requests are unique by method, url and body
+func (i *Input) key() string {
+	if i.Request != nil {
+		sum := md5.Sum([]byte(i.Request.Body))
+		return i.Request.Method + " " + i.Request.URL + " " + hex.EncodeToString(sum[:])
+	}
+	key := i.Scheme + "://" + strings.ToLower(i.Address()) + i.Path
+	if i.RawQuery != "" {
+		key += "?" + i.RawQuery
+	}
+	return key
+}
@@ -0,0 +1,273 @@
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxExpandSize is the largest cidr or ip range that will be expanded
+const maxExpandSize = 1 << 24
+
+// Provider turns target lists into deduplicated inputs
+type Provider struct {
+	// Ports are applied to inputs without a port, empty keeps the input as is
+	Ports []string
+
+	inputs []*Input
+	seen   map[string]struct{}
+}
+
+// NewProvider creates an empty input provider
+func NewProvider() *Provider {
+	return &Provider{seen: make(map[string]struct{})}
+}
+
+// Inputs returns the inputs in insertion order
+func (p *Provider) Inputs() []*Input {
+	return p.inputs
+}
+
+// Count returns the number of unique inputs
+func (p *Provider) Count() int {
+	return len(p.inputs)
+}
+
+// Add adds an input, duplicates are ignored
+func (p *Provider) Add(in *Input) {
+	if in == nil || in.Host == "" {
+		return
+	}
+	if _, ok := p.seen[in.key()]; ok {
+		return
+	}
+	p.seen[in.key()] = struct{}{}
+	p.inputs = append(p.inputs, in)
+}
+
+// AddFile reads the inputs of a file, "-" reads from stdin
+func (p *Provider) AddFile(filename string) error {
+	if filename == "-" {
+		return p.AddReader(os.Stdin)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return p.AddReader(f)
+}
+
+// AddReader reads inputs from a reader, nmap xml, masscan json, HAR and Burp xml
+// outputs are detected automatically, anything else is read as one target per line.
+func (p *Provider) AddReader(r io.Reader) error {
+	reader := bufio.NewReader(r)
+	// burp exports start with a long doctype, the peeked head must include the root element
+	head, _ := reader.Peek(4096)
+	head = bytes.TrimSpace(head)
+	switch {
+	case bytes.HasPrefix(head, []byte("<?xml")) && bytes.Contains(head, []byte("<items")):
+		return p.addBurp(reader)
+	case bytes.HasPrefix(head, []byte("<?xml")) || bytes.HasPrefix(head, []byte("<nmaprun")):
+		return p.addNmap(reader)
+	case bytes.HasPrefix(head, []byte("{")) && bytes.Contains(head, []byte(`"log"`)):
+		return p.addHAR(reader)
+	case isMasscan(head):
+		return p.addMasscan(reader)
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if err := p.AddLine(scanner.Text()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// isMasscan detects masscan json by its records, a bracket alone starts an ipv6 target like [::1]:8080
+func isMasscan(head []byte) bool {
+	if bytes.HasPrefix(head, []byte("[")) {
+		head = bytes.TrimSpace(head[1:])
+	}
+	return bytes.HasPrefix(head, []byte("{")) && bytes.Contains(head, []byte(`"ip"`))
+}
+
+// AddLine parses a single target, supported formats are:
+//
+//	url               http://example.com:8080/path?query
+//	host or ip        example.com, 10.0.0.1
+//	host:ports        example.com:80,443,8000-8010
+//	cidr[:ports]      10.0.0.0/24, 10.0.0.0/24:80,443
+//	ip range[:ports]  10.0.0.1-10.0.0.20, 10.0.0.1-20
+//
+// Empty lines and lines starting with # are ignored.
+func (p *Provider) AddLine(line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+
+	if strings.Contains(line, "://") {
+		parsed, err := url.Parse(line)
+		if err != nil {
+			return fmt.Errorf("invalid url %s: %w", line, err)
+		}
+		in := NewInput(line, parsed.Scheme, parsed.Hostname(), parsed.Port())
+		if parsed.Scheme != SchemeHTTP && parsed.Scheme != SchemeHTTPS {
+			in.Scheme = SchemeTCP
+		}
+		if parsed.Path != "/" || parsed.RawQuery != "" {
+			in.Path = parsed.Path
+		}
+		in.RawQuery = parsed.RawQuery
+		p.Add(in)
+		return nil
+	}
+
+	hosts, ports := splitPorts(line)
+	if len(ports) == 0 {
+		ports = p.Ports
+	}
+	var expandErr error
+	expand := func(host string) {
+		if len(ports) == 0 {
+			p.Add(NewInput(line, "", host, ""))
+			return
+		}
+		for _, port := range ports {
+			p.Add(NewInput(line, "", host, port))
+		}
+	}
+	switch {
+	case strings.Contains(hosts, "/"):
+		expandErr = expandCIDR(hosts, expand)
+	case strings.Contains(hosts, "-") && isIPRange(hosts):
+		expandErr = expandRange(hosts, expand)
+	default:
+		expand(hosts)
+	}
+	return expandErr
+}
+
+// splitPorts splits the host part from a port list, ipv6 addresses need brackets to carry ports
+func splitPorts(line string) (string, []string) {
+	if strings.HasPrefix(line, "[") {
+		end := strings.Index(line, "]")
+		if end < 0 {
+			return line, nil
+		}
+		host := line[1:end]
+		if !strings.HasPrefix(line[end+1:], ":") {
+			return host, nil
+		}
+		return host, parsePorts(line[end+2:])
+	}
+	if strings.Count(line, ":") != 1 {
+		return line, nil
+	}
+	i := strings.Index(line, ":")
+	return line[:i], parsePorts(line[i+1:])
+}
+
+// parsePorts parses a port list like 80,443,8000-8010
+func parsePorts(s string) []string {
+	var ports []string
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "-", 2)
+		start, err := strconv.Atoi(parts[0])
+		if err != nil {
+			continue
+		}
+		end := start
+		if len(parts) == 2 {
+			if end, err = strconv.Atoi(parts[1]); err != nil {
+				continue
+			}
+		}
+		for port := start; port <= end && port <= 65535; port++ {
+			ports = append(ports, strconv.Itoa(port))
+		}
+	}
+	return ports
+}
+
+func isIPRange(s string) bool {
+	parts := strings.SplitN(s, "-", 2)
+	return net.ParseIP(strings.TrimSpace(parts[0])) != nil
+}
+
+// expandCIDR calls fn for every address of a cidr
+func expandCIDR(cidr string, fn func(string)) error {
+	ip, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return fmt.Errorf("invalid cidr %s: %w", cidr, err)
+	}
+	ones, bits := ipnet.Mask.Size()
+	if bits-ones > 24 {
+		return fmt.Errorf("cidr %s is too large to expand", cidr)
+	}
+	for current := ip.Mask(ipnet.Mask); ipnet.Contains(current); current = nextIP(current) {
+		fn(current.String())
+	}
+	return nil
+}
+
+// expandRange calls fn for every address of a range like 10.0.0.1-10.0.0.20 or 10.0.0.1-20
+func expandRange(s string, fn func(string)) error {
+	parts := strings.SplitN(s, "-", 2)
+	start := net.ParseIP(strings.TrimSpace(parts[0])).To4()
+	if start == nil {
+		return fmt.Errorf("invalid ip range %s, only ipv4 ranges are supported", s)
+	}
+	endRaw := strings.TrimSpace(parts[1])
+	end := net.ParseIP(endRaw).To4()
+	if end == nil {
+		last, err := strconv.Atoi(endRaw)
+		if err != nil || last > 255 {
+			return fmt.Errorf("invalid ip range %s", s)
+		}
+		end = net.IPv4(start[0], start[1], start[2], byte(last)).To4()
+	}
+	from, to := binary.BigEndian.Uint32(start), binary.BigEndian.Uint32(end)
+	if to < from || to-from >= maxExpandSize {
+		return fmt.Errorf("invalid ip range %s", s)
+	}
+	for i := from; ; i++ {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, i)
+		fn(ip.String())
+		if i == to {
+			break
+		}
+	}
+	return nil
+}
+
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	if next.Equal(net.IPv4zero) || next.Equal(net.IPv6zero) {
+		// wrapped around, stop the iteration
+		return nil
+	}
+	return next
+}
@@ -0,0 +1,92 @@
+package input
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func inputStrings(p *Provider) []string {
+	var inputs []string
+	for _, in := range p.Inputs() {
+		inputs = append(inputs, in.String())
+	}
+	return inputs
+}
+
+func TestAddLine(t *testing.T) {
+	tests := []struct {
+		line   string
+		ports  []string
+		inputs []string
+	}{
+		{"http://example.com:8080/path?id=1&q=a", nil, []string{"http://example.com:8080/path?id=1&q=a"}},
+		{"https://example.com/?id=1", nil, []string{"https://example.com/?id=1"}},
+		{"https://example.com/", nil, []string{"https://example.com"}},
+		{"redis://10.0.0.1:6379", nil, []string{"10.0.0.1:6379"}},
+		{"example.com", nil, []string{"http://example.com"}},
+		{"example.com", []string{"80", "22"}, []string{"http://example.com:80", "example.com:22"}},
+		{"example.com:443,8000-8001", nil, []string{"https://example.com:443", "http://example.com:8000", "example.com:8001"}},
+		{"[::1]:8080", nil, []string{"http://[::1]:8080"}},
+		{"10.0.0.0/31:22", nil, []string{"10.0.0.0:22", "10.0.0.1:22"}},
+		{"10.0.0.1-3", nil, []string{"http://10.0.0.1", "http://10.0.0.2", "http://10.0.0.3"}},
+		{"# comment", nil, nil},
+	}
+	for _, test := range tests {
+		p := NewProvider()
+		p.Ports = test.ports
+		if err := p.AddLine(test.line); err != nil {
+			t.Errorf("%s: %s", test.line, err)
+			continue
+		}
+		if inputs := inputStrings(p); !reflect.DeepEqual(inputs, test.inputs) {
+			t.Errorf("%s: expected %v, got %v", test.line, test.inputs, inputs)
+		}
+	}
+}
+
+func TestAddReader(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		inputs []string
+	}{
+		{
+			name: "lines",
+			data: "example.com\n\nexample.com\nhttp://example.com:80/\n",
+			// the default port of the scheme is the same target
+			inputs: []string{"http://example.com"},
+		},
+		{
+			name:   "ipv6 lines",
+			data:   "[::1]:8080\n[fe80::1]:443\n",
+			inputs: []string{"http://[::1]:8080", "https://[fe80::1]:443"},
+		},
+		{
+			name: "masscan",
+			data: `[
+{   "ip": "10.0.0.1",   "timestamp": "1600000000", "ports": [ {"port": 443, "proto": "tcp", "status": "open"} ] },
+{   "ip": "10.0.0.2",   "timestamp": "1600000000", "ports": [ {"port": 53, "proto": "udp", "status": "open"} ] },
+]`,
+			inputs: []string{"https://10.0.0.1:443"},
+		},
+		{
+			name: "nmap",
+			data: `<?xml version="1.0"?>
+<nmaprun><host><status state="up"/><address addr="10.0.0.1" addrtype="ipv4"/>
+<ports><port protocol="tcp" portid="8443"><state state="open"/><service name="http" tunnel="ssl"/></port>
+<port protocol="tcp" portid="22"><state state="closed"/><service name="ssh"/></port></ports></host></nmaprun>`,
+			inputs: []string{"https://10.0.0.1:8443"},
+		},
+	}
+	for _, test := range tests {
+		p := NewProvider()
+		if err := p.AddReader(strings.NewReader(test.data)); err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if inputs := inputStrings(p); !reflect.DeepEqual(inputs, test.inputs) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.inputs, inputs)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package input
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type nmapRun struct {
+	Hosts []struct {
+		Status struct {
+			State string `xml:"state,attr"`
+		} `xml:"status"`
+		Addresses []struct {
+			Addr     string `xml:"addr,attr"`
+			AddrType string `xml:"addrtype,attr"`
+		} `xml:"address"`
+		Hostnames []struct {
+			Name string `xml:"name,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"hostnames>hostname"`
+		Ports []struct {
+			Protocol string `xml:"protocol,attr"`
+			PortID   string `xml:"portid,attr"`
+			State    struct {
+				State string `xml:"state,attr"`
+			} `xml:"state"`
+			Service struct {
+				Name   string `xml:"name,attr"`
+				Tunnel string `xml:"tunnel,attr"`
+			} `xml:"service"`
+		} `xml:"ports>port"`
+	} `xml:"host"`
+}
+
+// addNmap reads the open ports of a nmap xml output (-oX)
+func (p *Provider) addNmap(r io.Reader) error {
+	var run nmapRun
+	if err := xml.NewDecoder(r).Decode(&run); err != nil {
+		return err
+	}
+	for _, host := range run.Hosts {
+		if host.Status.State != "" && host.Status.State != "up" {
+			continue
+		}
+		var addr string
+		for _, address := range host.Addresses {
+			if address.AddrType == "ipv4" || address.AddrType == "ipv6" {
+				addr = address.Addr
+				break
+			}
+		}
+		// prefer the hostname given by the user, it matters for virtual hosts
+		for _, hostname := range host.Hostnames {
+			if hostname.Type == "user" {
+				addr = hostname.Name
+				break
+			}
+		}
+		if addr == "" {
+			continue
+		}
+		for _, port := range host.Ports {
+			if port.State.State != "open" || (port.Protocol != "" && port.Protocol != "tcp") {
+				continue
+			}
+			p.Add(NewInput(addr+":"+port.PortID, serviceScheme(port.Service.Name, port.Service.Tunnel, port.PortID), addr, port.PortID))
+		}
+	}
+	return nil
+}
+
+type masscanRecord struct {
+	IP    string `json:"ip"`
+	Ports []struct {
+		Port    int    `json:"port"`
+		Proto   string `json:"proto"`
+		Status  string `json:"status"`
+		Service struct {
+			Name string `json:"name"`
+		} `json:"service"`
+	} `json:"ports"`
+}
+
+// addMasscan reads a masscan json output (-oJ), the output is read line by line
+// because masscan writes an invalid json array with trailing commas.
+func (p *Provider) addMasscan(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		line = strings.TrimSuffix(strings.TrimPrefix(line, "["), "]")
+		line = strings.TrimSuffix(strings.TrimSpace(line), ",")
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var record masscanRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			continue
+		}
+		for _, port := range record.Ports {
+			if (port.Status != "" && port.Status != "open") || (port.Proto != "" && port.Proto != "tcp") {
+				continue
+			}
+			portStr := strconv.Itoa(port.Port)
+			p.Add(NewInput(record.IP+":"+portStr, serviceScheme(port.Service.Name, "", portStr), record.IP, portStr))
+		}
+	}
+	return scanner.Err()
+}
+
+// serviceScheme guesses the scheme from a detected service, falling back to the port
+func serviceScheme(service, tunnel, port string) string {
+	service = strings.ToLower(service)
+	switch {
+	case service == "https" || service == "https-alt" || (strings.HasPrefix(service, "http") && tunnel == "ssl"):
+		return SchemeHTTPS
+	case strings.HasPrefix(service, "http"):
+		return SchemeHTTP
+	case service != "" && service != "unknown":
+		return SchemeTCP
+	}
+	return GuessScheme(port)
+}
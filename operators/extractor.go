@@ -5,6 +5,7 @@ import (
 	"github.com/Knetic/govaluate"
 	"github.com/chainreactors/neutron/common"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -43,6 +44,9 @@ type Extractor struct {
 	RegexGroup int `json:"group,omitempty" yaml:"group,omitempty"`
 	// regexCompiled is the compiled variant
 	regexCompiled []*regexp.Regexp
+	// groupNames are the named capture groups of the regexes, e.g. (?P<user>\w+), in order.
+	// Without a group index, every match emits the named groups as key/value pairs on top of the full match.
+	groupNames []string
 
 	// description: |
 	//   kval contains the key-value pairs present in the HTTP response header.
@@ -111,6 +115,26 @@ type Extractor struct {
 	//   - false
 	//   - true
 	CaseInsensitive bool `json:"case-insensitive,omitempty" yaml:"case-insensitive,omitempty"`
+
+	// description: |
+	//   Transform are the dsl functions applied in order to each extracted value, before
+	//   it is used as a dynamic value. A function name is called with the value, an expression
+	//   gets the value as the `value` variable. Named regex groups are transformed one by one.
+	// examples:
+	//   - value: >
+	//       []string{"base64_decode", "json_path(value, 'user.id')"}
+	Transform []string `json:"transform,omitempty" yaml:"transform,omitempty"`
+	// Filter is a dsl predicate on `value`, the values it rejects are dropped
+	Filter string `json:"filter,omitempty" yaml:"filter,omitempty"`
+	// Unique drops the duplicated values produced by the transforms
+	Unique bool `json:"unique,omitempty" yaml:"unique,omitempty"`
+	// First keeps only the first value
+	First bool `json:"first,omitempty" yaml:"first,omitempty"`
+	// Last keeps only the last value
+	Last bool `json:"last,omitempty" yaml:"last,omitempty"`
+
+	transformCompiled []*govaluate.EvaluableExpression
+	filterCompiled    *govaluate.EvaluableExpression
 }
 
 // CompileExtractors performs the initial setup operation on an extractor
@@ -129,6 +153,14 @@ func (e *Extractor) CompileExtractors() error {
 			return fmt.Errorf("could not compile regex: %s", regex)
 		}
 		e.regexCompiled = append(e.regexCompiled, compiled)
+		if e.RegexGroup != 0 {
+			continue
+		}
+		for _, name := range compiled.SubexpNames() {
+			if name != "" && !containsString(e.groupNames, name) {
+				e.groupNames = append(e.groupNames, name)
+			}
+		}
 	}
 	for i, kval := range e.KVal {
 		e.KVal[i] = strings.ToLower(kval)
@@ -147,13 +179,17 @@ func (e *Extractor) CompileExtractors() error {
 	//}
 
 	for _, dslExp := range e.DSL {
-		compiled, err := govaluate.NewEvaluableExpressionWithFunctions(dslExp, common.HelperFunctions)
+		compiled, err := common.CompileExpression(common.ResolvePlaceholders(dslExp))
 		if err != nil {
 			return fmt.Errorf("could not compile dsl expression: %s", dslExp)
 		}
 		e.dslCompiled = append(e.dslCompiled, compiled)
 	}
 
+	if err := e.compileTransforms(); err != nil {
+		return err
+	}
+
 	if e.CaseInsensitive {
 		if e.GetType() != KValExtractor {
 			return fmt.Errorf("case-insensitive flag is supported only for 'kval' extractors (not '%s')", e.Type)
@@ -166,9 +202,30 @@ func (e *Extractor) CompileExtractors() error {
 	return nil
 }
 
+// Extracted is a value extracted by an extractor, Groups are the non empty named groups of a regex match
+type Extracted struct {
+	Value  string
+	Groups map[string]string
+}
+
+// ExtractedSet returns the unique values of the extracted values
+func ExtractedSet(extracted []Extracted) map[string]struct{} {
+	results := make(map[string]struct{}, len(extracted))
+	for _, item := range extracted {
+		results[item.Value] = struct{}{}
+	}
+	return results
+}
+
 // ExtractRegex extracts text from a corpus and returns it
 func (e *Extractor) ExtractRegex(corpus string) map[string]struct{} {
-	results := make(map[string]struct{})
+	return ExtractedSet(e.ExtractRegexValues(corpus))
+}
+
+// ExtractRegexValues extracts text from a corpus and returns it in order of appearance,
+// with the named groups of the matches. The value of a named groups match is the full match.
+func (e *Extractor) ExtractRegexValues(corpus string) []Extracted {
+	results := newExtractResults()
 
 	groupPlusOne := e.RegexGroup + 1
 	for _, regex := range e.regexCompiled {
@@ -178,18 +235,43 @@ func (e *Extractor) ExtractRegex(corpus string) map[string]struct{} {
 			if len(match) < groupPlusOne {
 				continue
 			}
-			matchString := match[e.RegexGroup]
-
-			if _, ok := results[matchString]; !ok {
-				results[matchString] = struct{}{}
+			if len(e.groupNames) == 0 {
+				results.add(Extracted{Value: match[e.RegexGroup]})
+				continue
 			}
+			groups := make(map[string]string)
+			for i, name := range regex.SubexpNames() {
+				if name != "" && match[i] != "" {
+					groups[name] = match[i]
+				}
+			}
+			results.add(Extracted{Value: match[0], Groups: groups})
 		}
 	}
-	return results
+	return results.values
+}
+
+// HasNamedGroups returns true if the regexes emit named groups key/value pairs
+func (e *Extractor) HasNamedGroups() bool {
+	return len(e.groupNames) > 0
+}
+
+func containsString(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
 }
 
 // ExtractKval extracts key value pairs from a data map
 func (e *Extractor) ExtractKval(data map[string]interface{}) map[string]struct{} {
+	return ExtractedSet(e.ExtractKvalValues(data))
+}
+
+// ExtractKvalValues extracts key value pairs from a data map in the order of the keys
+func (e *Extractor) ExtractKvalValues(data map[string]interface{}) []Extracted {
 	if e.CaseInsensitive {
 		inputData := data
 		data = make(map[string]interface{}, len(inputData))
@@ -201,18 +283,15 @@ func (e *Extractor) ExtractKval(data map[string]interface{}) map[string]struct{}
 		}
 	}
 
-	results := make(map[string]struct{})
+	results := newExtractResults()
 	for _, k := range e.KVal {
 		item, ok := data[k]
 		if !ok {
 			continue
 		}
-		itemString := common.ToString(item)
-		if _, ok := results[itemString]; !ok {
-			results[itemString] = struct{}{}
-		}
+		results.add(Extracted{Value: common.ToString(item)})
 	}
-	return results
+	return results.values
 }
 
 //// ExtractXPath extracts items from text using XPath selectors
@@ -320,22 +399,57 @@ func (e *Extractor) ExtractKval(data map[string]interface{}) map[string]struct{}
 
 // ExtractDSL execute the expression and returns the results
 func (e *Extractor) ExtractDSL(data map[string]interface{}) map[string]struct{} {
-	results := make(map[string]struct{})
+	return ExtractedSet(e.ExtractDSLValues(data))
+}
+
+// ExtractDSLValues executes the expressions and returns the results in the order of the expressions
+func (e *Extractor) ExtractDSLValues(data map[string]interface{}) []Extracted {
+	results := newExtractResults()
 
 	for _, compiledExpression := range e.dslCompiled {
 		result, err := compiledExpression.Evaluate(data)
 		// ignore errors that are related to missing parameters
 		// eg: dns dsl can have all the parameters that are not present
 		if err != nil && !strings.HasPrefix(err.Error(), "No parameter") {
-			return results
+			return results.values
 		}
 
 		if result != nil {
 			resultString := fmt.Sprint(result)
 			if resultString != "" {
-				results[resultString] = struct{}{}
+				results.add(Extracted{Value: resultString})
 			}
 		}
 	}
-	return results
+	return results.values
+}
+
+// extractResults are the unique extracted values in order of extraction
+type extractResults struct {
+	values []Extracted
+	unique map[string]struct{}
+}
+
+func newExtractResults() *extractResults {
+	return &extractResults{unique: make(map[string]struct{})}
+}
+
+func (r *extractResults) add(value Extracted) {
+	key := value.Value
+	if len(value.Groups) > 0 {
+		// matches of several regexes may share the value with other groups
+		names := make([]string, 0, len(value.Groups))
+		for name := range value.Groups {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			key += "\x00" + name + "\x00" + value.Groups[name]
+		}
+	}
+	if _, ok := r.unique[key]; ok {
+		return
+	}
+	r.unique[key] = struct{}{}
+	r.values = append(r.values, value)
 }
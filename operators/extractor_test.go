@@ -0,0 +1,108 @@
+package operators
+
+import (
+	"reflect"
+	"testing"
+)
+
+func extractBody(data map[string]interface{}, extractor *Extractor) []Extracted {
+	return extractor.ExtractRegexValues(data["body"].(string))
+}
+
+func TestExtractNamedGroups(t *testing.T) {
+	body := "token=abc user=admin; token=def; key=a&b=c+d code=403"
+	tests := []struct {
+		name      string
+		extractor *Extractor
+		// value is the value of the extractor name in the data
+		value interface{}
+		// output are the extracts printed for non internal extractors
+		output []string
+		pairs  map[string]interface{}
+	}{
+		{
+			name:      "named groups",
+			extractor: &Extractor{Name: "session", Type: "regex", Regex: []string{`token=(?P<tok>\w+)(?: user=(?P<user>\w+))?`}},
+			value:     []string{"token=abc user=admin", "token=def"},
+			output:    []string{"token=abc user=admin", "token=def"},
+			pairs:     map[string]interface{}{"tok": "def", "user": "admin"},
+		},
+		{
+			name:      "group index",
+			extractor: &Extractor{Name: "session", Type: "regex", Regex: []string{`token=(?P<tok>\w+)`}, RegexGroup: 1},
+			value:     []string{"abc", "def"},
+			output:    []string{"abc", "def"},
+		},
+		{
+			name:      "transform",
+			extractor: &Extractor{Name: "session", Type: "regex", Regex: []string{`token=(?P<tok>\w+)`}, Transform: []string{"to_upper(value)"}, First: true},
+			value:     "TOKEN=ABC",
+			output:    []string{"TOKEN=ABC"},
+			pairs:     map[string]interface{}{"tok": "ABC"},
+		},
+		{
+			name:      "special characters",
+			extractor: &Extractor{Name: "session", Type: "regex", Regex: []string{`key=(?P<key>\S+)`}},
+			value:     "key=a&b=c+d",
+			output:    []string{"key=a&b=c+d"},
+			pairs:     map[string]interface{}{"key": "a&b=c+d"},
+		},
+		{
+			// the groups don't overwrite the variables of the event
+			name:      "event variables",
+			extractor: &Extractor{Name: "session", Type: "regex", Regex: []string{`(?P<body>co)de=(?P<status_code>\d+)`}},
+			value:     "code=403",
+			output:    []string{"code=403"},
+			pairs:     map[string]interface{}{"status_code": 200, "body": body},
+		},
+	}
+	for _, test := range tests {
+		for _, internal := range []bool{false, true} {
+			extractor := *test.extractor
+			extractor.Internal = internal
+			ops := &Operators{Extractors: []*Extractor{&extractor}}
+			if err := ops.Compile(); err != nil {
+				t.Fatalf("%s: %s", test.name, err)
+			}
+
+			data := map[string]interface{}{"body": body, "status_code": 200}
+			result, _ := ops.ExecuteValues(data, matchBody, extractBody)
+			if result == nil {
+				t.Fatalf("%s: expected a result", test.name)
+			}
+			if !reflect.DeepEqual(data["session"], test.value) {
+				t.Errorf("%s (internal %v): expected session %v, got %v", test.name, internal, test.value, data["session"])
+			}
+			for key, value := range test.pairs {
+				if data[key] != value {
+					t.Errorf("%s (internal %v): expected %s %v, got %v", test.name, internal, key, value, data[key])
+				}
+			}
+			if internal {
+				if dynamic := result.DynamicValues["session"]; !reflect.DeepEqual(dynamic, test.output) {
+					t.Errorf("%s: expected dynamic values %v, got %v", test.name, test.output, dynamic)
+				}
+				if len(result.OutputExtracts) > 0 || len(result.StructuredExtracts) > 0 {
+					t.Errorf("%s: internal extractors don't output, got %v", test.name, result.OutputExtracts)
+				}
+
+				dynamic := ops.ExecuteInternalExtractorsValues(map[string]interface{}{"body": body, "status_code": 200}, extractBody)
+				if dynamic["session"] != test.output[0] {
+					t.Errorf("%s: expected the internal session %v, got %v", test.name, test.output[0], dynamic["session"])
+				}
+				for key := range test.pairs {
+					if _, ok := dynamic[key]; ok == (key == "body" || key == "status_code") {
+						t.Errorf("%s: unexpected internal named groups %v", test.name, dynamic)
+					}
+				}
+				continue
+			}
+			if !reflect.DeepEqual(result.OutputExtracts, test.output) {
+				t.Errorf("%s: expected output %v, got %v", test.name, test.output, result.OutputExtracts)
+			}
+			if (len(result.StructuredExtracts) > 0) != (test.pairs != nil) {
+				t.Errorf("%s: unexpected structured extracts %v", test.name, result.StructuredExtracts)
+			}
+		}
+	}
+}
@@ -28,30 +28,51 @@ type Matcher struct {
 	Name string `json:"name,omitempty" yaml:"name,omitempty"`
 	// Status are the acceptable status codes for the response
 	Status []int `json:"status,omitempty" yaml:"status,omitempty"`
+	// StatusRanges are acceptable status code ranges, e.g. 200-299, >=500 or !404
+	StatusRanges Ranges `json:"status-ranges,omitempty" yaml:"status-ranges,omitempty"`
 	// Size is the acceptable size for the response
 	Size []int `json:"size,omitempty" yaml:"size,omitempty"`
+	// SizeRanges are acceptable size ranges, e.g. 100-200 or >1000
+	SizeRanges Ranges `json:"size-ranges,omitempty" yaml:"size-ranges,omitempty"`
 	// Words are the words required to be present in the response
 	Words []string `json:"words,omitempty" yaml:"words,omitempty"`
 	// Regex are the regex pattern required to be present in the response
 	Regex []string `json:"regex,omitempty" yaml:"regex,omitempty"`
 	// Binary are the binary characters required to be present in the response
 	Binary []string `json:"binary,omitempty" yaml:"binary,omitempty"`
+	// NegateItems makes a word, regex or binary starting with ! required to be absent,
+	// a leading \! is a literal !. Without it the items are matched as written.
+	NegateItems bool `json:"negate-items,omitempty" yaml:"negate-items,omitempty"`
 	// DSL are the dsl queries
 	DSL []string `json:"dsl,omitempty" yaml:"dsl,omitempty"`
+	// Compare is the part of another response the part is compared to, e.g. body_1.
+	// Index the part too (part: body_2) so the matcher only runs once both responses exist.
+	Compare string `json:"compare,omitempty" yaml:"compare,omitempty"`
+	// Algorithm is the similarity algorithm, simhash (default), levenshtein or lines
+	Algorithm string `json:"algorithm,omitempty" yaml:"algorithm,omitempty"`
+	// Threshold is the minimum similarity ratio between 0 and 1 for the similarity matcher
+	Threshold float64 `json:"threshold,omitempty" yaml:"threshold,omitempty"`
 	// Encoding specifies the encoding for the word content if any.
+	// hex, base64 and url words are decoded, utf16 words are matched as utf-16le.
 	Encoding string `json:"encoding,omitempty" yaml:"encoding,omitempty"`
 	// description: |
 	//   MatchAll enables matching for all matcher values. Default is false.
 	// values:
 	//   - false
 	//   - true
-	MatchAll        bool `yaml:"match-all,omitempty" json:"match-all,omitempty" `
+	MatchAll bool `yaml:"match-all,omitempty" json:"match-all,omitempty" `
+	// CaseInsensitive enables case-insensitive matching for word, regex and binary matchers
 	CaseInsensitive bool `yaml:"case-insensitive,omitempty" json:"case-insensitive,omitempty" `
 	condition       ConditionType
 	matcherType     MatcherType
+	wordsCompiled   []string
 	regexCompiled   []*regexp.Regexp
 	dslCompiled     []*govaluate.EvaluableExpression
 	binaryDecoded   []string
+	statusRanges    []numberRange
+	sizeRanges      []numberRange
+	// negated tells which words, regexes or binaries are negated
+	negated []bool
 }
 
 // Result reverts the results of the match if the matcher is of type negative.
@@ -79,15 +100,6 @@ func (m *Matcher) GetType() MatcherType {
 func (m *Matcher) CompileMatchers() error {
 	var ok bool
 
-	// Support hexadecimal encoding for matchers too.
-	if m.Encoding == "hex" {
-		for i, word := range m.Words {
-			if decoded, err := hex.DecodeString(word); err == nil && len(decoded) > 0 {
-				m.Words[i] = string(decoded)
-			}
-		}
-	}
-
 	// Setup the matcher type
 	m.matcherType, ok = matcherTypes[m.Type]
 	if !ok {
@@ -97,34 +109,100 @@ func (m *Matcher) CompileMatchers() error {
 	if m.Part == "" {
 		m.Part = "body"
 	}
+	if m.CaseInsensitive {
+		switch m.GetType() {
+		case WordsMatcher, RegexMatcher, BinaryMatcher:
+		default:
+			return fmt.Errorf("case-insensitive flag is supported only for 'word', 'regex' and 'binary' matchers (not '%s')", m.Type)
+		}
+	}
+	if m.Encoding != "" {
+		if _, ok := wordEncodings[m.Encoding]; !ok {
+			return fmt.Errorf("unknown encoding specified: %s", m.Encoding)
+		}
+	}
+	m.negated = m.negated[:0]
+
+	var err error
+	if m.statusRanges, err = compileRanges(m.Status, m.StatusRanges); err != nil {
+		return err
+	}
+	if m.sizeRanges, err = compileRanges(m.Size, m.SizeRanges); err != nil {
+		return err
+	}
+
+	// Decode the words, hex, base64, url or utf16
+	m.wordsCompiled = m.wordsCompiled[:0]
+	for _, word := range m.Words {
+		word, negated := m.splitNegation(word)
+		decoded, err := decodeWord(word, m.Encoding)
+		if err != nil {
+			return err
+		}
+		if m.CaseInsensitive {
+			decoded = strings.ToLower(decoded)
+		}
+		m.wordsCompiled = append(m.wordsCompiled, decoded)
+		m.negated = append(m.negated, negated)
+	}
 
 	// Compile the regexes
+	m.regexCompiled = m.regexCompiled[:0]
 	for _, regex := range m.Regex {
-		compiled, err := regexp.Compile(regex)
+		pattern, negated := m.splitNegation(regex)
+		if m.CaseInsensitive {
+			pattern = "(?i)" + pattern
+		}
+		compiled, err := regexp.Compile(pattern)
 		if err != nil {
 			return fmt.Errorf("could not Compile regex: %s", regex)
 		}
 		m.regexCompiled = append(m.regexCompiled, compiled)
+		m.negated = append(m.negated, negated)
 	}
 
 	// Compile and validate binary Values in matcher
+	m.binaryDecoded = m.binaryDecoded[:0]
 	for _, value := range m.Binary {
-		if decoded, err := hex.DecodeString(value); err != nil {
+		value, negated := m.splitNegation(value)
+		decoded, err := hex.DecodeString(value)
+		if err != nil {
 			return fmt.Errorf("could not hex decode binary: %s", value)
-		} else {
-			m.binaryDecoded = append(m.binaryDecoded, string(decoded))
 		}
+		if m.CaseInsensitive {
+			decoded = asciiLower(decoded)
+		}
+		m.binaryDecoded = append(m.binaryDecoded, string(decoded))
+		m.negated = append(m.negated, negated)
 	}
 
-	// Compile the dsl expressions
+	// Compile the dsl expressions, placeholders are resolved through the parameters
 	for _, dslExpression := range m.DSL {
-		compiledExpression, err := govaluate.NewEvaluableExpressionWithFunctions(dslExpression, common.HelperFunctions)
+		compiledExpression, err := common.CompileExpression(common.ResolvePlaceholders(dslExpression))
 		if err != nil {
 			return fmt.Errorf("could not compile dsl expression: %s", dslExpression)
 		}
 		m.dslCompiled = append(m.dslCompiled, compiledExpression)
 	}
 
+	if m.GetType() == SimilarityMatcher {
+		if m.Compare == "" {
+			return fmt.Errorf("similarity matcher requires a compare part")
+		}
+		if m.Compare == m.Part {
+			return fmt.Errorf("similarity matcher compares %s with itself", m.Part)
+		}
+		if m.Algorithm == "" {
+			m.Algorithm = SimHashAlgorithm
+		}
+		if _, ok := similarityAlgorithms[m.Algorithm]; !ok {
+			return fmt.Errorf("unknown similarity algorithm specified: %s", m.Algorithm)
+		}
+		if m.Threshold <= 0 || m.Threshold > 1 {
+			return fmt.Errorf("similarity threshold must be between 0 and 1, got %v", m.Threshold)
+		}
+	}
+
 	// Setup the condition type, if any.
 	if m.Condition != "" {
 		m.condition, ok = conditionTypes[m.Condition]
@@ -134,45 +212,27 @@ func (m *Matcher) CompileMatchers() error {
 	} else {
 		m.condition = ORCondition
 	}
-	if m.CaseInsensitive {
-		if m.GetType() != WordsMatcher {
-			return fmt.Errorf("case-insensitive flag is supported only for 'word' matchers (not '%s')", m.Type)
-		}
-		for i := range m.Words {
-			m.Words[i] = strings.ToLower(m.Words[i])
-		}
-	}
 	return nil
 }
 
 // MatchStatusCode matches a status code check against a corpus
 func (m *Matcher) MatchStatusCode(statusCode int) bool {
-	// Iterate over all the status codes accepted as valid
-	//
 	// Status codes don't support AND conditions.
-	for _, status := range m.Status {
-		// Continue if the status codes don't match
-		if statusCode != status {
-			continue
-		}
-		// Return on the first match.
-		return true
-	}
-	return false
+	return matchRanges(m.statusRanges, statusCode)
 }
 
 // MatchSize matches a size check against a corpus
 func (m *Matcher) MatchSize(length int) bool {
-	// Iterate over all the sizes accepted as valid
-	//
 	// Sizes codes don't support AND conditions.
-	for _, size := range m.Size {
-		// Continue if the size doesn't match
-		if length != size {
-			continue
-		}
+	return matchRanges(m.sizeRanges, length)
+}
+
+func matchRanges(ranges []numberRange, value int) bool {
+	for _, r := range ranges {
 		// Return on the first match.
-		return true
+		if r.match(value) {
+			return true
+		}
 	}
 	return false
 }
@@ -182,114 +242,69 @@ func (matcher *Matcher) MatchWords(corpus string, data map[string]interface{}) (
 	if matcher.CaseInsensitive {
 		corpus = strings.ToLower(corpus)
 	}
-
-	var matchedWords []string
-	// Iterate over all the words accepted as valid
-	for i, word := range matcher.Words {
-		if data == nil {
-			data = make(map[string]interface{})
-		}
-
-		var err error
-		word, err = common.Evaluate(word, data)
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	return matcher.matchItems(len(matcher.wordsCompiled), func(i int) (bool, []string) {
+		word, err := common.Evaluate(matcher.wordsCompiled[i], data)
 		if err != nil {
 			common.NeutronLog.Warnf("Error while evaluating word matcher: %q", word)
-			if matcher.condition == ANDCondition {
-				return false, []string{}
-			}
+			return false, nil
 		}
-		// Continue if the word doesn't match
-		if !strings.Contains(corpus, word) {
-			// If we are in an AND request and a match failed,
-			// return false as the AND condition fails on any single mismatch.
-			switch matcher.condition {
-			case ANDCondition:
-				return false, []string{}
-			case ORCondition:
-				continue
-			}
-		}
-
-		// If the condition was an OR, return on the first match.
-		if matcher.condition == ORCondition && !matcher.MatchAll {
-			return true, []string{word}
-		}
-		matchedWords = append(matchedWords, word)
-
-		// If we are at the end of the words, return with true
-		if len(matcher.Words)-1 == i && !matcher.MatchAll {
-			return true, matchedWords
-		}
-	}
-	if len(matchedWords) > 0 && matcher.MatchAll {
-		return true, matchedWords
-	}
-	return false, []string{}
+		return strings.Contains(corpus, word), []string{word}
+	})
 }
 
 // MatchRegex matches a regex check against a corpus
 func (matcher *Matcher) MatchRegex(corpus string) (bool, []string) {
-	var matchedRegexes []string
-	// Iterate over all the regexes accepted as valid
-	for i, regex := range matcher.regexCompiled {
-		// Continue if the regex doesn't match
+	return matcher.matchItems(len(matcher.regexCompiled), func(i int) (bool, []string) {
+		regex := matcher.regexCompiled[i]
 		if !regex.MatchString(corpus) {
-			// If we are in an AND request and a match failed,
-			// return false as the AND condition fails on any single mismatch.
-			switch matcher.condition {
-			case ANDCondition:
-				return false, []string{}
-			case ORCondition:
-				continue
-			}
-		}
-
-		currentMatches := regex.FindAllString(corpus, -1)
-		// If the condition was an OR, return on the first match.
-		if matcher.condition == ORCondition && !matcher.MatchAll {
-			return true, currentMatches
-		}
-
-		matchedRegexes = append(matchedRegexes, currentMatches...)
-
-		// If we are at the end of the regex, return with true
-		if len(matcher.regexCompiled)-1 == i && !matcher.MatchAll {
-			return true, matchedRegexes
+			return false, nil
 		}
-	}
-	if len(matchedRegexes) > 0 && matcher.MatchAll {
-		return true, matchedRegexes
-	}
-	return false, []string{}
+		return true, regex.FindAllString(corpus, -1)
+	})
 }
 
 // MatchBinary matches a binary check against a corpus
 func (m *Matcher) MatchBinary(corpus string) (bool, []string) {
-	var matchedBinary []string
-	// Iterate over all the words accepted as valid
-	for i, binary := range m.binaryDecoded {
-		if !strings.Contains(corpus, binary) {
+	if m.CaseInsensitive {
+		corpus = string(asciiLower([]byte(corpus)))
+	}
+	return m.matchItems(len(m.binaryDecoded), func(i int) (bool, []string) {
+		return strings.Contains(corpus, m.binaryDecoded[i]), []string{m.binaryDecoded[i]}
+	})
+}
+
+// matchItems applies the condition to the words, regexes or binaries, find tells if the item i
+// is in the corpus and returns the matched snippets. A negated item is satisfied if it is not found.
+func (m *Matcher) matchItems(count int, find func(i int) (bool, []string)) (bool, []string) {
+	var matched []string
+	var satisfied int
+	for i := 0; i < count; i++ {
+		found, snippets := find(i)
+		negated := i < len(m.negated) && m.negated[i]
+		if found == negated {
 			// If we are in an AND request and a match failed,
 			// return false as the AND condition fails on any single mismatch.
-			switch m.condition {
-			case ANDCondition:
+			if m.condition == ANDCondition {
 				return false, []string{}
-			case ORCondition:
-				continue
 			}
+			continue
 		}
-
-		// If the condition was an OR, return on the first match.
-		if m.condition == ORCondition {
-			return true, []string{binary}
+		if negated {
+			snippets = nil
 		}
+		satisfied++
 
-		matchedBinary = append(matchedBinary, binary)
-
-		// If we are at the end of the words, return with true
-		if len(m.Binary)-1 == i {
-			return true, matchedBinary
+		// If the condition was an OR, return on the first match.
+		if m.condition == ORCondition && !m.MatchAll {
+			return true, append([]string{}, snippets...)
 		}
+		matched = append(matched, snippets...)
+	}
+	if satisfied > 0 && (m.condition == ANDCondition || m.MatchAll) {
+		return true, matched
 	}
 	return false, []string{}
 }
@@ -299,17 +314,6 @@ func (m *Matcher) MatchDSL(data map[string]interface{}) bool {
 
 	// Iterate over all the expressions accepted as valid
 	for i, expression := range m.dslCompiled {
-		resolvedExpression, err := common.Evaluate(expression.String(), data)
-		if err != nil {
-			common.NeutronLog.Errorf(m.Name, err)
-			return false
-		}
-		expression, err = govaluate.NewEvaluableExpressionWithFunctions(resolvedExpression, common.HelperFunctions)
-		if err != nil {
-			common.NeutronLog.Errorf(m.Name, err)
-			return false
-		}
-
 		result, err := expression.Evaluate(data)
 		if err != nil {
 			if m.condition == ANDCondition {
@@ -344,3 +348,8 @@ func (m *Matcher) MatchDSL(data map[string]interface{}) bool {
 	}
 	return false
 }
+
+// MatchSimilarity matches if the similarity ratio of the corpus and the compared corpus reaches the threshold
+func (m *Matcher) MatchSimilarity(corpus, compared string) bool {
+	return similarityAlgorithms[m.Algorithm](corpus, compared) >= m.Threshold
+}
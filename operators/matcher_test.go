@@ -0,0 +1,253 @@
+package operators
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Knetic/govaluate"
+	"github.com/chainreactors/neutron/common"
+	"github.com/chainreactors/neutron/common/dsl"
+)
+
+var dslData = map[string]interface{}{
+	"body":        "<html><title>admin panel</title> welcome admin</html>",
+	"status_code": 200,
+	"username":    "admin",
+	"code":        200,
+	"code_text":   "200",
+	"template-id": "admin-panel",
+}
+
+func newDSLMatcher(tb testing.TB, expressions ...string) *Matcher {
+	m := &Matcher{Type: "dsl", DSL: expressions}
+	if err := m.CompileMatchers(); err != nil {
+		tb.Fatal(err)
+	}
+	return m
+}
+
+func TestMatchDSLPlaceholders(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   bool
+	}{
+		{`contains(body, "welcome {{username}}")`, true},
+		{`contains(body, 'welcome {{username}}')`, true},
+		{`contains(body, "welcome {{to_upper(username)}}")`, false},
+		{`status_code == {{code}}`, true},
+		// a string payload is a number outside of a string literal, as when it was replaced by its text
+		{`status_code == {{code_text}}`, true},
+		{`{{code_text}} + 1 == 201`, true},
+		{`"{{code_text}}" == "200"`, true},
+		{`{{username}} == "admin"`, true},
+		{`{{template-id}} == "admin-panel"`, true},
+		{`"{{template-id}}" == "admin-panel"`, true},
+		{`contains(body, "{{missing}}")`, false},
+		{`contains(body, "\"{{username}}\"")`, false},
+	}
+	for _, test := range tests {
+		m := newDSLMatcher(t, test.expression)
+		if got := m.MatchDSL(dslData); got != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.expression, test.expected, got)
+		}
+	}
+}
+
+func TestRegisterFunction(t *testing.T) {
+	expression := `acme_reverse(username) == "nimda"`
+	if _, err := common.CompileExpression(expression); err == nil {
+		t.Fatal("expected an unknown function error before registration")
+	}
+
+	var calls int
+	err := dsl.RegisterFunction(dsl.NewWithPositionalArgs("acme_reverse", 1, true, func(args ...interface{}) (interface{}, error) {
+		calls++
+		runes := []rune(args[0].(string))
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return string(runes), nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dsl.RegisterFunction(dsl.NewWithPositionalArgs("acme_reverse", 1, false, nil)); err == nil {
+		t.Error("expected an error registering a function without implementation")
+	}
+	if err := dsl.RegisterFunction(dsl.NewWithPositionalArgs("base64", 1, false, func(args ...interface{}) (interface{}, error) {
+		return nil, nil
+	})); err == nil {
+		t.Error("expected a duplicate function error")
+	}
+
+	m := newDSLMatcher(t, expression)
+	if !m.MatchDSL(dslData) || !m.MatchDSL(dslData) {
+		t.Error("expected the registered function to match")
+	}
+	if calls != 1 {
+		t.Errorf("expected the cacheable function to be called once, got %d", calls)
+	}
+	if out, err := common.Evaluate("{{acme_reverse('abc')}}", nil); err != nil || out != "cba" {
+		t.Errorf("expected cba, got %q %v", out, err)
+	}
+	if !common.HasFunction("acme_reverse") {
+		t.Error("expected the function to be exposed to common")
+	}
+	var listed bool
+	for _, name := range common.HelperFunctionNames() {
+		listed = listed || name == "acme_reverse"
+	}
+	if !listed {
+		t.Error("expected the function to be listed by common")
+	}
+	// the functions at init are built once
+	if _, ok := common.HelperFunctions["acme_reverse"]; ok {
+		t.Error("expected common.HelperFunctions to be left unchanged")
+	}
+}
+
+const benchmarkExpression = `status_code == 200 && contains(body, "welcome {{username}}")`
+
+// BenchmarkMatchDSL is the per response cost of a dsl matcher compiled once
+func BenchmarkMatchDSL(b *testing.B) {
+	m := newDSLMatcher(b, benchmarkExpression)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.MatchDSL(dslData)
+	}
+}
+
+// BenchmarkMatchDSLReparse is the per response cost when the placeholders are replaced
+// and the expression is parsed again on every response
+func BenchmarkMatchDSLReparse(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		resolved, err := common.Evaluate(benchmarkExpression, dslData)
+		if err != nil {
+			b.Fatal(err)
+		}
+		expression, err := govaluate.NewEvaluableExpressionWithFunctions(resolved, common.HelperFunctions)
+		if err != nil {
+			b.Fatal(err)
+		}
+		expression.Evaluate(dslData)
+	}
+}
+
+func compileMatcher(t *testing.T, m *Matcher) *Matcher {
+	if err := m.CompileMatchers(); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMatchRanges(t *testing.T) {
+	m := compileMatcher(t, &Matcher{Type: "status", Status: []int{302}})
+	for status, expected := range map[int]bool{302: true, 200: false} {
+		if got := m.MatchStatusCode(status); got != expected {
+			t.Errorf("status %d: expected %v, got %v", status, expected, got)
+		}
+	}
+	m = compileMatcher(t, &Matcher{Type: "status", Status: []int{500}, StatusRanges: Ranges{"200-299", "!404"}})
+	for status, expected := range map[int]bool{200: true, 204: true, 301: true, 404: false, 500: true} {
+		if got := m.MatchStatusCode(status); got != expected {
+			t.Errorf("status %d: expected %v, got %v", status, expected, got)
+		}
+	}
+	m = compileMatcher(t, &Matcher{Type: "size", SizeRanges: Ranges{">1000", "<=10"}})
+	for size, expected := range map[int]bool{1001: true, 1000: false, 10: true, 11: false} {
+		if got := m.MatchSize(size); got != expected {
+			t.Errorf("size %d: expected %v, got %v", size, expected, got)
+		}
+	}
+
+	var ranges Ranges
+	if err := json.Unmarshal([]byte(`[200, "300-399"]`), &ranges); err != nil || len(ranges) != 2 || ranges[0] != "200" {
+		t.Errorf("unexpected json ranges %v: %v", ranges, err)
+	}
+	if err := (&Matcher{Type: "status", StatusRanges: Ranges{"299-200"}}).CompileMatchers(); err == nil {
+		t.Error("expected an error for an inverted range")
+	}
+}
+
+func TestMatchNegatedItems(t *testing.T) {
+	body := dslData["body"].(string)
+	m := compileMatcher(t, &Matcher{Type: "word", Words: []string{"!admin"}})
+	if ok, _ := m.MatchWords("!admin", nil); !ok {
+		t.Error("expected items to be matched as written without negate-items")
+	}
+	m = compileMatcher(t, &Matcher{Type: "word", Condition: "and", Words: []string{"admin", "!root"}, NegateItems: true})
+	if ok, snippets := m.MatchWords(body, nil); !ok || len(snippets) != 1 || snippets[0] != "admin" {
+		t.Errorf("expected admin without root to match, got %v %v", ok, snippets)
+	}
+	m = compileMatcher(t, &Matcher{Type: "word", Words: []string{"!admin"}, NegateItems: true})
+	if ok, _ := m.MatchWords(body, nil); ok {
+		t.Error("expected !admin not to match")
+	}
+	m = compileMatcher(t, &Matcher{Type: "word", Words: []string{`\!admin`}, NegateItems: true})
+	if ok, _ := m.MatchWords("!admin", nil); !ok {
+		t.Error(`expected \!admin to match a literal !admin`)
+	}
+	m = compileMatcher(t, &Matcher{Type: "regex", Condition: "and", Regex: []string{"WELCOME", "!ROOT"}, CaseInsensitive: true, NegateItems: true})
+	if ok, _ := m.MatchRegex(body); !ok {
+		t.Error("expected the case-insensitive regexes to match")
+	}
+}
+
+func TestMatchBinary(t *testing.T) {
+	m := compileMatcher(t, &Matcher{Type: "binary", Binary: []string{"41444d494e", "77656c636f6d65"}, MatchAll: true, CaseInsensitive: true})
+	ok, snippets := m.MatchBinary(dslData["body"].(string))
+	if !ok || len(snippets) != 2 {
+		t.Errorf("expected both binaries with match-all, got %v %q", ok, snippets)
+	}
+}
+
+func TestMatchEncodings(t *testing.T) {
+	tests := []struct {
+		encoding, word, corpus string
+	}{
+		{"hex", "61646d696e", "admin"},
+		{"base64", "YWRtaW4=", "admin"},
+		{"url", "admin%20panel", "admin panel"},
+		{"utf16", "admin", "a\x00d\x00m\x00i\x00n\x00"},
+	}
+	for _, test := range tests {
+		m := compileMatcher(t, &Matcher{Type: "word", Encoding: test.encoding, Words: []string{test.word}})
+		if ok, _ := m.MatchWords(test.corpus, nil); !ok {
+			t.Errorf("%s: expected %q to match", test.encoding, test.word)
+		}
+	}
+	if err := (&Matcher{Type: "word", Encoding: "rot13", Words: []string{"x"}}).CompileMatchers(); err == nil {
+		t.Error("expected an error for an unknown encoding")
+	}
+}
+
+func TestCompileSimilarity(t *testing.T) {
+	tests := []struct {
+		part, compare string
+		valid         bool
+	}{
+		{"body_2", "body_1", true},
+		{"body", "all_headers", true},
+		// the current response is compared to body_1 from the second request on
+		{"", "body_1", true},
+		{"body", "body_1", true},
+		{"body_1", "body_1", false},
+		{"", "body", false},
+		{"body", "", false},
+	}
+	for _, test := range tests {
+		m := &Matcher{Type: "similarity", Part: test.part, Compare: test.compare, Threshold: 0.9}
+		if err := m.CompileMatchers(); (err == nil) != test.valid {
+			t.Errorf("%s compared to %s: expected valid %v, got %v", test.part, test.compare, test.valid, err)
+		}
+	}
+
+	m := &Matcher{Type: "similarity", Part: "body_2", Compare: "body_1", Algorithm: "lines", Threshold: 0.5}
+	if err := m.CompileMatchers(); err != nil {
+		t.Fatal(err)
+	}
+	if !m.MatchSimilarity("a\nb\nc", "a\nb\nd") || m.MatchSimilarity("a\nb\nc", "x\ny\nz") {
+		t.Error("expected the threshold to apply to the lines similarity")
+	}
+}
@@ -3,6 +3,7 @@ package operators
 import (
 	"fmt"
 	"github.com/chainreactors/neutron/common"
+	"sort"
 	"strconv"
 )
 
@@ -22,6 +23,8 @@ type Operators struct {
 
 	// TemplateID is the ID of the template for matcher
 	TemplateID string
+	// Explain records a Trace of the matchers and extractors in the result
+	Explain bool `json:"-" yaml:"-"`
 }
 
 // Result is a result structure created from operators running on data.
@@ -36,11 +39,17 @@ type Result struct {
 	Extracts map[string][]string
 	// OutputExtracts is the list of extracts to be displayed on screen.
 	OutputExtracts []string
-	outputUnique   map[string]struct{}
+	// StructuredExtracts are the key/value pairs of the named regex groups, one map per match
+	StructuredExtracts []map[string]string
+	outputUnique       map[string]struct{}
+	// groupVariables are the variables of the data set by named groups
+	groupVariables map[string]struct{}
 	// DynamicValues contains any dynamic values to be templated
 	DynamicValues map[string][]string
 	// PayloadValues contains payload values provided by user. (Optional)
 	PayloadValues map[string]interface{}
+	// Trace explains the matchers and extractors, only in explain mode
+	Trace *Trace
 }
 
 func (r *Operators) Compile() error {
@@ -69,17 +78,49 @@ func (r *Operators) GetMatchersCondition() ConditionType {
 
 type matchFunc func(data map[string]interface{}, matcher *Matcher) (bool, []string)
 type extractFunc func(data map[string]interface{}, matcher *Extractor) map[string]struct{}
+type extractValuesFunc func(data map[string]interface{}, matcher *Extractor) []Extracted
 
-// Execute executes the operators on data and returns a result structure
+// extractValues adapts an extractFunc, the values are sorted as the set has no order
+func extractValues(extract extractFunc) extractValuesFunc {
+	if extract == nil {
+		return nil
+	}
+	return func(data map[string]interface{}, extractor *Extractor) []Extracted {
+		set := extract(data, extractor)
+		values := make([]string, 0, len(set))
+		for value := range set {
+			values = append(values, value)
+		}
+		sort.Strings(values)
+		extracted := make([]Extracted, len(values))
+		for i, value := range values {
+			extracted[i] = Extracted{Value: value}
+		}
+		return extracted
+	}
+}
+
+// Execute executes the operators on data and returns a result structure.
+// In explain mode a result carrying the trace is returned even if nothing matched, with false.
 func (operators *Operators) Execute(data map[string]interface{}, match matchFunc, extract extractFunc) (*Result, bool) {
+	return operators.ExecuteValues(data, match, extractValues(extract))
+}
+
+// ExecuteValues is Execute with an extract function returning the values in order, with their named groups
+func (operators *Operators) ExecuteValues(data map[string]interface{}, match matchFunc, extract extractValuesFunc) (*Result, bool) {
 	matcherCondition := operators.GetMatchersCondition()
+	var trace *Trace
+	if operators.Explain {
+		trace = newTrace(operators, data)
+	}
 
 	var matches bool
 	result := &Result{
-		Matches:       make(map[string][]string),
-		Extracts:      make(map[string][]string),
-		DynamicValues: make(map[string][]string),
-		outputUnique:  make(map[string]struct{}),
+		Matches:        make(map[string][]string),
+		Extracts:       make(map[string][]string),
+		DynamicValues:  make(map[string][]string),
+		outputUnique:   make(map[string]struct{}),
+		groupVariables: make(map[string]struct{}),
 	}
 
 	// state variable to check if all extractors are internal
@@ -90,7 +131,9 @@ func (operators *Operators) Execute(data map[string]interface{}, match matchFunc
 			allInternalExtractors = false
 		}
 		var extractorResults []string
-		for match := range extract(data, extractor) {
+		for _, extracted := range extractor.Postprocess(extract(data, extractor), data) {
+			match := extracted.Value
+			result.addNamedGroups(extractor, extracted.Groups, data)
 			extractorResults = append(extractorResults, match)
 
 			if extractor.Internal {
@@ -106,6 +149,9 @@ func (operators *Operators) Execute(data map[string]interface{}, match matchFunc
 				}
 			}
 		}
+		if trace != nil {
+			trace.Extractors = append(trace.Extractors, traceExtractor(extractor, extractorResults))
+		}
 		if len(extractorResults) > 0 && !extractor.Internal && extractor.Name != "" {
 			result.Extracts[extractor.Name] = extractorResults
 		}
@@ -133,8 +179,12 @@ func (operators *Operators) Execute(data map[string]interface{}, match matchFunc
 		data = common.MergeMaps(data, dataDynamicValues)
 	}
 
-	for _, matcher := range operators.Matchers {
-		if isMatch, matched := match(data, matcher); isMatch {
+	for i, matcher := range operators.Matchers {
+		isMatch, matched := match(data, matcher)
+		if trace != nil {
+			trace.Matchers = append(trace.Matchers, traceMatcher(matcher, i, data, match, isMatch, matched))
+		}
+		if isMatch {
 			common.Debug("Matched: %+v", matcher)
 			if matcherCondition == ORCondition && matcher.Name != "" {
 				result.Matches[matcher.Name] = matched
@@ -142,35 +192,87 @@ func (operators *Operators) Execute(data map[string]interface{}, match matchFunc
 			matches = true
 		} else if matcherCondition == ANDCondition {
 			common.Debug("Not Matched: %+v", matcher)
+			if trace != nil {
+				for j, skipped := range operators.Matchers[i+1:] {
+					trace.Matchers = append(trace.Matchers, &MatcherTrace{Name: getMatcherName(skipped, i+1+j), Type: skipped.Type, Skipped: true})
+				}
+			}
 			if len(result.DynamicValues) > 0 {
-				return result, true
+				return result.withTrace(trace), true
 			}
-			return nil, false
+			return explained(trace)
 		} else {
 			common.Debug("Not Matched: %+v", matcher)
 		}
 	}
 
 	result.Matched = matches
-	result.Extracted = len(result.OutputExtracts) > 0
+	result.Extracted = len(result.OutputExtracts) > 0 || len(result.StructuredExtracts) > 0
 	if len(result.DynamicValues) > 0 {
-		return result, true
+		return result.withTrace(trace), true
 	}
 	// Don't print if we have matchers and they have not matched, irregardless of extractor
 	if len(operators.Matchers) > 0 && !matches {
-		return nil, false
+		return explained(trace)
 	}
 	// Write a final string of output if matcher type is
 	// AND or if we have extractors for the mechanism too.
 	if len(result.Extracts) > 0 || len(result.OutputExtracts) > 0 || matches {
-		return result, true
+		return result.withTrace(trace), true
 	}
 
+	if trace != nil {
+		return explained(trace)
+	}
 	return nil, true
 }
 
+func (result *Result) withTrace(trace *Trace) *Result {
+	result.Trace = trace
+	return result
+}
+
+// explained returns the trace of operators that produced nothing
+func explained(trace *Trace) (*Result, bool) {
+	if trace == nil {
+		return nil, false
+	}
+	return &Result{Trace: trace}, false
+}
+
+// addNamedGroups exports every pair as a variable of the data, internal extractors
+// export them as dynamic values too. The extractor name keeps the full match, and
+// the variables of the event, e.g. body or status_code, are never overwritten.
+func (result *Result) addNamedGroups(extractor *Extractor, pairs map[string]string, data map[string]interface{}) {
+	for key, value := range pairs {
+		if key == extractor.Name {
+			continue
+		}
+		if _, ok := result.groupVariables[key]; !ok {
+			if _, ok := data[key]; ok {
+				common.Debug("named group %s of %s skipped, it would overwrite the variable of the event", key, extractor.Name)
+				continue
+			}
+			result.groupVariables[key] = struct{}{}
+		}
+		data[key] = value
+		if extractor.Internal {
+			result.DynamicValues[key] = append(result.DynamicValues[key], value)
+		}
+	}
+	if !extractor.Internal && len(pairs) > 0 {
+		result.StructuredExtracts = append(result.StructuredExtracts, pairs)
+	}
+}
+
 // ExecuteInternalExtractors executes internal dynamic extractors
 func (operators *Operators) ExecuteInternalExtractors(data map[string]interface{}, extract extractFunc) map[string]interface{} {
+	return operators.ExecuteInternalExtractorsValues(data, extractValues(extract))
+}
+
+// ExecuteInternalExtractorsValues is ExecuteInternalExtractors with an extract function returning the values
+// in order, with their named groups. Named groups don't shadow the variables of the data.
+func (operators *Operators) ExecuteInternalExtractorsValues(data map[string]interface{}, extract extractValuesFunc) map[string]interface{} {
 	dynamicValues := make(map[string]interface{})
 
 	// Start with the extractors first and evaluate them.
@@ -178,9 +280,17 @@ func (operators *Operators) ExecuteInternalExtractors(data map[string]interface{
 		if !extractor.Internal {
 			continue
 		}
-		for match := range extract(data, extractor) {
+		for _, extracted := range extractor.Postprocess(extract(data, extractor), data) {
+			for key, value := range extracted.Groups {
+				if _, ok := data[key]; ok {
+					continue
+				}
+				if _, ok := dynamicValues[key]; !ok && key != extractor.Name {
+					dynamicValues[key] = value
+				}
+			}
 			if _, ok := dynamicValues[extractor.Name]; !ok {
-				dynamicValues[extractor.Name] = match
+				dynamicValues[extractor.Name] = extracted.Value
 			}
 		}
 	}
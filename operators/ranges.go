@@ -0,0 +1,171 @@
+package operators
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Ranges are the status-ranges and size-ranges of the matchers, as numbers (200), ranges (200-299)
+// or comparisons (>1000, >=1000, <100, <=100). A leading ! negates the value, e.g. !404.
+type Ranges []string
+
+// UnmarshalJSON accepts numbers as well as strings
+func (r *Ranges) UnmarshalJSON(data []byte) error {
+	var values []interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	*r = make(Ranges, 0, len(values))
+	for _, value := range values {
+		switch v := value.(type) {
+		case string:
+			*r = append(*r, v)
+		case float64:
+			*r = append(*r, strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			return fmt.Errorf("invalid range value: %v", value)
+		}
+	}
+	return nil
+}
+
+// numberRange is a compiled range, bounds included
+type numberRange struct {
+	min, max int
+	negative bool
+}
+
+func (r numberRange) match(value int) bool {
+	return (value >= r.min && value <= r.max) != r.negative
+}
+
+// compileRanges compiles the exact values, then the ranges
+func compileRanges(values []int, ranges Ranges) ([]numberRange, error) {
+	compiled := make([]numberRange, 0, len(values)+len(ranges))
+	for _, value := range values {
+		compiled = append(compiled, numberRange{min: value, max: value})
+	}
+	for _, value := range ranges {
+		r, err := parseRange(value)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, r)
+	}
+	return compiled, nil
+}
+
+func parseRange(value string) (numberRange, error) {
+	var r numberRange
+	s := strings.TrimSpace(value)
+	if strings.HasPrefix(s, "!") {
+		r.negative = true
+		s = strings.TrimSpace(s[1:])
+	}
+
+	var err error
+	switch {
+	case strings.HasPrefix(s, ">="):
+		r.min, err = strconv.Atoi(strings.TrimSpace(s[2:]))
+		r.max = math.MaxInt32
+	case strings.HasPrefix(s, "<="):
+		r.max, err = strconv.Atoi(strings.TrimSpace(s[2:]))
+		r.min = math.MinInt32
+	case strings.HasPrefix(s, ">"):
+		r.min, err = strconv.Atoi(strings.TrimSpace(s[1:]))
+		r.min++
+		r.max = math.MaxInt32
+	case strings.HasPrefix(s, "<"):
+		r.max, err = strconv.Atoi(strings.TrimSpace(s[1:]))
+		r.max--
+		r.min = math.MinInt32
+	case strings.Index(s, "-") > 0:
+		bounds := strings.SplitN(s, "-", 2)
+		if r.min, err = strconv.Atoi(strings.TrimSpace(bounds[0])); err == nil {
+			r.max, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+		}
+		if err == nil && r.min > r.max {
+			err = fmt.Errorf("lower bound is greater than the upper bound")
+		}
+	default:
+		r.min, err = strconv.Atoi(s)
+		r.max = r.min
+	}
+	if err != nil {
+		return r, fmt.Errorf("invalid range %q: %s", value, err.Error())
+	}
+	return r, nil
+}
+
+// splitNegation returns the item without its leading ! if the matcher negates items,
+// a literal leading ! is escaped as \!
+func (m *Matcher) splitNegation(item string) (string, bool) {
+	if !m.NegateItems {
+		return item, false
+	}
+	if strings.HasPrefix(item, `\!`) {
+		return item[1:], false
+	}
+	if strings.HasPrefix(item, "!") {
+		return item[1:], true
+	}
+	return item, false
+}
+
+// wordEncodings are the supported encodings of the words
+var wordEncodings = map[string]struct{}{
+	"hex":    {},
+	"base64": {},
+	"url":    {},
+	"utf16":  {},
+}
+
+// decodeWord returns the bytes to match of a word written in the encoding
+func decodeWord(word, encoding string) (string, error) {
+	switch encoding {
+	case "hex":
+		// for backwards compatibility, words that aren't hex are kept as is
+		if decoded, err := hex.DecodeString(word); err == nil && len(decoded) > 0 {
+			return string(decoded), nil
+		}
+	case "base64":
+		decoded, err := base64.StdEncoding.DecodeString(word)
+		if err != nil {
+			return "", fmt.Errorf("could not base64 decode word: %s", word)
+		}
+		return string(decoded), nil
+	case "url":
+		decoded, err := url.PathUnescape(word)
+		if err != nil {
+			return "", fmt.Errorf("could not url decode word: %s", word)
+		}
+		return decoded, nil
+	case "utf16":
+		units := utf16.Encode([]rune(word))
+		encoded := make([]byte, 0, len(units)*2)
+		for _, unit := range units {
+			encoded = append(encoded, byte(unit), byte(unit>>8))
+		}
+		return string(encoded), nil
+	}
+	return word, nil
+}
+
+// asciiLower lowercases the ascii letters only, binary data is kept as is
+func asciiLower(data []byte) []byte {
+	lower := make([]byte, len(data))
+	for i, c := range data {
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		lower[i] = c
+	}
+	return lower
+}
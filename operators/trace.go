@@ -0,0 +1,202 @@
+package operators
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chainreactors/neutron/common"
+)
+
+// Trace explains how the operators ran on a response, it is recorded in explain mode
+type Trace struct {
+	TemplateID string
+	// Matched is the url or address of the response
+	Matched           string
+	MatchersCondition string
+	Matchers          []*MatcherTrace
+	Extractors        []*ExtractorTrace
+}
+
+// MatcherTrace is the outcome of a matcher and of each of its words, regexes, dsl...
+type MatcherTrace struct {
+	Name      string
+	Type      string
+	Part      string
+	Condition string
+	Negative  bool
+	MatchAll  bool
+	// Matched is the result of the matcher, negation applied
+	Matched bool
+	// Skipped is true if an earlier matcher failed the and condition
+	Skipped  bool
+	Items    []*ItemTrace
+	Snippets []string
+}
+
+// ItemTrace is the outcome of a single item of a matcher, before negation
+type ItemTrace struct {
+	Value   string
+	Matched bool
+	// Detail is the dsl result or error
+	Detail string
+}
+
+// ExtractorTrace lists the values of an extractor
+type ExtractorTrace struct {
+	Name   string
+	Type   string
+	Part   string
+	Values []string
+}
+
+func newTrace(operators *Operators, data map[string]interface{}) *Trace {
+	condition := operators.MatchersCondition
+	if condition == "" {
+		condition = "or"
+	}
+	return &Trace{
+		TemplateID:        operators.TemplateID,
+		Matched:           common.ToString(data["matched"]),
+		MatchersCondition: condition,
+	}
+}
+
+// traceMatcher runs every item of the matcher alone to tell which ones matched
+func traceMatcher(matcher *Matcher, index int, data map[string]interface{}, match matchFunc, matched bool, snippets []string) *MatcherTrace {
+	t := &MatcherTrace{
+		Name:      getMatcherName(matcher, index),
+		Type:      matcher.Type,
+		Part:      matcher.Part,
+		Condition: matcher.Condition,
+		Negative:  matcher.Negative,
+		MatchAll:  matcher.MatchAll,
+		Matched:   matched,
+		Snippets:  snippets,
+	}
+	if t.Condition == "" {
+		t.Condition = "or"
+	}
+
+	if matcher.GetType() == DSLMatcher {
+		for i, expression := range matcher.dslCompiled {
+			item := &ItemTrace{Value: matcher.DSL[i]}
+			result, err := expression.Evaluate(data)
+			if err != nil {
+				item.Detail = err.Error()
+			} else if b, ok := result.(bool); ok {
+				item.Matched = b
+			} else {
+				item.Detail = fmt.Sprintf("returned %T %v, not a boolean", result, result)
+			}
+			t.Items = append(t.Items, item)
+		}
+		return t
+	}
+
+	var values []string
+	switch matcher.GetType() {
+	case WordsMatcher:
+		values = matcher.Words
+	case RegexMatcher:
+		values = matcher.Regex
+	case BinaryMatcher:
+		values = matcher.Binary
+	case StatusMatcher:
+		values = rangeValues(matcher.Status, matcher.StatusRanges)
+	case SizeMatcher:
+		values = rangeValues(matcher.Size, matcher.SizeRanges)
+	}
+	for i, value := range values {
+		single := matcher.single(i)
+		ok, _ := match(data, single)
+		t.Items = append(t.Items, &ItemTrace{Value: value, Matched: ok})
+	}
+	return t
+}
+
+// single returns a copy of the matcher with the item i only, without the matcher negation
+func (m *Matcher) single(i int) *Matcher {
+	single := *m
+	single.Negative = false
+	single.MatchAll = false
+	single.condition = ORCondition
+	switch m.GetType() {
+	case WordsMatcher:
+		single.wordsCompiled = m.wordsCompiled[i : i+1]
+		single.negated = m.negated[i : i+1]
+	case RegexMatcher:
+		single.regexCompiled = m.regexCompiled[i : i+1]
+		single.negated = m.negated[i : i+1]
+	case BinaryMatcher:
+		single.binaryDecoded = m.binaryDecoded[i : i+1]
+		single.negated = m.negated[i : i+1]
+	case StatusMatcher:
+		single.statusRanges = m.statusRanges[i : i+1]
+	case SizeMatcher:
+		single.sizeRanges = m.sizeRanges[i : i+1]
+	}
+	return &single
+}
+
+// rangeValues returns the values in the order of compileRanges
+func rangeValues(values []int, ranges Ranges) []string {
+	items := make([]string, 0, len(values)+len(ranges))
+	for _, value := range values {
+		items = append(items, strconv.Itoa(value))
+	}
+	return append(items, ranges...)
+}
+
+func traceExtractor(extractor *Extractor, values []string) *ExtractorTrace {
+	part := extractor.Part
+	if part == "" {
+		part = "body"
+	}
+	return &ExtractorTrace{Name: extractor.Name, Type: extractor.Type, Part: part, Values: values}
+}
+
+func mark(matched bool) string {
+	if matched {
+		return "[+]"
+	}
+	return "[-]"
+}
+
+// String formats the trace for a terminal
+func (t *Trace) String() string {
+	builder := &strings.Builder{}
+	fmt.Fprintf(builder, "%s %s, matchers-condition: %s\n", t.TemplateID, t.Matched, t.MatchersCondition)
+	for _, m := range t.Matchers {
+		if m.Skipped {
+			fmt.Fprintf(builder, "  [ ] matcher %s (%s) skipped, the and condition already failed\n", m.Name, m.Type)
+			continue
+		}
+		options := []string{m.Type, "part: " + m.Part, "condition: " + m.Condition}
+		if m.MatchAll {
+			options = append(options, "match-all")
+		}
+		if m.Negative {
+			options = append(options, "negative")
+		}
+		fmt.Fprintf(builder, "  %s matcher %s (%s)\n", mark(m.Matched), m.Name, strings.Join(options, ", "))
+		for _, item := range m.Items {
+			fmt.Fprintf(builder, "      %s %q", mark(item.Matched), item.Value)
+			if item.Detail != "" {
+				fmt.Fprintf(builder, ": %s", item.Detail)
+			}
+			builder.WriteString("\n")
+		}
+		if len(m.Snippets) > 0 {
+			fmt.Fprintf(builder, "      matched: %q\n", m.Snippets)
+		}
+	}
+	for _, e := range t.Extractors {
+		fmt.Fprintf(builder, "  extractor %s (%s, part: %s): %d values", e.Name, e.Type, e.Part, len(e.Values))
+		if len(e.Values) > 0 {
+			fmt.Fprintf(builder, " %q", e.Values)
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
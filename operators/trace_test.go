@@ -0,0 +1,51 @@
+package operators
+
+import (
+	"strings"
+	"testing"
+)
+
+func matchBody(data map[string]interface{}, matcher *Matcher) (bool, []string) {
+	switch matcher.GetType() {
+	case WordsMatcher:
+		return matcher.ResultWithMatchedSnippet(matcher.MatchWords(data["body"].(string), data))
+	case DSLMatcher:
+		return matcher.Result(matcher.MatchDSL(data)), nil
+	}
+	return false, nil
+}
+
+func TestExecuteExplain(t *testing.T) {
+	ops := &Operators{
+		MatchersCondition: "and",
+		Matchers: []*Matcher{
+			{Type: "word", Condition: "and", Words: []string{"welcome", "root"}},
+			{Type: "dsl", DSL: []string{"status_code == 200"}},
+		},
+		Explain: true,
+	}
+	if err := ops.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	result, ok := ops.Execute(map[string]interface{}{"body": dslData["body"], "status_code": 200}, matchBody, nil)
+	if ok || result == nil || result.Trace == nil {
+		t.Fatalf("expected an unmatched result with a trace, got %v %v", result, ok)
+	}
+	trace := result.Trace
+	if len(trace.Matchers) != 2 || trace.Matchers[0].Matched || !trace.Matchers[1].Skipped {
+		t.Fatalf("unexpected matchers trace:\n%s", trace)
+	}
+	items := trace.Matchers[0].Items
+	if len(items) != 2 || !items[0].Matched || items[1].Matched {
+		t.Fatalf("unexpected items trace:\n%s", trace)
+	}
+	if !strings.Contains(trace.String(), `[-] "root"`) {
+		t.Errorf("trace doesn't explain the missing word:\n%s", trace)
+	}
+
+	ops.Explain = false
+	if result, _ := ops.Execute(map[string]interface{}{"body": dslData["body"], "status_code": 200}, matchBody, nil); result != nil {
+		t.Errorf("expected no result without explain, got %v", result)
+	}
+}
@@ -0,0 +1,161 @@
+package operators
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/chainreactors/neutron/common"
+	"github.com/chainreactors/neutron/common/dsl"
+)
+
+// reFunctionName matches a bare dsl function name, e.g. base64_decode
+var reFunctionName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// TransformExpression returns the dsl expression of a transform, a bare function name is called with
+// the value, e.g. base64_decode => base64_decode(value). The function must accept the value alone.
+func TransformExpression(transform string) (string, error) {
+	if !reFunctionName.MatchString(transform) {
+		return transform, nil
+	}
+	if !common.HasFunction(transform) {
+		return "", fmt.Errorf("unknown transform function: %s", transform)
+	}
+	if err := dsl.CheckArguments(transform, 1); err != nil {
+		return "", fmt.Errorf("invalid transform function: %w", err)
+	}
+	return transform + "(value)", nil
+}
+
+// compileTransforms compiles the post-processing pipeline of the extractor
+func (e *Extractor) compileTransforms() error {
+	if e.First && e.Last {
+		return fmt.Errorf("first and last can't be both set on extractor %s", e.Name)
+	}
+	for _, transform := range e.Transform {
+		expression, err := TransformExpression(transform)
+		if err != nil {
+			return err
+		}
+		compiled, err := common.CompileExpression(common.ResolvePlaceholders(expression))
+		if err != nil {
+			return fmt.Errorf("could not compile transform: %s", transform)
+		}
+		e.transformCompiled = append(e.transformCompiled, compiled)
+	}
+	if e.Filter != "" {
+		compiled, err := common.CompileExpression(common.ResolvePlaceholders(e.Filter))
+		if err != nil {
+			return fmt.Errorf("could not compile filter: %s", e.Filter)
+		}
+		e.filterCompiled = compiled
+	}
+	return nil
+}
+
+// valueParameters exposes the value being processed on top of the event data
+type valueParameters struct {
+	value string
+	data  map[string]interface{}
+}
+
+func (p valueParameters) Get(name string) (interface{}, error) {
+	if name == "value" {
+		return p.value, nil
+	}
+	if value, ok := p.data[name]; ok {
+		return value, nil
+	}
+	return nil, fmt.Errorf("No parameter '%s' found.", name)
+}
+
+// Postprocess applies the transforms, the filter, unique and first/last to the extracted values.
+// Values a transform fails on are dropped.
+func (e *Extractor) Postprocess(values []Extracted, data map[string]interface{}) []Extracted {
+	if len(e.transformCompiled) == 0 && e.filterCompiled == nil && !e.Unique && !e.First && !e.Last {
+		return values
+	}
+	processed := make([]Extracted, 0, len(values))
+	unique := make(map[string]struct{})
+	for _, value := range values {
+		var ok bool
+		if len(value.Groups) > 0 {
+			// the named groups are filtered with their pairs
+			value, ok = e.transformNamedGroups(value, data)
+		} else {
+			value.Value, ok = e.transform(value.Value, data)
+			ok = ok && e.filter(value.Value, data)
+		}
+		if !ok {
+			continue
+		}
+		if e.Unique {
+			if _, ok := unique[value.Value]; ok {
+				continue
+			}
+			unique[value.Value] = struct{}{}
+		}
+		processed = append(processed, value)
+	}
+	if len(processed) == 0 {
+		return processed
+	}
+	if e.First {
+		return processed[:1]
+	}
+	if e.Last {
+		return processed[len(processed)-1:]
+	}
+	return processed
+}
+
+func (e *Extractor) transform(value string, data map[string]interface{}) (string, bool) {
+	for _, transform := range e.transformCompiled {
+		result, err := transform.Eval(valueParameters{value: value, data: data})
+		if err != nil {
+			common.Debug("transform %s of %s failed, %s", transform.String(), e.Name, err.Error())
+			return "", false
+		}
+		value = common.ToString(result)
+	}
+	return value, true
+}
+
+// transformNamedGroups transforms the full match and each pair of a named groups match,
+// the filter gets the pairs as variables
+func (e *Extractor) transformNamedGroups(match Extracted, data map[string]interface{}) (Extracted, bool) {
+	value, ok := e.transform(match.Value, data)
+	if !ok {
+		return Extracted{}, false
+	}
+	pairs := make(map[string]string, len(match.Groups))
+	for key, pair := range match.Groups {
+		transformed, ok := e.transform(pair, data)
+		if !ok {
+			return Extracted{}, false
+		}
+		pairs[key] = transformed
+	}
+	if e.filterCompiled != nil {
+		variables := make(map[string]interface{}, len(pairs))
+		for key, pair := range pairs {
+			variables[key] = pair
+		}
+		if !e.filter(value, common.MergeMaps(data, variables)) {
+			return Extracted{}, false
+		}
+	}
+	return Extracted{Value: value, Groups: pairs}, true
+}
+
+func (e *Extractor) filter(value string, data map[string]interface{}) bool {
+	if e.filterCompiled == nil {
+		return true
+	}
+	result, err := e.filterCompiled.Eval(valueParameters{value: value, data: data})
+	if err != nil {
+		common.Debug("filter of %s failed, %s", e.Name, err.Error())
+		return false
+	}
+	matched, ok := result.(bool)
+	return ok && matched
+}
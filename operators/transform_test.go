@@ -0,0 +1,130 @@
+package operators
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPostprocess(t *testing.T) {
+	data := map[string]interface{}{"username": "admin"}
+	tests := []struct {
+		name      string
+		extractor *Extractor
+		values    []string
+		expected  []string
+	}{
+		{
+			name:      "function name",
+			extractor: &Extractor{Transform: []string{"base64_decode"}},
+			values:    []string{"YWRtaW4=", "Z3Vlc3Q="},
+			expected:  []string{"admin", "guest"},
+		},
+		{
+			name:      "ordered expressions",
+			extractor: &Extractor{Transform: []string{"to_upper(value)", `replace(value, "A", "@")`}},
+			values:    []string{"admin"},
+			expected:  []string{"@DMIN"},
+		},
+		{
+			name:      "event variables",
+			extractor: &Extractor{Transform: []string{`concat(username, ":", value)`}},
+			values:    []string{"secret"},
+			expected:  []string{"admin:secret"},
+		},
+		{
+			name:      "failed transform",
+			extractor: &Extractor{Transform: []string{"value + missing"}},
+			values:    []string{"admin"},
+			expected:  []string{},
+		},
+		{
+			name:      "filter",
+			extractor: &Extractor{Filter: "len(value) > 3 && value != username"},
+			values:    []string{"a", "admin", "guest"},
+			expected:  []string{"guest"},
+		},
+		{
+			name:      "unique",
+			extractor: &Extractor{Transform: []string{"to_lower"}, Unique: true},
+			values:    []string{"a", "A", "b", "a"},
+			expected:  []string{"a", "b"},
+		},
+		{
+			name:      "first",
+			extractor: &Extractor{Filter: `value != "a"`, First: true},
+			values:    []string{"a", "b", "c"},
+			expected:  []string{"b"},
+		},
+		{
+			name:      "last",
+			extractor: &Extractor{Last: true},
+			values:    []string{"a", "b", "c"},
+			expected:  []string{"c"},
+		},
+		{
+			name:      "no values",
+			extractor: &Extractor{First: true},
+			values:    nil,
+			expected:  []string{},
+		},
+		{
+			name:      "no postprocessing",
+			extractor: &Extractor{},
+			values:    []string{"b", "a", "b"},
+			expected:  []string{"b", "a", "b"},
+		},
+	}
+	for _, test := range tests {
+		test.extractor.Type = "kval"
+		if err := test.extractor.CompileExtractors(); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		values := make([]Extracted, len(test.values))
+		for i, value := range test.values {
+			values[i] = Extracted{Value: value}
+		}
+		got := []string{}
+		for _, extracted := range test.extractor.Postprocess(values, data) {
+			got = append(got, extracted.Value)
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestPostprocessNamedGroups(t *testing.T) {
+	extractor := &Extractor{
+		Type:      "regex",
+		Regex:     []string{`(?P<user>\w+):(?P<pass>\w+)`},
+		Transform: []string{"to_upper"},
+		// the filter gets the transformed groups as variables
+		Filter: `user != "GUEST"`,
+	}
+	if err := extractor.CompileExtractors(); err != nil {
+		t.Fatal(err)
+	}
+	var matches []string
+	for _, match := range extractor.Postprocess(extractor.ExtractRegexValues("admin:secret guest:guest root:toor"), nil) {
+		matches = append(matches, match.Value+" "+match.Groups["user"]+" "+match.Groups["pass"])
+	}
+	if expected := []string{"ADMIN:SECRET ADMIN SECRET", "ROOT:TOOR ROOT TOOR"}; !reflect.DeepEqual(matches, expected) {
+		t.Errorf("expected %v, got %v", expected, matches)
+	}
+}
+
+func TestCompileTransforms(t *testing.T) {
+	for _, extractor := range []*Extractor{
+		{Transform: []string{"unknown_function"}},
+		// trim needs a cutset, the value alone can't be trimmed
+		{Transform: []string{"trim"}},
+		{Transform: []string{"to_upper(value"}},
+		{Filter: "len(value) >"},
+		{First: true, Last: true},
+	} {
+		extractor.Type = "kval"
+		if err := extractor.CompileExtractors(); err == nil {
+			t.Errorf("expected a compile error for %+v", extractor)
+		}
+	}
+}
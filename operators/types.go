@@ -1,5 +1,12 @@
 package operators
 
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/chainreactors/neutron/common/dsl"
+)
+
 // ExtractorType is the type of the extractor specified
 type ExtractorType int
 
@@ -55,16 +62,45 @@ const (
 	SizeMatcher
 	// DSLMatcher matches based upon dsl syntax
 	DSLMatcher
+	// SimilarityMatcher matches responses similar to another response
+	SimilarityMatcher
 )
 
 // matcherTypes is an table for conversion of matcher type from string.
 var matcherTypes = map[string]MatcherType{
-	"status": StatusMatcher,
-	"size":   SizeMatcher,
-	"word":   WordsMatcher,
-	"regex":  RegexMatcher,
-	"binary": BinaryMatcher,
-	"dsl":    DSLMatcher,
+	"status":     StatusMatcher,
+	"size":       SizeMatcher,
+	"word":       WordsMatcher,
+	"regex":      RegexMatcher,
+	"binary":     BinaryMatcher,
+	"dsl":        DSLMatcher,
+	"similarity": SimilarityMatcher,
+}
+
+const (
+	SimHashAlgorithm     = "simhash"
+	LevenshteinAlgorithm = "levenshtein"
+	LinesAlgorithm       = "lines"
+)
+
+// similarityAlgorithms is a table of the similarity ratio functions of the similarity matcher
+var similarityAlgorithms = map[string]func(a, b string) float64{
+	SimHashAlgorithm:     dsl.SimHashSimilarity,
+	LevenshteinAlgorithm: dsl.LevenshteinRatio,
+	LinesAlgorithm:       dsl.LineSimilarity,
+}
+
+// reIndexedPart matches the parts of a previous response, e.g. body_1
+var reIndexedPart = regexp.MustCompile(`_(\d+)$`)
+
+// PartIndex returns the index of the request of an indexed part, e.g. 1 for body_1
+func PartIndex(part string) (int, bool) {
+	match := reIndexedPart.FindStringSubmatch(part)
+	if match == nil {
+		return 0, false
+	}
+	index, err := strconv.Atoi(match[1])
+	return index, err == nil
 }
 
 // conditionType is the type of condition for matcher
@@ -0,0 +1,50 @@
+package protocols
+
+import (
+	"fmt"
+	"strings"
+)
+
+// auth types supported by AuthConfig
+const (
+	AuthBasic  = "basic"
+	AuthDigest = "digest"
+	AuthNTLM   = "ntlm"
+	AuthBearer = "bearer"
+)
+
+// AuthConfig is the http authentication of a request, or of a whole scan when set in Options
+type AuthConfig struct {
+	// Type is the authentication scheme: basic, digest, ntlm or bearer
+	Type     string `json:"type" yaml:"type"`
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+	// Domain is the ntlm domain, it can also be given as DOMAIN\user
+	Domain string `json:"domain,omitempty" yaml:"domain,omitempty"`
+	// Token is the static bearer token
+	Token string `json:"token,omitempty" yaml:"token,omitempty"`
+	// TokenProvider returns the bearer token of a target (scheme://host), it is used when Token is empty,
+	// e.g. with a token extracted by a login template
+	TokenProvider func(target string) (string, error) `json:"-" yaml:"-"`
+}
+
+// Compile validates the auth configuration
+func (a *AuthConfig) Compile() error {
+	a.Type = strings.ToLower(a.Type)
+	switch a.Type {
+	case AuthBasic, AuthDigest:
+	case AuthNTLM:
+		if a.Domain == "" {
+			if i := strings.Index(a.Username, `\`); i >= 0 {
+				a.Domain, a.Username = a.Username[:i], a.Username[i+1:]
+			}
+		}
+	case AuthBearer:
+		if a.Token == "" && a.TokenProvider == nil {
+			return fmt.Errorf("bearer auth needs a token or a token provider")
+		}
+	default:
+		return fmt.Errorf("unknown auth type: %s", a.Type)
+	}
+	return nil
+}
@@ -2,6 +2,11 @@ package protocols
 
 import "github.com/chainreactors/neutron/operators"
 
+// valuesExtractor is a request extracting the values in order, with their named groups
+type valuesExtractor interface {
+	ExtractValues(data map[string]interface{}, extractor *operators.Extractor) []operators.Extracted
+}
+
 // CreateEvent wraps the outputEvent with the result of the operators defined on the request
 func CreateEvent(request Request, outputEvent InternalEvent) *InternalWrappedEvent {
 	return CreateEventWithAdditionalOptions(request, outputEvent, nil)
@@ -16,7 +21,13 @@ func CreateEventWithAdditionalOptions(request Request, outputEvent InternalEvent
 	// Dump response variables if ran in debug mode
 	for _, compiledOperator := range request.GetCompiledOperators() {
 		if compiledOperator != nil {
-			result, ok := compiledOperator.Execute(outputEvent, request.Match, request.Extract)
+			var result *operators.Result
+			var ok bool
+			if extractor, isValues := request.(valuesExtractor); isValues {
+				result, ok = compiledOperator.ExecuteValues(outputEvent, request.Match, extractor.ExtractValues)
+			} else {
+				result, ok = compiledOperator.Execute(outputEvent, request.Match, request.Extract)
+			}
 			if ok && result != nil {
 				event.OperatorsResult = result
 				if addAdditionalOptions != nil {
@@ -0,0 +1,104 @@
+package protocols
+
+import (
+	"net/http"
+)
+
+// BeforeRequestHook is called before a http request is sent.
+// It may modify the request (sign it, add headers...), returning an error vetoes the request.
+type BeforeRequestHook func(req *http.Request) error
+
+// AfterResponseHook is called once a http response has been converted into an event.
+// The response body has already been consumed, its content is available as event["body"].
+type AfterResponseHook func(resp *http.Response, event InternalEvent)
+
+// NetworkRequest is the data about to be written on a network connection
+type NetworkRequest struct {
+	// Address is the host:port the data is sent to
+	Address string
+	// TLS is true if the connection is encrypted
+	TLS bool
+	// Data is the data to write, hooks are allowed to replace it
+	Data []byte
+}
+
+// NetworkResponse is the data read from a network connection
+type NetworkResponse struct {
+	// Address is the host:port the data was read from
+	Address string
+	// Data is the full data read from the connection
+	Data []byte
+}
+
+// BeforeNetworkRequestHook is called before each input is written on a network connection.
+// Returning an error vetoes the request.
+type BeforeNetworkRequestHook func(req *NetworkRequest) error
+
+// AfterNetworkResponseHook is called once a network response has been converted into an event.
+type AfterNetworkResponseHook func(resp *NetworkResponse, event InternalEvent)
+
+// AddBeforeRequest appends hooks to the http before request chain
+func (e *ExecuterOptions) AddBeforeRequest(hooks ...BeforeRequestHook) {
+	e.BeforeRequest = append(e.BeforeRequest, hooks...)
+}
+
+// AddAfterResponse appends hooks to the http after response chain
+func (e *ExecuterOptions) AddAfterResponse(hooks ...AfterResponseHook) {
+	e.AfterResponse = append(e.AfterResponse, hooks...)
+}
+
+// AddBeforeNetworkRequest appends hooks to the network before request chain
+func (e *ExecuterOptions) AddBeforeNetworkRequest(hooks ...BeforeNetworkRequestHook) {
+	e.BeforeNetworkRequest = append(e.BeforeNetworkRequest, hooks...)
+}
+
+// AddAfterNetworkResponse appends hooks to the network after response chain
+func (e *ExecuterOptions) AddAfterNetworkResponse(hooks ...AfterNetworkResponseHook) {
+	e.AfterNetworkResponse = append(e.AfterNetworkResponse, hooks...)
+}
+
+// RunBeforeRequest runs the before request chain in order and stops at the first error
+func (e *ExecuterOptions) RunBeforeRequest(req *http.Request) error {
+	if e == nil {
+		return nil
+	}
+	for _, hook := range e.BeforeRequest {
+		if err := hook(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunAfterResponse runs the after response chain in order
+func (e *ExecuterOptions) RunAfterResponse(resp *http.Response, event InternalEvent) {
+	if e == nil {
+		return
+	}
+	for _, hook := range e.AfterResponse {
+		hook(resp, event)
+	}
+}
+
+// RunBeforeNetworkRequest runs the network before request chain in order and stops at the first error
+func (e *ExecuterOptions) RunBeforeNetworkRequest(req *NetworkRequest) error {
+	if e == nil {
+		return nil
+	}
+	for _, hook := range e.BeforeNetworkRequest {
+		if err := hook(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunAfterNetworkResponse runs the network after response chain in order
+func (e *ExecuterOptions) RunAfterNetworkResponse(resp *NetworkResponse, event InternalEvent) {
+	if e == nil {
+		return
+	}
+	for _, hook := range e.AfterNetworkResponse {
+		hook(resp, event)
+	}
+}
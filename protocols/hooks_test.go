@@ -0,0 +1,84 @@
+package protocols
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestHookChains(t *testing.T) {
+	var calls []string
+	vetoed := errors.New("vetoed")
+	options := &ExecuterOptions{}
+	options.AddBeforeRequest(func(req *http.Request) error {
+		calls = append(calls, "before 1")
+		req.Header.Set("X-Signature", "1")
+		return nil
+	}, func(req *http.Request) error {
+		calls = append(calls, "before 2 "+req.Header.Get("X-Signature"))
+		return vetoed
+	})
+	options.AddBeforeRequest(func(req *http.Request) error {
+		calls = append(calls, "before 3")
+		return nil
+	})
+	options.AddAfterResponse(func(resp *http.Response, event InternalEvent) {
+		calls = append(calls, "after 1")
+		event["signed"] = true
+	}, func(resp *http.Response, event InternalEvent) {
+		calls = append(calls, "after 2")
+	})
+
+	req, _ := http.NewRequest("GET", "http://127.0.0.1/", nil)
+	// the chain stops at the first error
+	if err := options.RunBeforeRequest(req); err != vetoed {
+		t.Errorf("expected the veto of the second hook, got %v", err)
+	}
+	event := InternalEvent{}
+	options.RunAfterResponse(&http.Response{}, event)
+	if expected := []string{"before 1", "before 2 1", "after 1", "after 2"}; !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected the calls %v, got %v", expected, calls)
+	}
+	if event["signed"] != true {
+		t.Errorf("expected the after response hook to update the event, got %v", event)
+	}
+
+	calls = nil
+	options.AddBeforeNetworkRequest(func(req *NetworkRequest) error {
+		calls = append(calls, "network before 1")
+		req.Data = append(req.Data, '1')
+		return nil
+	}, func(req *NetworkRequest) error {
+		calls = append(calls, "network before 2 "+string(req.Data))
+		return nil
+	})
+	options.AddAfterNetworkResponse(func(resp *NetworkResponse, event InternalEvent) {
+		calls = append(calls, "network after "+string(resp.Data))
+	})
+	networkRequest := &NetworkRequest{Data: []byte("ping")}
+	if err := options.RunBeforeNetworkRequest(networkRequest); err != nil {
+		t.Fatal(err)
+	}
+	options.RunAfterNetworkResponse(&NetworkResponse{Data: []byte("pong")}, InternalEvent{})
+	if expected := []string{"network before 1", "network before 2 ping1", "network after pong"}; !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected the calls %v, got %v", expected, calls)
+	}
+	options.AddBeforeNetworkRequest(func(req *NetworkRequest) error {
+		return vetoed
+	})
+	if err := options.RunBeforeNetworkRequest(networkRequest); err != vetoed {
+		t.Errorf("expected the network veto, got %v", err)
+	}
+
+	// requests compiled without executer options run no hooks
+	var empty *ExecuterOptions
+	if err := empty.RunBeforeRequest(req); err != nil {
+		t.Error(err)
+	}
+	if err := empty.RunBeforeNetworkRequest(networkRequest); err != nil {
+		t.Error(err)
+	}
+	empty.RunAfterResponse(&http.Response{}, event)
+	empty.RunAfterNetworkResponse(&NetworkResponse{}, event)
+}
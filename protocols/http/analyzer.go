@@ -0,0 +1,271 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chainreactors/neutron/common"
+	"github.com/chainreactors/neutron/operators"
+	"github.com/chainreactors/neutron/protocols"
+)
+
+const (
+	// TimeDelayAnalyzer fits the response time against the injected delay
+	TimeDelayAnalyzer = "time_delay"
+	// sleepTimeMarker is replaced by the delay of every sample in the url, the headers and the body
+	sleepTimeMarker = "[SLEEPTIME]"
+)
+
+// Analyzer re-sends the request with several delays, e.g. `sleep([SLEEPTIME])`, and matches
+// if the response time grows linearly with the injected delay.
+type Analyzer struct {
+	// Name is the analyzer, only time_delay is supported
+	Name string `json:"name" yaml:"name"`
+	// SleepDuration is the largest delay in seconds, default 5
+	SleepDuration int `json:"sleep-duration,omitempty" yaml:"sleep-duration,omitempty"`
+	// RequestsLimit is the number of samples, default 4
+	RequestsLimit int `json:"requests-limit,omitempty" yaml:"requests-limit,omitempty"`
+	// CorrelationErrorRange is the accepted distance of the correlation to 1, default 0.15
+	CorrelationErrorRange float64 `json:"time-correlation-error-range,omitempty" yaml:"time-correlation-error-range,omitempty"`
+	// SlopeErrorRange is the accepted distance of the slope to 1, default 0.3
+	SlopeErrorRange float64 `json:"time-slope-error-range,omitempty" yaml:"time-slope-error-range,omitempty"`
+}
+
+// Compile validates the analyzer and sets the defaults
+func (a *Analyzer) Compile() error {
+	if a.Name != TimeDelayAnalyzer {
+		return fmt.Errorf("unknown analyzer specified: %s", a.Name)
+	}
+	if a.SleepDuration == 0 {
+		a.SleepDuration = 5
+	}
+	if a.RequestsLimit == 0 {
+		a.RequestsLimit = 4
+	}
+	if a.CorrelationErrorRange == 0 {
+		a.CorrelationErrorRange = 0.15
+	}
+	if a.SlopeErrorRange == 0 {
+		a.SlopeErrorRange = 0.3
+	}
+	if a.SleepDuration < 2 || a.RequestsLimit < 2 {
+		return fmt.Errorf("analyzer needs a sleep-duration and a requests-limit of at least 2")
+	}
+	return nil
+}
+
+// delays returns the delay of every sample, large and small delays alternate so that
+// a slowing down server does not look like a correlation
+func (a *Analyzer) delays() []int {
+	sorted := make([]int, a.RequestsLimit)
+	for i := range sorted {
+		sorted[i] = 1 + int(math.Round(float64((a.SleepDuration-1)*i)/float64(a.RequestsLimit-1)))
+	}
+	delays := make([]int, 0, len(sorted))
+	for low, high := 0, len(sorted)-1; low <= high; low, high = low+1, high-1 {
+		delays = append(delays, sorted[high])
+		if low != high {
+			delays = append(delays, sorted[low])
+		}
+	}
+	return delays
+}
+
+// analyzerResult is the linear regression of the response times on the delays
+type analyzerResult struct {
+	matched     bool
+	correlation float64
+	slope       float64
+	intercept   float64
+	confidence  float64
+	delays      []int
+	durations   []float64
+}
+
+// analyze fits durations = slope * delays + intercept
+func (a *Analyzer) analyze(delays []int, durations []float64) *analyzerResult {
+	result := &analyzerResult{delays: delays, durations: durations}
+	n := float64(len(durations))
+	var sumX, sumY float64
+	for i := range durations {
+		sumX += float64(delays[i])
+		sumY += durations[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+	var covariance, varianceX, varianceY float64
+	for i := range durations {
+		dx, dy := float64(delays[i])-meanX, durations[i]-meanY
+		covariance += dx * dy
+		varianceX += dx * dx
+		varianceY += dy * dy
+	}
+	if varianceX == 0 || varianceY == 0 {
+		return result
+	}
+	result.slope = covariance / varianceX
+	result.intercept = meanY - result.slope*meanX
+	result.correlation = covariance / math.Sqrt(varianceX*varianceY)
+	result.confidence = math.Max(0, result.correlation*result.correlation*(1-math.Min(1, math.Abs(result.slope-1))))
+	result.matched = result.correlation >= 1-a.CorrelationErrorRange && math.Abs(result.slope-1) <= a.SlopeErrorRange
+	return result
+}
+
+// toMap returns the analyzer variables, they are reported in the result metadata
+func (result *analyzerResult) toMap() map[string]interface{} {
+	durations := make([]string, len(result.durations))
+	for i, duration := range result.durations {
+		durations[i] = strconv.FormatFloat(duration, 'f', 3, 64)
+	}
+	delays := make([]string, len(result.delays))
+	for i, delay := range result.delays {
+		delays[i] = strconv.Itoa(delay)
+	}
+	return map[string]interface{}{
+		"analyzer":             TimeDelayAnalyzer,
+		"analyzer_matched":     result.matched,
+		"analyzer_correlation": round(result.correlation),
+		"analyzer_slope":       round(result.slope),
+		"analyzer_intercept":   round(result.intercept),
+		"analyzer_confidence":  round(result.confidence),
+		"analyzer_delays":      strings.Join(delays, ","),
+		"analyzer_durations":   strings.Join(durations, ","),
+	}
+}
+
+func round(f float64) float64 {
+	rounded := math.Round(f*1000) / 1000
+	if rounded == 0 {
+		// avoids reporting -0
+		return 0
+	}
+	return rounded
+}
+
+// analyzerMatcher is the matcher added when the request has no matchers, the analyzer alone decides
+func analyzerMatcher() *operators.Matcher {
+	return &operators.Matcher{Type: "dsl", Name: TimeDelayAnalyzer, DSL: []string{"analyzer_matched == true"}}
+}
+
+// execute sends the generated request, through the analyzer if the request has one
+func (r *Request) execute(input *protocols.ScanContext, request *generatedRequest, previousEvent map[string]interface{}, callback protocols.OutputEventCallback, reqcount int) error {
+	if r.Analyzer != nil {
+		return r.executeAnalyzer(input, request, previousEvent, callback, reqcount)
+	}
+	return r.executeRequest(input, request, previousEvent, callback, reqcount)
+}
+
+// executeAnalyzer measures the samples of the analyzer, the sampling stops at the first response
+// faster than its delay. The request is sent once more with the largest delay to run the operators,
+// the analyzer variables are added to its dynamic values.
+func (r *Request) executeAnalyzer(input *protocols.ScanContext, base *generatedRequest, previous map[string]interface{}, callback protocols.OutputEventCallback, reqcount int) error {
+	if base.cancel != nil {
+		base.cancel()
+	}
+	var body []byte
+	if base.request.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(base.request.Body)
+		base.request.Body.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	delays := r.Analyzer.delays()
+	durations := make([]float64, 0, len(delays))
+	for _, delay := range delays {
+		sample, err := r.withDelay(base, body, delay)
+		if err != nil {
+			return err
+		}
+		duration, err := r.measure(input, sample)
+		if err != nil {
+			return err
+		}
+		common.Debug("analyzer %s delay %ds took %.3fs", sample.request.URL, delay, duration)
+		if duration < float64(delay) {
+			return nil
+		}
+		durations = append(durations, duration)
+	}
+	result := r.Analyzer.analyze(delays, durations)
+	if !result.matched {
+		return nil
+	}
+
+	generated, err := r.withDelay(base, body, delays[0])
+	if err != nil {
+		return err
+	}
+	generated.dynamicValues = common.MergeMaps(generated.dynamicValues, result.toMap())
+	r.options.Options.RateLimit.Wait(generated.request.URL.Host)
+	return r.executeRequest(input, generated, previous, callback, reqcount)
+}
+
+// withDelay copies the generated request with the delay marker replaced, the timeout is extended by the delay
+func (r *Request) withDelay(base *generatedRequest, body []byte, delay int) (*generatedRequest, error) {
+	value := strconv.Itoa(delay)
+	rawURL := strings.NewReplacer(sleepTimeMarker, value, url.PathEscape(sleepTimeMarker), value, url.QueryEscape(sleepTimeMarker), value).Replace(base.request.URL.String())
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(r.options.Options.Timeout+delay)*time.Second)
+	req := base.request.Clone(ctx)
+	req.URL = parsed
+	for key, values := range req.Header {
+		for i := range values {
+			values[i] = strings.ReplaceAll(values[i], sleepTimeMarker, value)
+		}
+		req.Header[key] = values
+	}
+	if len(body) > 0 {
+		replaced := bytes.ReplaceAll(body, []byte(sleepTimeMarker), []byte(value))
+		req.Body = ioutil.NopCloser(bytes.NewReader(replaced))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(replaced)), nil
+		}
+		req.ContentLength = int64(len(replaced))
+	}
+	return &generatedRequest{
+		original:      r,
+		meta:          base.meta,
+		request:       req,
+		transport:     base.transport,
+		dynamicValues: base.dynamicValues,
+		cancel:        cancel,
+		session:       base.session,
+		token:         base.token,
+		slot:          base.slot,
+	}, nil
+}
+
+// measure sends a sample through the before request hooks and returns the seconds until the response body is read
+func (r *Request) measure(input *protocols.ScanContext, sample *generatedRequest) (float64, error) {
+	defer sample.cancel()
+	if err := r.options.Options.Scope.CheckURL(sample.request.URL); err != nil {
+		r.reportScopeViolation(input, err)
+		return 0, err
+	}
+	if err := r.options.RunBeforeRequest(sample.request); err != nil {
+		common.Debug("%s request vetoed, %s", sample.request.URL, err.Error())
+		return 0, err
+	}
+	r.options.Options.RateLimit.Wait(sample.request.URL.Host)
+	timeStart := time.Now()
+	resp, err := r.do(r.client(sample), sample)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	return time.Since(timeStart).Seconds(), nil
+}
@@ -0,0 +1,98 @@
+package http
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chainreactors/neutron/protocols"
+)
+
+func TestAnalyze(t *testing.T) {
+	analyzer := &Analyzer{Name: TimeDelayAnalyzer}
+	if err := analyzer.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	if delays := analyzer.delays(); len(delays) != 4 || delays[0] != 5 || delays[1] != 1 || delays[2] != 4 || delays[3] != 2 {
+		t.Fatalf("unexpected delays %v", delays)
+	}
+
+	tests := []struct {
+		name      string
+		delays    []int
+		durations []float64
+		matched   bool
+		slope     float64
+		intercept float64
+	}{
+		{"exact delay", []int{5, 1, 4, 2}, []float64{5.2, 1.2, 4.2, 2.2}, true, 1, 0.2},
+		{"jitter", []int{5, 1, 4, 2}, []float64{5.3, 1.1, 4.25, 2.2}, true, 1.045, 0.0775},
+		{"twice the delay", []int{5, 1, 4, 2}, []float64{10, 2, 8, 4}, false, 2, 0},
+		{"constant time", []int{5, 1, 4, 2}, []float64{6, 6, 6, 6}, false, 0, 0},
+		{"uncorrelated", []int{5, 1, 4, 2}, []float64{5, 5, 1, 4}, false, -0.3, 4.65},
+	}
+	for _, test := range tests {
+		result := analyzer.analyze(test.delays, test.durations)
+		if result.matched != test.matched {
+			t.Errorf("%s: expected matched %v, got %+v", test.name, test.matched, result)
+		}
+		if math.Abs(result.slope-test.slope) > 0.001 || math.Abs(result.intercept-test.intercept) > 0.001 {
+			t.Errorf("%s: expected slope %v and intercept %v, got %v and %v", test.name, test.slope, test.intercept, result.slope, result.intercept)
+		}
+		if result.matched && (result.correlation < 0.99 || result.confidence <= 0 || result.confidence > 1) {
+			t.Errorf("%s: unexpected correlation %v and confidence %v", test.name, result.correlation, result.confidence)
+		}
+	}
+
+	vars := analyzer.analyze([]int{5, 1, 4, 2}, []float64{5.2, 1.2, 4.2, 2.2}).toMap()
+	if vars["analyzer_slope"] != 1.0 || vars["analyzer_delays"] != "5,1,4,2" || vars["analyzer_durations"] != "5.200,1.200,4.200,2.200" {
+		t.Errorf("unexpected analyzer variables %v", vars)
+	}
+}
+
+func TestAnalyzerBeforeRequest(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("X-Signature"))
+	}))
+	defer server.Close()
+
+	request := &Request{Path: []string{"{{BaseURL}}/"}, Method: "GET", Analyzer: &Analyzer{Name: TimeDelayAnalyzer}}
+	options := &protocols.ExecuterOptions{Options: &protocols.Options{Timeout: 5}}
+	var hooked []string
+	options.AddBeforeRequest(func(req *http.Request) error {
+		hooked = append(hooked, req.URL.Query().Get("sleep"))
+		if req.URL.Query().Get("sleep") == "2" {
+			return errors.New("vetoed")
+		}
+		req.Header.Set("X-Signature", "signed")
+		return nil
+	})
+	if err := request.Compile(options); err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := http.NewRequest("GET", server.URL+"/?sleep="+sleepTimeMarker, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scan := protocols.NewScanContext(server.URL, nil)
+	for _, delay := range []int{1, 2} {
+		sample, err := request.withDelay(&generatedRequest{original: request, request: base}, nil, delay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = request.measure(scan, sample)
+		if (err != nil) != (delay == 2) {
+			t.Errorf("delay %d: unexpected error %v", delay, err)
+		}
+	}
+	if len(hooked) != 2 || hooked[0] != "1" || hooked[1] != "2" {
+		t.Errorf("expected the samples to run the hooks, got %v", hooked)
+	}
+	if len(received) != 1 || received[0] != "signed" {
+		t.Errorf("expected a single signed sample, got %v", received)
+	}
+}
@@ -0,0 +1,317 @@
+package http
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/chainreactors/neutron/common"
+	"github.com/chainreactors/neutron/protocols"
+)
+
+// maxChallengeBodySize is the maximum size of a challenge body drained to keep the connection alive
+const maxChallengeBodySize = 1 << 16
+
+// getAuth returns the auth of the request, falling back to the scan level auth
+func (r *Request) getAuth() *protocols.AuthConfig {
+	if r.Auth != nil {
+		return r.Auth
+	}
+	return r.options.Options.Auth
+}
+
+// doAuth sends the request with the configured authentication
+func (r *Request) doAuth(client *http.Client, request *generatedRequest) (*http.Response, error) {
+	auth := r.getAuth()
+	if auth == nil {
+		return client.Do(request.request)
+	}
+	req := request.request
+	values := request.Vars()
+	username, err := common.Evaluate(auth.Username, values)
+	if err != nil {
+		return nil, err
+	}
+	password, err := common.Evaluate(auth.Password, values)
+	if err != nil {
+		return nil, err
+	}
+
+	switch auth.Type {
+	case protocols.AuthBasic:
+		req.SetBasicAuth(username, password)
+		return client.Do(req)
+	case protocols.AuthBearer:
+		token := request.token
+		if token == "" {
+			if token, err = common.Evaluate(auth.Token, values); err != nil {
+				return nil, err
+			}
+		}
+		if token == "" && auth.TokenProvider != nil {
+			if token, err = auth.TokenProvider(req.URL.Scheme + "://" + req.URL.Host); err != nil {
+				return nil, fmt.Errorf("could not get bearer token: %w", err)
+			}
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return client.Do(req)
+	case protocols.AuthDigest:
+		return r.doDigest(client, req, username, password)
+	case protocols.AuthNTLM:
+		return r.doNTLM(client, req, auth.Domain, username, password)
+	}
+	return client.Do(req)
+}
+
+// replayable buffers the request body so the request can be sent again after a challenge
+func replayable(req *http.Request) error {
+	if req.Body == nil || req.GetBody != nil {
+		return nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+	return nil
+}
+
+// retry clones the request with a fresh body and the given authorization
+func retry(req *http.Request, authorization string) (*http.Request, error) {
+	next := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		next.Body = body
+	}
+	next.Header.Set("Authorization", authorization)
+	return next, nil
+}
+
+// drain reads the challenge response so its connection can be reused
+func drain(resp *http.Response) {
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxChallengeBodySize))
+	resp.Body.Close()
+}
+
+// challenge returns the parameters of the first WWW-Authenticate challenge of the scheme
+func challenge(resp *http.Response, scheme string) (string, bool) {
+	for _, value := range resp.Header.Values("WWW-Authenticate") {
+		value = strings.TrimSpace(value)
+		if strings.EqualFold(value, scheme) {
+			return "", true
+		}
+		if len(value) > len(scheme) && strings.EqualFold(value[:len(scheme)+1], scheme+" ") {
+			return strings.TrimSpace(value[len(scheme)+1:]), true
+		}
+	}
+	return "", false
+}
+
+func (r *Request) doDigest(client *http.Client, req *http.Request, username, password string) (*http.Response, error) {
+	if err := replayable(req); err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	params, ok := challenge(resp, "Digest")
+	if !ok {
+		return resp, nil
+	}
+	authorization, err := digestAuthorization(parseAuthParams(params), req.Method, req.URL.RequestURI(), username, password)
+	if err != nil {
+		return resp, nil
+	}
+	drain(resp)
+	next, err := retry(req, authorization)
+	if err != nil {
+		return nil, err
+	}
+	r.options.Options.RateLimit.Wait(req.URL.Host)
+	return client.Do(next)
+}
+
+// parseAuthParams parses the comma separated key=value parameters of a challenge
+func parseAuthParams(params string) map[string]string {
+	parsed := make(map[string]string)
+	for len(params) > 0 {
+		params = strings.TrimLeft(params, " ,")
+		eq := strings.Index(params, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(params[:eq]))
+		params = strings.TrimSpace(params[eq+1:])
+		var value string
+		if strings.HasPrefix(params, `"`) {
+			end := 1
+			for end < len(params) && params[end] != '"' {
+				if params[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(params) {
+				end = len(params) - 1
+			}
+			value = strings.Replace(params[1:end], `\`, "", -1)
+			params = params[end+1:]
+		} else if comma := strings.Index(params, ","); comma >= 0 {
+			value, params = strings.TrimSpace(params[:comma]), params[comma+1:]
+		} else {
+			value, params = strings.TrimSpace(params), ""
+		}
+		parsed[key] = value
+	}
+	return parsed
+}
+
+// digestAuthorization returns the RFC 7616 authorization answering the challenge
+func digestAuthorization(params map[string]string, method, uri, username, password string) (string, error) {
+	algorithm := params["algorithm"]
+	var newHash func() hash.Hash
+	switch strings.ToUpper(strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS")) {
+	case "", "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm: %s", algorithm)
+	}
+	h := func(data string) string {
+		sum := newHash()
+		sum.Write([]byte(data))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	realm, nonce := params["realm"], params["nonce"]
+	if nonce == "" {
+		return "", errors.New("digest challenge without nonce")
+	}
+	cnonceBytes := make([]byte, 8)
+	if _, err := rand.Read(cnonceBytes); err != nil {
+		return "", err
+	}
+	cnonce := hex.EncodeToString(cnonceBytes)
+	nc := "00000001"
+
+	ha1 := h(username + ":" + realm + ":" + password)
+	if strings.HasSuffix(strings.ToUpper(algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+
+	var qop string
+	for _, q := range strings.Split(params["qop"], ",") {
+		if strings.TrimSpace(q) == "auth" {
+			qop = "auth"
+		}
+	}
+	var response string
+	if qop != "" {
+		response = h(strings.Join([]string{ha1, nonce, nc, cnonce, qop, ha2}, ":"))
+	} else {
+		response = h(ha1 + ":" + nonce + ":" + ha2)
+	}
+
+	authorization := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`, username, realm, nonce, uri, response)
+	if algorithm != "" {
+		authorization += ", algorithm=" + algorithm
+	}
+	if qop != "" {
+		authorization += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, qop, nc, cnonce)
+	}
+	if opaque, ok := params["opaque"]; ok {
+		authorization += fmt.Sprintf(`, opaque="%s"`, opaque)
+	}
+	return authorization, nil
+}
+
+// doNTLM performs the ntlm handshake, ntlm authenticates the connection so the handshake
+// is sent on a dedicated transport keeping a single connection to the host. The transport is
+// closed with the body of the returned response.
+func (r *Request) doNTLM(client *http.Client, req *http.Request, domain, username, password string) (resp *http.Response, err error) {
+	if err := replayable(req); err != nil {
+		return nil, err
+	}
+	base, ok := client.Transport.(*http.Transport)
+	if !ok {
+		base = DefaultTransport
+	}
+	tr := base.Clone()
+	tr.MaxConnsPerHost = 1
+	tr.MaxIdleConnsPerHost = 1
+	tr.DisableKeepAlives = false
+	ntlmClient := *client
+	ntlmClient.Transport = tr
+	defer func() {
+		if resp == nil {
+			tr.CloseIdleConnections()
+			return
+		}
+		resp.Body = &transportBody{ReadCloser: resp.Body, transport: tr}
+	}()
+
+	negotiate, err := retry(req, "NTLM "+base64.StdEncoding.EncodeToString(ntlmNegotiateMessage()))
+	if err != nil {
+		return nil, err
+	}
+	resp, err = ntlmClient.Do(negotiate)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	params, ok := challenge(resp, "NTLM")
+	if !ok || params == "" {
+		return resp, nil
+	}
+	msg, err := base64.StdEncoding.DecodeString(params)
+	if err != nil {
+		return resp, nil
+	}
+	ntlmChallenge, err := parseNTLMChallenge(msg)
+	if err != nil {
+		return resp, nil
+	}
+	drain(resp)
+	authenticate, err := ntlmAuthenticateMessage(ntlmChallenge, domain, username, password)
+	if err != nil {
+		return nil, err
+	}
+	next, err := retry(req, "NTLM "+base64.StdEncoding.EncodeToString(authenticate))
+	if err != nil {
+		return nil, err
+	}
+	r.options.Options.RateLimit.Wait(req.URL.Host)
+	return ntlmClient.Do(next)
+}
+
+// transportBody closes the idle connections of its dedicated transport once the body is closed
+type transportBody struct {
+	io.ReadCloser
+	transport *http.Transport
+}
+
+func (b *transportBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.transport.CloseIdleConnections()
+	return err
+}
@@ -0,0 +1,197 @@
+package http
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chainreactors/neutron/operators"
+	"github.com/chainreactors/neutron/protocols"
+)
+
+func executeAuthRequest(t *testing.T, target string, auth *protocols.AuthConfig) bool {
+	request := &Request{
+		Path:   []string{"{{BaseURL}}"},
+		Method: "POST",
+		Body:   "data=1",
+		Auth:   auth,
+		Operators: operators.Operators{
+			Matchers: []*operators.Matcher{{Type: "status", Status: []int{200}}},
+		},
+	}
+	options := &protocols.ExecuterOptions{Options: &protocols.Options{Timeout: 5}}
+	if err := request.Compile(options); err != nil {
+		t.Fatalf("could not compile request: %s", err)
+	}
+	var matched bool
+	err := request.ExecuteWithResults(protocols.NewScanContext(target, nil), nil, map[string]interface{}{}, func(event *protocols.InternalWrappedEvent) {
+		matched = event.OperatorsResult != nil && event.OperatorsResult.Matched
+	})
+	if err != nil {
+		t.Fatalf("could not execute %s request: %s", auth.Type, err)
+	}
+	return matched
+}
+
+func TestMD4(t *testing.T) {
+	tests := map[string]string{
+		"":    "31d6cfe0d16ae931b73c59d7e0c089c0",
+		"abc": "a448017aaf21d8525fc10ae87aa6729d",
+		"12345678901234567890123456789012345678901234567890123456789012345678901234567890": "e33b4ddc9c38f2199c3e7b164fcc0536",
+	}
+	for input, expected := range tests {
+		if sum := md4Sum([]byte(input)); hex.EncodeToString(sum[:]) != expected {
+			t.Errorf("md4(%q) = %x, expected %s", input, sum, expected)
+		}
+	}
+	// MS-NLMP 4.2.4.1.1 NTOWFv2
+	if key := hex.EncodeToString(ntlmv2Hash("Domain", "User", "Password")); key != "0c868a403bfd7a93a3001ef22ef02e3f" {
+		t.Errorf("unexpected ntlmv2 response key %s", key)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	if !executeAuthRequest(t, server.URL, &protocols.AuthConfig{Type: "basic", Username: "admin", Password: "secret"}) {
+		t.Error("basic auth failed")
+	}
+	if executeAuthRequest(t, server.URL, &protocols.AuthConfig{Type: "basic", Username: "admin", Password: "wrong"}) {
+		t.Error("basic auth succeeded with a wrong password")
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-"+r.Host {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	provider := func(target string) (string, error) {
+		return "token-" + strings.TrimPrefix(target, "http://"), nil
+	}
+	if !executeAuthRequest(t, server.URL, &protocols.AuthConfig{Type: "bearer", TokenProvider: provider}) {
+		t.Error("bearer auth failed")
+	}
+}
+
+func TestDigestAuth(t *testing.T) {
+	md5hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		if !strings.HasPrefix(authorization, "Digest ") {
+			w.Header().Set("WWW-Authenticate", `Digest realm="test", qop="auth,auth-int", nonce="abcdef", opaque="xyz"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		params := parseAuthParams(strings.TrimPrefix(authorization, "Digest "))
+		ha1 := md5hex("admin:test:secret")
+		ha2 := md5hex(r.Method + ":" + params["uri"])
+		expected := md5hex(strings.Join([]string{ha1, "abcdef", params["nc"], params["cnonce"], params["qop"], ha2}, ":"))
+		if params["response"] != expected || params["opaque"] != "xyz" || params["uri"] != r.URL.RequestURI() {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// the body must be replayed after the challenge
+		if body, _ := ioutil.ReadAll(r.Body); string(body) != "data=1" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	if !executeAuthRequest(t, server.URL+"/path?q=1", &protocols.AuthConfig{Type: "digest", Username: "admin", Password: "secret"}) {
+		t.Error("digest auth failed")
+	}
+	if executeAuthRequest(t, server.URL, &protocols.AuthConfig{Type: "digest", Username: "admin", Password: "wrong"}) {
+		t.Error("digest auth succeeded with a wrong password")
+	}
+}
+
+func TestNTLMAuth(t *testing.T) {
+	serverChallenge := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	targetInfo := []byte{ntlmAvTimestamp, 0, 8, 0, 1, 2, 3, 4, 5, 6, 7, 8, ntlmAvEOL, 0, 0, 0}
+	challengeMessage := make([]byte, ntlmChallengeMinLength)
+	copy(challengeMessage, ntlmSignature)
+	binary.LittleEndian.PutUint32(challengeMessage[8:], 2)
+	binary.LittleEndian.PutUint32(challengeMessage[20:], ntlmDefaultFlags)
+	copy(challengeMessage[24:], serverChallenge)
+	binary.LittleEndian.PutUint16(challengeMessage[40:], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint16(challengeMessage[42:], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint32(challengeMessage[44:], ntlmChallengeMinLength)
+	challengeMessage = append(challengeMessage, targetInfo...)
+
+	// ntlm authenticates the connection, the handshake must stay on the negotiating connection
+	var mu sync.Mutex
+	negotiated := make(map[string]bool)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		authorization := r.Header.Get("Authorization")
+		msg, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(authorization, "NTLM "))
+		switch {
+		case len(msg) > 12 && binary.LittleEndian.Uint32(msg[8:]) == 1:
+			negotiated[r.RemoteAddr] = true
+			w.Header().Set("WWW-Authenticate", "NTLM "+base64.StdEncoding.EncodeToString(challengeMessage))
+			w.WriteHeader(http.StatusUnauthorized)
+		case len(msg) > ntlmAuthenticateMessageHeaderLen && binary.LittleEndian.Uint32(msg[8:]) == 3 && negotiated[r.RemoteAddr]:
+			field := func(i int) []byte {
+				length := binary.LittleEndian.Uint16(msg[12+8*i:])
+				offset := binary.LittleEndian.Uint32(msg[16+8*i:])
+				return msg[offset : offset+uint32(length)]
+			}
+			ntResponse := field(1)
+			expected := ntlmv2Response(ntlmv2Hash("CORP", "admin", "secret"), serverChallenge, ntResponse[32:40], ntResponse[24:32], targetInfo)
+			if string(field(2)) != string(utf16le("CORP")) || string(ntResponse) != string(expected) {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		default:
+			w.Header().Set("WWW-Authenticate", "NTLM")
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	// the dedicated transports must close their connections once the responses are read
+	var open int32
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			atomic.AddInt32(&open, 1)
+		case http.StateClosed, http.StateHijacked:
+			atomic.AddInt32(&open, -1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	if !executeAuthRequest(t, server.URL, &protocols.AuthConfig{Type: "ntlm", Username: `CORP\admin`, Password: "secret"}) {
+		t.Error("ntlm auth failed")
+	}
+	if executeAuthRequest(t, server.URL, &protocols.AuthConfig{Type: "ntlm", Username: `CORP\admin`, Password: "wrong"}) {
+		t.Error("ntlm auth succeeded with a wrong password")
+	}
+	for i := 0; i < 100 && atomic.LoadInt32(&open) > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if count := atomic.LoadInt32(&open); count != 0 {
+		t.Errorf("%d ntlm connections leaked", count)
+	}
+}
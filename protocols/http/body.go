@@ -0,0 +1,181 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"strings"
+
+	"github.com/chainreactors/neutron/common"
+)
+
+// MultipartField is a field of a multipart body, it is a file when Filename is set
+type MultipartField struct {
+	Name     string `json:"name" yaml:"name"`
+	Filename string `json:"filename,omitempty" yaml:"filename,omitempty"`
+	// ContentType defaults to application/octet-stream for files
+	ContentType string `json:"content-type,omitempty" yaml:"content-type,omitempty"`
+	// Content supports {{variables}} and DSL expressions, e.g. {{base64_decode("...")}}
+	Content string `json:"content" yaml:"content"`
+}
+
+// validateBody checks that a single body kind is used
+func (r *Request) validateBody() error {
+	var kinds []string
+	if r.Body != "" {
+		kinds = append(kinds, "body")
+	}
+	if len(r.BodyForm) > 0 {
+		kinds = append(kinds, "body-form")
+	}
+	if r.BodyJSON != nil {
+		kinds = append(kinds, "body-json")
+	}
+	if len(r.BodyMultipart) > 0 {
+		kinds = append(kinds, "body-multipart")
+	}
+	if len(kinds) > 1 {
+		return fmt.Errorf("only one of %s can be used", strings.Join(kinds, ", "))
+	}
+	if len(kinds) == 1 && kinds[0] != "body" && len(r.Raw) > 0 {
+		return fmt.Errorf("%s can not be used with raw requests", kinds[0])
+	}
+	for _, field := range r.BodyMultipart {
+		if field.Name == "" {
+			return errors.New("body-multipart field without name")
+		}
+	}
+	return nil
+}
+
+// buildBody builds the structured body of the request, it returns the body and its content type
+func (r *Request) buildBody(values map[string]interface{}) ([]byte, string, error) {
+	switch {
+	case len(r.BodyForm) > 0:
+		return buildFormBody(r.BodyForm, values)
+	case r.BodyJSON != nil:
+		return buildJSONBody(r.BodyJSON, values)
+	case len(r.BodyMultipart) > 0:
+		return buildMultipartBody(r.BodyMultipart, values)
+	}
+	return nil, "", nil
+}
+
+func buildFormBody(form map[string]string, values map[string]interface{}) ([]byte, string, error) {
+	encoded := url.Values{}
+	for key, value := range form {
+		key, err := common.Evaluate(key, values)
+		if err != nil {
+			return nil, "", err
+		}
+		value, err := common.Evaluate(value, values)
+		if err != nil {
+			return nil, "", err
+		}
+		encoded.Add(key, value)
+	}
+	return []byte(encoded.Encode()), "application/x-www-form-urlencoded", nil
+}
+
+func buildJSONBody(body interface{}, values map[string]interface{}) ([]byte, string, error) {
+	evaluated, err := evaluateJSON(body, values)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := json.Marshal(evaluated)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, "application/json", nil
+}
+
+// evaluateJSON evaluates the variables of the string keys and values of a decoded json or yaml document
+func evaluateJSON(node interface{}, values map[string]interface{}) (interface{}, error) {
+	switch v := node.(type) {
+	case string:
+		return common.Evaluate(v, values)
+	case map[string]interface{}:
+		evaluated := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			key, err := common.Evaluate(key, values)
+			if err != nil {
+				return nil, err
+			}
+			if evaluated[key], err = evaluateJSON(item, values); err != nil {
+				return nil, err
+			}
+		}
+		return evaluated, nil
+	case map[interface{}]interface{}:
+		evaluated := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			key, err := common.Evaluate(fmt.Sprint(key), values)
+			if err != nil {
+				return nil, err
+			}
+			if evaluated[key], err = evaluateJSON(item, values); err != nil {
+				return nil, err
+			}
+		}
+		return evaluated, nil
+	case []interface{}:
+		evaluated := make([]interface{}, len(v))
+		for i, item := range v {
+			var err error
+			if evaluated[i], err = evaluateJSON(item, values); err != nil {
+				return nil, err
+			}
+		}
+		return evaluated, nil
+	}
+	return node, nil
+}
+
+func buildMultipartBody(fields []*MultipartField, values map[string]interface{}) ([]byte, string, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for _, field := range fields {
+		var evaluated [4]string
+		for i, value := range []string{field.Name, field.Filename, field.ContentType, field.Content} {
+			var err error
+			if evaluated[i], err = common.Evaluate(value, values); err != nil {
+				return nil, "", err
+			}
+		}
+		name, filename, contentType, content := evaluated[0], evaluated[1], evaluated[2], evaluated[3]
+
+		header := make(textproto.MIMEHeader)
+		disposition := fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(name))
+		if filename != "" {
+			disposition += fmt.Sprintf(`; filename="%s"`, escapeQuotes(filename))
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+		}
+		header.Set("Content-Disposition", disposition)
+		if contentType != "" {
+			header.Set("Content-Type", contentType)
+		}
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := part.Write([]byte(content)); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), writer.FormDataContentType(), nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
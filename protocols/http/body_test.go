@@ -0,0 +1,148 @@
+package http
+
+import (
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/chainreactors/neutron/operators"
+	"github.com/chainreactors/neutron/protocols"
+)
+
+func TestMultipartBody(t *testing.T) {
+	type received struct {
+		contentType   string
+		contentLength string
+		length        int
+		fields        map[string]string
+		files         map[string]string
+		err           error
+	}
+	var got received
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		got = received{
+			contentType:   r.Header.Get("Content-Type"),
+			contentLength: r.Header.Get("Content-Length"),
+			length:        len(body),
+			fields:        make(map[string]string),
+			files:         make(map[string]string),
+		}
+		_, params, err := mime.ParseMediaType(got.contentType)
+		if err != nil || !strings.Contains(string(body), "--"+params["boundary"]+"--") {
+			got.err = err
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.Body = ioutil.NopCloser(strings.NewReader(string(body)))
+		if got.err = r.ParseMultipartForm(1 << 20); got.err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for name, values := range r.MultipartForm.Value {
+			got.fields[name] = values[0]
+		}
+		for name, headers := range r.MultipartForm.File {
+			file, _ := headers[0].Open()
+			content, _ := ioutil.ReadAll(file)
+			file.Close()
+			got.files[name] = headers[0].Filename + "|" + headers[0].Header.Get("Content-Type") + "|" + string(content)
+		}
+	}))
+	defer server.Close()
+
+	request := &Request{
+		Path:   []string{"{{BaseURL}}/upload"},
+		Method: "POST",
+		// the generated boundary replaces the template content type
+		Headers: map[string]string{"Content-Type": "multipart/form-data; boundary=template"},
+		BodyMultipart: []*MultipartField{
+			{Name: "user", Content: "{{username}}"},
+			{Name: "note", Content: `quote " and \ backslash`},
+			{Name: "file", Filename: "{{username}}.php", Content: `{{base64_decode("PD9waHAgZWNobyAxOyA/Pg==")}}`},
+			{Name: "image", Filename: "a.png", ContentType: "image/png", Content: "\x89PNG"},
+		},
+		Operators: operators.Operators{
+			Matchers: []*operators.Matcher{{Type: "status", Status: []int{200}}},
+		},
+	}
+	options := &protocols.ExecuterOptions{Options: &protocols.Options{Timeout: 5}}
+	if err := request.Compile(options); err != nil {
+		t.Fatalf("could not compile request: %s", err)
+	}
+	var matched bool
+	err := request.ExecuteWithResults(protocols.NewScanContext(server.URL, map[string]interface{}{"username": "admin"}), nil, map[string]interface{}{}, func(event *protocols.InternalWrappedEvent) {
+		matched = event.OperatorsResult != nil && event.OperatorsResult.Matched
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !matched {
+		t.Fatalf("the multipart body wasn't parsed: %v, content type %s", got.err, got.contentType)
+	}
+	if got.contentLength != strconv.Itoa(got.length) {
+		t.Errorf("expected the content length %d, got %s", got.length, got.contentLength)
+	}
+	expectedFields := map[string]string{"user": "admin", "note": `quote " and \ backslash`}
+	for name, value := range expectedFields {
+		if got.fields[name] != value {
+			t.Errorf("expected the field %s %q, got %q", name, value, got.fields[name])
+		}
+	}
+	expectedFiles := map[string]string{
+		"file":  "admin.php|application/octet-stream|<?php echo 1; ?>",
+		"image": "a.png|image/png|\x89PNG",
+	}
+	for name, value := range expectedFiles {
+		if got.files[name] != value {
+			t.Errorf("expected the file %s %q, got %q", name, value, got.files[name])
+		}
+	}
+}
+
+func TestBuildBody(t *testing.T) {
+	values := map[string]interface{}{"username": "admin"}
+	tests := []struct {
+		name        string
+		request     *Request
+		body        string
+		contentType string
+	}{
+		{
+			name:        "form",
+			request:     &Request{BodyForm: map[string]string{"user": "{{username}}", "q": "a&b c"}},
+			body:        "q=a%26b+c&user=admin",
+			contentType: "application/x-www-form-urlencoded",
+		},
+		{
+			name:        "json",
+			request:     &Request{BodyJSON: map[interface{}]interface{}{"user": "{{username}}", "ids": []interface{}{1, "{{to_upper(username)}}"}}},
+			body:        `{"ids":[1,"ADMIN"],"user":"admin"}`,
+			contentType: "application/json",
+		},
+	}
+	for _, test := range tests {
+		body, contentType, err := test.request.buildBody(values)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if string(body) != test.body || contentType != test.contentType {
+			t.Errorf("%s: expected %s %s, got %s %s", test.name, test.contentType, test.body, contentType, body)
+		}
+	}
+
+	for _, request := range []*Request{
+		{Body: "a", BodyForm: map[string]string{"a": "b"}},
+		{Raw: []string{"POST / HTTP/1.1\n\n"}, BodyMultipart: []*MultipartField{{Name: "a"}}},
+		{BodyMultipart: []*MultipartField{{Content: "a"}}},
+	} {
+		if err := request.validateBody(); err == nil {
+			t.Errorf("expected an invalid body for %+v", request)
+		}
+	}
+}
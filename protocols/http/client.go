@@ -8,6 +8,8 @@ import (
 	"net/http/cookiejar"
 	"net/url"
 	"time"
+
+	"github.com/chainreactors/neutron/protocols"
 )
 
 var ua = "Mozilla/5.0 (compatible; MSIE 9.0; Windows NT 6.1; Trident/5.0;"
@@ -17,7 +19,13 @@ type Configuration struct {
 	FollowRedirects bool
 	MaxRedirects    int
 	CookieReuse     bool
-	Proxy           func(*http.Request) (*url.URL, error)
+	// CookieJar is the jar used with CookieReuse, a new jar is created when nil
+	CookieJar http.CookieJar
+	Proxy     func(*http.Request) (*url.URL, error)
+	// Scope is checked on every redirect hop
+	Scope *protocols.Scope
+	// RateLimit is applied on every redirect hop
+	RateLimit *protocols.RateLimit
 }
 
 var DefaultOption = Configuration{
@@ -26,6 +34,9 @@ var DefaultOption = Configuration{
 	3,
 	false,
 	nil,
+	nil,
+	nil,
+	nil,
 }
 
 var DefaultTransport = &http.Transport{
@@ -46,13 +57,13 @@ var DefaultTransport = &http.Transport{
 func createClient(opt *Configuration) *http.Client {
 	var tr *http.Transport = DefaultTransport
 
-	var jar *cookiejar.Jar
-	if opt.CookieReuse {
+	jar := opt.CookieJar
+	if opt.CookieReuse && jar == nil {
 		jar, _ = cookiejar.New(nil)
 	}
 	client := &http.Client{
 		Transport:     tr,
-		CheckRedirect: makeCheckRedirectFunc(opt.FollowRedirects, opt.MaxRedirects),
+		CheckRedirect: makeCheckRedirectFunc(opt.FollowRedirects, opt.MaxRedirects, opt.Scope, opt.RateLimit),
 	}
 	if jar != nil {
 		client.Jar = jar
@@ -64,21 +75,24 @@ const defaultMaxRedirects = 10
 
 type checkRedirectFunc func(req *http.Request, via []*http.Request) error
 
-func makeCheckRedirectFunc(followRedirects bool, maxRedirects int) checkRedirectFunc {
+func makeCheckRedirectFunc(followRedirects bool, maxRedirects int, scope *protocols.Scope, rateLimit *protocols.RateLimit) checkRedirectFunc {
 	return func(req *http.Request, via []*http.Request) error {
 		if !followRedirects {
 			return http.ErrUseLastResponse
 		}
-		if maxRedirects == 0 {
-			if len(via) > defaultMaxRedirects {
-				return http.ErrUseLastResponse
-			}
-			return nil
+		// every hop must stay in scope, a redirect to an out of scope host blocks the request
+		if err := scope.CheckURL(req.URL); err != nil {
+			return err
 		}
-
-		if len(via) > maxRedirects {
+		limit := maxRedirects
+		if limit == 0 {
+			limit = defaultMaxRedirects
+		}
+		if len(via) > limit {
 			return http.ErrUseLastResponse
 		}
+		rateLimit.Wait(req.URL.Host)
+		recordRedirect(req)
 		return nil
 	}
 }
@@ -0,0 +1,177 @@
+package http
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/chainreactors/neutron/common"
+	"github.com/chainreactors/neutron/protocols"
+)
+
+// maxFaviconSize is the maximum size of an icon read in bytes
+const maxFaviconSize = 1 << 20
+
+var (
+	reLinkTag = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+	reIconRel = regexp.MustCompile(`(?i)\brel\s*=\s*["']?[^"'>]*\bicon\b`)
+	reHref    = regexp.MustCompile(`(?i)\bhref\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+	reTitle   = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+)
+
+// fingerprintVars returns the title and the normalised body hash of the response, and the hashes
+// of the first icon found among the <link rel=icon> icons and /favicon.ico:
+//
+//	favicon_mmh3  the shodan compatible hash, mmh3(base64_py(icon))
+//	favicon_md5   the md5 of the icon
+//	favicon_url   the url of the icon
+//	title         the page title
+//	body_hash     the md5 of the body with its whitespaces collapsed
+func (r *Request) fingerprintVars(input *protocols.ScanContext, base *generatedRequest, matchedURL, body string) map[string]interface{} {
+	vars := map[string]interface{}{
+		"title":     pageTitle(body),
+		"body_hash": md5Hex([]byte(strings.Join(strings.Fields(body), " "))),
+	}
+	pageURL, err := url.Parse(matchedURL)
+	if err != nil {
+		return vars
+	}
+	for k, v := range r.favicon(input, base, pageURL, body) {
+		vars[k] = v
+	}
+	return vars
+}
+
+// favicon returns the favicon variables of the host of the page, the icons are fetched once per host
+func (r *Request) favicon(input *protocols.ScanContext, base *generatedRequest, pageURL *url.URL, body string) map[string]interface{} {
+	host := pageURL.Scheme + "://" + pageURL.Host
+	if cached, ok := r.favicons.Load(host); ok {
+		return cached.(map[string]interface{})
+	}
+	vars := make(map[string]interface{})
+	for _, iconURL := range iconURLs(pageURL, body) {
+		icon, err := r.fetchIcon(input, base, iconURL)
+		if err != nil {
+			common.Debug("could not fetch icon %s, %s", iconURL, err.Error())
+			continue
+		}
+		if len(icon) == 0 {
+			continue
+		}
+		encoded, _ := common.HelperFunctions["base64_py"](string(icon))
+		hash, _ := common.HelperFunctions["mmh3"](encoded)
+		vars["favicon_mmh3"] = hash
+		vars["favicon_md5"] = md5Hex(icon)
+		vars["favicon_url"] = iconURL
+		break
+	}
+	r.favicons.Store(host, vars)
+	return vars
+}
+
+// pageTitle returns the unescaped title of an html page with its whitespaces collapsed
+func pageTitle(body string) string {
+	matches := reTitle.FindStringSubmatch(body)
+	if matches == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(matches[1])), " ")
+}
+
+// iconURLs returns the icons referenced by the page in order, then /favicon.ico
+func iconURLs(pageURL *url.URL, body string) []string {
+	var urls []string
+	seen := make(map[string]struct{})
+	add := func(u string) {
+		if _, ok := seen[u]; !ok {
+			seen[u] = struct{}{}
+			urls = append(urls, u)
+		}
+	}
+	for _, tag := range reLinkTag.FindAllString(body, -1) {
+		if !reIconRel.MatchString(tag) {
+			continue
+		}
+		matches := reHref.FindStringSubmatch(tag)
+		if matches == nil {
+			continue
+		}
+		href := strings.TrimSpace(html.UnescapeString(matches[1] + matches[2] + matches[3]))
+		if href == "" {
+			continue
+		}
+		if strings.HasPrefix(href, "data:") {
+			add(href)
+			continue
+		}
+		resolved, err := pageURL.Parse(href)
+		if err != nil || (resolved.Scheme != "http" && resolved.Scheme != "https") {
+			continue
+		}
+		add(resolved.String())
+	}
+	add((&url.URL{Scheme: pageURL.Scheme, Host: pageURL.Host, Path: "/favicon.ico"}).String())
+	return urls
+}
+
+// fetchIcon returns the content of an icon through the before request hooks, inline data: icons are decoded
+func (r *Request) fetchIcon(input *protocols.ScanContext, base *generatedRequest, iconURL string) ([]byte, error) {
+	if strings.HasPrefix(iconURL, "data:") {
+		return decodeDataURL(iconURL)
+	}
+	ctx, cancel := r.newContext()
+	defer cancel()
+	req, err := http.NewRequest(http.MethodGet, iconURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if userAgent := base.request.Header.Get("User-Agent"); userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	if err := r.options.Options.Scope.CheckURL(req.URL); err != nil {
+		r.reportScopeViolation(input, err)
+		return nil, err
+	}
+	if err := r.options.RunBeforeRequest(req); err != nil {
+		return nil, err
+	}
+	icon := &generatedRequest{original: r, meta: base.meta, request: req, transport: base.transport, dynamicValues: base.dynamicValues}
+	r.options.Options.RateLimit.Wait(req.URL.Host)
+	resp, err := r.do(r.client(icon), icon)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil, nil
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, maxFaviconSize))
+}
+
+// decodeDataURL decodes a data:[<mediatype>][;base64],<data> url
+func decodeDataURL(dataURL string) ([]byte, error) {
+	comma := strings.Index(dataURL, ",")
+	if comma < 0 {
+		return nil, nil
+	}
+	data := dataURL[comma+1:]
+	if strings.HasSuffix(dataURL[:comma], ";base64") {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	decoded, err := url.PathUnescape(data)
+	return []byte(decoded), err
+}
+
+func md5Hex(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
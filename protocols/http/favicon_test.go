@@ -0,0 +1,112 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/chainreactors/neutron/operators"
+	"github.com/chainreactors/neutron/protocols"
+	"github.com/spaolacci/murmur3"
+)
+
+func TestFavicon(t *testing.T) {
+	icon := []byte("\x00\x00\x01\x00icon")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><head><title> Acme &amp; Co
+				Login </title><link rel="shortcut icon" href="static/logo.ico"></head></html>`)
+		case "/static/logo.ico":
+			w.Write(icon)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// shodan hashes the base64 with a new line every 76 characters and at the end
+	hash := fmt.Sprintf("%d", int32(murmur3.Sum32([]byte("AAABAGljb24=\n"))))
+	request := &Request{
+		Path:    []string{"{{BaseURL}}/"},
+		Method:  "GET",
+		Favicon: true,
+		Operators: operators.Operators{
+			MatchersCondition: "and",
+			Matchers: []*operators.Matcher{
+				{Type: "dsl", DSL: []string{
+					fmt.Sprintf(`favicon_mmh3 == "%s"`, hash),
+					`favicon_md5 == md5(base64_decode("AAABAGljb24="))`,
+					`title == "Acme & Co Login"`,
+					fmt.Sprintf(`favicon_url == "%s/static/logo.ico"`, server.URL),
+				}, Condition: "and"},
+			},
+		},
+	}
+	if err := request.Compile(&protocols.ExecuterOptions{Options: &protocols.Options{Timeout: 5}}); err != nil {
+		t.Fatal(err)
+	}
+	var matched bool
+	err := request.ExecuteWithResults(protocols.NewScanContext(server.URL, nil), nil, map[string]interface{}{}, func(event *protocols.InternalWrappedEvent) {
+		matched = event.OperatorsResult != nil && event.OperatorsResult.Matched
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !matched {
+		t.Error("expected the favicon variables to match")
+	}
+}
+
+func TestFaviconCache(t *testing.T) {
+	var fetched int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			if r.Header.Get("X-Signature") != "signed" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			atomic.AddInt32(&fetched, 1)
+			w.Write([]byte("icon"))
+			return
+		}
+		fmt.Fprint(w, "<html></html>")
+	}))
+	defer server.Close()
+
+	request := &Request{
+		Path:    []string{"{{BaseURL}}/a", "{{BaseURL}}/b"},
+		Method:  "GET",
+		Favicon: true,
+		Operators: operators.Operators{
+			Matchers: []*operators.Matcher{{Type: "dsl", DSL: []string{`favicon_md5 == md5("icon")`}}},
+		},
+	}
+	options := &protocols.ExecuterOptions{Options: &protocols.Options{Timeout: 5}}
+	options.AddBeforeRequest(func(req *http.Request) error {
+		req.Header.Set("X-Signature", "signed")
+		return nil
+	})
+	if err := request.Compile(options); err != nil {
+		t.Fatal(err)
+	}
+	var matches int
+	for i := 0; i < 2; i++ {
+		err := request.ExecuteWithResults(protocols.NewScanContext(server.URL, nil), nil, map[string]interface{}{}, func(event *protocols.InternalWrappedEvent) {
+			if event.OperatorsResult != nil && event.OperatorsResult.Matched {
+				matches++
+			}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if matches != 4 {
+		t.Errorf("expected the 4 responses to match the cached icon, got %d", matches)
+	}
+	if fetched != 1 {
+		t.Errorf("expected the icon to be fetched once per host, got %d", fetched)
+	}
+}
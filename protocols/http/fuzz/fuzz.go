@@ -0,0 +1,230 @@
+package fuzz
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// ruleType is the type of mutation applied on a value
+type ruleType int
+
+const (
+	// replaceRuleType replaces the value with the payload
+	replaceRuleType ruleType = iota + 1
+	// prefixRuleType prepends the payload to the value
+	prefixRuleType
+	// postfixRuleType appends the payload to the value
+	postfixRuleType
+	// infixRuleType inserts the payload in the middle of the value
+	infixRuleType
+)
+
+var ruleTypes = map[string]ruleType{
+	"replace": replaceRuleType,
+	"prefix":  prefixRuleType,
+	"postfix": postfixRuleType,
+	"infix":   infixRuleType,
+}
+
+// modeType is the way parameters are mutated
+type modeType int
+
+const (
+	// singleModeType mutates one parameter at a time
+	singleModeType modeType = iota + 1
+	// multipleModeType mutates all the parameters at once
+	multipleModeType
+)
+
+var modeTypes = map[string]modeType{
+	"single":   singleModeType,
+	"multiple": multipleModeType,
+}
+
+// Rule is a fuzzing rule mutating a part of a base request
+type Rule struct {
+	// Part is the part of the request to fuzz: query, path, header, cookie, body,
+	// json, xml, form or request for every part. Default is query.
+	Part string `json:"part,omitempty" yaml:"part,omitempty"`
+	// Type is the mutation type: replace, prefix, postfix or infix. Default is replace.
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+	// Mode is single to mutate one parameter at a time, or multiple to mutate all of them at once.
+	// Default is single.
+	Mode string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	// Keys restricts the fuzzed parameters to these names
+	Keys []string `json:"keys,omitempty" yaml:"keys,omitempty"`
+	// KeysRegex restricts the fuzzed parameters to names matching these regexes
+	KeysRegex []string `json:"keys-regex,omitempty" yaml:"keys-regex,omitempty"`
+	// ValuesRegex restricts the fuzzed parameters to values matching these regexes
+	ValuesRegex []string `json:"values,omitempty" yaml:"values,omitempty"`
+	// Fuzz are the payloads, they support {{variables}}
+	Fuzz []string `json:"fuzz,omitempty" yaml:"fuzz,omitempty"`
+
+	parts       []string
+	ruleType    ruleType
+	modeType    modeType
+	keys        map[string]struct{}
+	keysRegex   []*regexp.Regexp
+	valuesRegex []*regexp.Regexp
+}
+
+// Mutation is a single fuzzed request
+type Mutation struct {
+	Request *http.Request
+	// Part is the fuzzed part
+	Part string
+	// Key is the fuzzed parameter, empty in multiple mode
+	Key string
+	// Payload is the payload used for the mutation
+	Payload string
+}
+
+// Compile validates the rule and compiles its regexes
+func (r *Rule) Compile() error {
+	part := strings.ToLower(r.Part)
+	switch part {
+	case "":
+		r.parts = []string{"query"}
+	case "request":
+		r.parts = []string{"query", "path", "header", "cookie", "body"}
+	default:
+		if _, ok := partBuilders[part]; !ok {
+			return fmt.Errorf("unknown fuzzing part: %s", r.Part)
+		}
+		r.parts = []string{part}
+	}
+
+	var ok bool
+	if r.Type == "" {
+		r.ruleType = replaceRuleType
+	} else if r.ruleType, ok = ruleTypes[r.Type]; !ok {
+		return fmt.Errorf("unknown fuzzing type: %s", r.Type)
+	}
+	if r.Mode == "" {
+		r.modeType = singleModeType
+	} else if r.modeType, ok = modeTypes[r.Mode]; !ok {
+		return fmt.Errorf("unknown fuzzing mode: %s", r.Mode)
+	}
+	if len(r.Fuzz) == 0 {
+		return fmt.Errorf("no fuzz payloads for %s fuzzing rule", r.Part)
+	}
+
+	r.keys = make(map[string]struct{}, len(r.Keys))
+	for _, key := range r.Keys {
+		r.keys[strings.ToLower(key)] = struct{}{}
+	}
+	for _, regex := range r.KeysRegex {
+		compiled, err := regexp.Compile(regex)
+		if err != nil {
+			return fmt.Errorf("could not compile keys regex: %s", regex)
+		}
+		r.keysRegex = append(r.keysRegex, compiled)
+	}
+	for _, regex := range r.ValuesRegex {
+		compiled, err := regexp.Compile(regex)
+		if err != nil {
+			return fmt.Errorf("could not compile values regex: %s", regex)
+		}
+		r.valuesRegex = append(r.valuesRegex, compiled)
+	}
+	return nil
+}
+
+// Execute calls fn for every mutation of the base request with the evaluated payloads,
+// the iteration stops when fn returns true.
+func (r *Rule) Execute(base *http.Request, body []byte, payloads []string, fn func(mutation *Mutation) bool) error {
+	for _, partName := range r.parts {
+		part, err := partBuilders[partName](base, body)
+		if err != nil {
+			return err
+		}
+		if part == nil {
+			continue
+		}
+
+		var selected []int
+		for i, param := range part.params {
+			if r.matchParam(param) {
+				selected = append(selected, i)
+			}
+		}
+		if len(selected) == 0 {
+			continue
+		}
+
+		for _, payload := range payloads {
+			if r.modeType == multipleModeType {
+				params := part.clone()
+				for _, i := range selected {
+					params[i].value = r.mutate(params[i].value, payload)
+				}
+				req, err := part.build(params)
+				if err != nil {
+					return err
+				}
+				if fn(&Mutation{Request: req, Part: partName, Payload: payload}) {
+					return nil
+				}
+				continue
+			}
+			for _, i := range selected {
+				params := part.clone()
+				params[i].value = r.mutate(params[i].value, payload)
+				req, err := part.build(params)
+				if err != nil {
+					return err
+				}
+				if fn(&Mutation{Request: req, Part: partName, Key: params[i].key, Payload: payload}) {
+					return nil
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// matchParam returns true if the parameter is selected by the keys and values filters
+func (r *Rule) matchParam(param param) bool {
+	if len(r.keys) > 0 || len(r.keysRegex) > 0 {
+		_, ok := r.keys[strings.ToLower(param.key)]
+		if !ok {
+			_, ok = r.keys[strings.ToLower(param.name())]
+		}
+		for _, regex := range r.keysRegex {
+			if ok {
+				break
+			}
+			ok = regex.MatchString(param.key)
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(r.valuesRegex) == 0 {
+		return true
+	}
+	for _, regex := range r.valuesRegex {
+		if regex.MatchString(param.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// mutate applies the rule type on a value
+func (r *Rule) mutate(value, payload string) string {
+	switch r.ruleType {
+	case prefixRuleType:
+		return payload + value
+	case postfixRuleType:
+		return value + payload
+	case infixRuleType:
+		runes := []rune(value)
+		middle := len(runes) / 2
+		return string(runes[:middle]) + payload + string(runes[middle:])
+	default:
+		return payload
+	}
+}
@@ -0,0 +1,426 @@
+package fuzz
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// param is a single fuzzable key/value of a part
+type param struct {
+	key   string
+	value string
+}
+
+// name returns the last segment of a nested key, e.g. "id" for "user.0.id"
+func (p param) name() string {
+	if i := strings.LastIndex(p.key, "."); i >= 0 {
+		return p.key[i+1:]
+	}
+	return p.key
+}
+
+// part is a parsed request part able to rebuild the request with new values
+type part struct {
+	params []param
+	build  func(params []param) (*http.Request, error)
+}
+
+func (p *part) clone() []param {
+	params := make([]param, len(p.params))
+	copy(params, p.params)
+	return params
+}
+
+// partBuilder parses a part of the base request, it returns nil if the part is empty
+type partBuilder func(base *http.Request, body []byte) (*part, error)
+
+var partBuilders = map[string]partBuilder{
+	"query":  queryPart,
+	"path":   pathPart,
+	"header": headerPart,
+	"cookie": cookiePart,
+	"body":   bodyPart,
+	"json":   jsonPart,
+	"xml":    xmlPart,
+	"form":   formPart,
+}
+
+// cloneRequest copies the base request with a fresh body
+func cloneRequest(base *http.Request, body []byte) *http.Request {
+	req := base.Clone(base.Context())
+	setBody(req, body)
+	return req
+}
+
+func setBody(req *http.Request, body []byte) {
+	if len(body) == 0 {
+		req.Body = nil
+		req.GetBody = nil
+		req.ContentLength = 0
+		return
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+}
+
+// span is the position of a raw value in the original part, prefix is written before a value
+// inserted where there was none, e.g. the = of a key without value
+type span struct {
+	start, end int
+	prefix     string
+}
+
+// splice writes the changed values of params in place of their spans in raw, the rest of raw
+// is kept byte for byte. The spans must be ordered.
+func splice(raw string, spans []span, original, params []param, encode func(string) string) string {
+	builder := &strings.Builder{}
+	last := 0
+	for i, p := range params {
+		if p.value == original[i].value {
+			continue
+		}
+		builder.WriteString(raw[last:spans[i].start])
+		if spans[i].start == spans[i].end {
+			builder.WriteString(spans[i].prefix)
+		}
+		builder.WriteString(encode(p.value))
+		last = spans[i].end
+	}
+	builder.WriteString(raw[last:])
+	return builder.String()
+}
+
+// parsePairs parses an url encoded list of pairs keeping their order, with the spans of their values
+func parsePairs(raw string) ([]param, []span) {
+	var params []param
+	var spans []span
+	for offset := 0; offset <= len(raw); {
+		end := strings.IndexByte(raw[offset:], '&')
+		if end < 0 {
+			end = len(raw)
+		} else {
+			end += offset
+		}
+		pair := raw[offset:end]
+		if pair != "" {
+			rawKey, rawValue := pair, ""
+			valueSpan := span{start: end, end: end, prefix: "="}
+			if eq := strings.IndexByte(pair, '='); eq >= 0 {
+				rawKey, rawValue = pair[:eq], pair[eq+1:]
+				valueSpan = span{start: offset + eq + 1, end: end}
+			}
+			key, err := url.QueryUnescape(rawKey)
+			if err != nil {
+				key = rawKey
+			}
+			value, err := url.QueryUnescape(rawValue)
+			if err != nil {
+				value = rawValue
+			}
+			params = append(params, param{key: key, value: value})
+			spans = append(spans, valueSpan)
+		}
+		offset = end + 1
+	}
+	return params, spans
+}
+
+// pairsPart fuzzes the pairs of raw, build gets raw with the changed values spliced in
+func pairsPart(raw string, build func(raw string) *http.Request) *part {
+	params, spans := parsePairs(raw)
+	if len(params) == 0 {
+		return nil
+	}
+	original := make([]param, len(params))
+	copy(original, params)
+	return &part{params: params, build: func(params []param) (*http.Request, error) {
+		return build(splice(raw, spans, original, params, url.QueryEscape)), nil
+	}}
+}
+
+func queryPart(base *http.Request, body []byte) (*part, error) {
+	return pairsPart(base.URL.RawQuery, func(raw string) *http.Request {
+		req := cloneRequest(base, body)
+		req.URL.RawQuery = raw
+		return req
+	}), nil
+}
+
+// pathPart fuzzes each path segment, segments are keyed by their position starting at 1
+func pathPart(base *http.Request, body []byte) (*part, error) {
+	var params []param
+	for i, segment := range strings.Split(base.URL.Path, "/") {
+		if segment == "" {
+			continue
+		}
+		params = append(params, param{key: strconv.Itoa(i), value: segment})
+	}
+	if len(params) == 0 {
+		return nil, nil
+	}
+	return &part{params: params, build: func(params []param) (*http.Request, error) {
+		req := cloneRequest(base, body)
+		segments := strings.Split(base.URL.Path, "/")
+		for _, p := range params {
+			index, _ := strconv.Atoi(p.key)
+			segments[index] = p.value
+		}
+		req.URL.Path = strings.Join(segments, "/")
+		req.URL.RawPath = ""
+		return req, nil
+	}}, nil
+}
+
+func headerPart(base *http.Request, body []byte) (*part, error) {
+	var params []param
+	for key := range base.Header {
+		switch http.CanonicalHeaderKey(key) {
+		case "Cookie", "Content-Length", "Host":
+			continue
+		}
+		params = append(params, param{key: key, value: base.Header.Get(key)})
+	}
+	if len(params) == 0 {
+		return nil, nil
+	}
+	sort.Slice(params, func(i, j int) bool {
+		return params[i].key < params[j].key
+	})
+	return &part{params: params, build: func(params []param) (*http.Request, error) {
+		req := cloneRequest(base, body)
+		for _, p := range params {
+			req.Header[p.key] = []string{p.value}
+		}
+		return req, nil
+	}}, nil
+}
+
+func cookiePart(base *http.Request, body []byte) (*part, error) {
+	var params []param
+	for _, cookie := range base.Cookies() {
+		params = append(params, param{key: cookie.Name, value: cookie.Value})
+	}
+	if len(params) == 0 {
+		return nil, nil
+	}
+	return &part{params: params, build: func(params []param) (*http.Request, error) {
+		req := cloneRequest(base, body)
+		cookies := make([]string, 0, len(params))
+		for _, p := range params {
+			// cookies are written as is, http.Cookie would sanitize the payloads
+			cookies = append(cookies, p.key+"="+p.value)
+		}
+		req.Header.Set("Cookie", strings.Join(cookies, "; "))
+		return req, nil
+	}}, nil
+}
+
+// bodyPart detects the body format from the content type, then from the content
+func bodyPart(base *http.Request, body []byte) (*part, error) {
+	contentType := strings.ToLower(base.Header.Get("Content-Type"))
+	trimmed := bytes.TrimSpace(body)
+	switch {
+	case len(trimmed) == 0, strings.Contains(contentType, "multipart/"):
+		return nil, nil
+	case strings.Contains(contentType, "json"), trimmed[0] == '{', trimmed[0] == '[':
+		return jsonPart(base, body)
+	case strings.Contains(contentType, "xml"), trimmed[0] == '<':
+		return xmlPart(base, body)
+	default:
+		return formPart(base, body)
+	}
+}
+
+func formPart(base *http.Request, body []byte) (*part, error) {
+	// the surrounding whitespaces are kept out of the first key and the last value
+	leading := len(body) - len(bytes.TrimLeftFunc(body, unicode.IsSpace))
+	trimmed := bytes.TrimRightFunc(body[leading:], unicode.IsSpace)
+	trailing := body[leading+len(trimmed):]
+	return pairsPart(string(trimmed), func(raw string) *http.Request {
+		spliced := make([]byte, 0, leading+len(raw)+len(trailing))
+		spliced = append(spliced, body[:leading]...)
+		spliced = append(spliced, raw...)
+		return cloneRequest(base, append(spliced, trailing...))
+	}), nil
+}
+
+// jsonPart fuzzes every leaf value of a json body, keys are the dotted path of the leaf
+func jsonPart(base *http.Request, body []byte) (*part, error) {
+	doc, err := decodeJSON(body)
+	if err != nil {
+		return nil, nil
+	}
+	var params []param
+	var paths [][]interface{}
+	walkJSON(doc, nil, func(path []interface{}, value string) {
+		keys := make([]string, 0, len(path))
+		for _, segment := range path {
+			keys = append(keys, fmtSegment(segment))
+		}
+		params = append(params, param{key: strings.Join(keys, "."), value: value})
+		paths = append(paths, path)
+	})
+	if len(params) == 0 {
+		return nil, nil
+	}
+	original := make([]param, len(params))
+	copy(original, params)
+	return &part{params: params, build: func(params []param) (*http.Request, error) {
+		doc, err := decodeJSON(body)
+		if err != nil {
+			return nil, err
+		}
+		for i, p := range params {
+			if p.value != original[i].value {
+				doc = setJSON(doc, paths[i], p.value)
+			}
+		}
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		return cloneRequest(base, data), nil
+	}}, nil
+}
+
+func decodeJSON(body []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var doc interface{}
+	err := decoder.Decode(&doc)
+	return doc, err
+}
+
+func fmtSegment(segment interface{}) string {
+	if index, ok := segment.(int); ok {
+		return strconv.Itoa(index)
+	}
+	return segment.(string)
+}
+
+func walkJSON(node interface{}, path []interface{}, fn func(path []interface{}, value string)) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			walkJSON(v[key], appendPath(path, key), fn)
+		}
+	case []interface{}:
+		for i, item := range v {
+			walkJSON(item, appendPath(path, i), fn)
+		}
+	case string:
+		fn(path, v)
+	case json.Number:
+		fn(path, v.String())
+	case bool:
+		fn(path, strconv.FormatBool(v))
+	case nil:
+		fn(path, "")
+	}
+}
+
+func appendPath(path []interface{}, segment interface{}) []interface{} {
+	next := make([]interface{}, len(path), len(path)+1)
+	copy(next, path)
+	return append(next, segment)
+}
+
+func setJSON(node interface{}, path []interface{}, value string) interface{} {
+	if len(path) == 0 {
+		return value
+	}
+	switch v := node.(type) {
+	case map[string]interface{}:
+		key := path[0].(string)
+		v[key] = setJSON(v[key], path[1:], value)
+	case []interface{}:
+		index := path[0].(int)
+		v[index] = setJSON(v[index], path[1:], value)
+	}
+	return node
+}
+
+// xmlPart fuzzes the text of every leaf element of a xml body, keys are the dotted element path.
+// The mutated texts are escaped and spliced in the body, the rest of the document is kept as is.
+func xmlPart(base *http.Request, body []byte) (*part, error) {
+	tokens, offsets, err := decodeXML(body)
+	if err != nil {
+		return nil, nil
+	}
+	var params []param
+	var spans []span
+	var stack []string
+	for i, token := range tokens {
+		switch t := token.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name.Local)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if i == 0 || i == len(tokens)-1 || len(bytes.TrimSpace(t)) == 0 {
+				continue
+			}
+			if _, ok := tokens[i-1].(xml.StartElement); !ok {
+				continue
+			}
+			if _, ok := tokens[i+1].(xml.EndElement); !ok {
+				continue
+			}
+			params = append(params, param{key: strings.Join(stack, "."), value: string(t)})
+			spans = append(spans, span{start: offsets[i], end: offsets[i+1]})
+		}
+	}
+	if len(params) == 0 {
+		return nil, nil
+	}
+	original := make([]param, len(params))
+	copy(original, params)
+	return &part{params: params, build: func(params []param) (*http.Request, error) {
+		return cloneRequest(base, []byte(splice(string(body), spans, original, params, escapeXML))), nil
+	}}, nil
+}
+
+func escapeXML(text string) string {
+	buf := &bytes.Buffer{}
+	xml.EscapeText(buf, []byte(text))
+	return buf.String()
+}
+
+// decodeXML returns the raw tokens of a document with their start offsets,
+// offsets has one more item, the end of the last token
+func decodeXML(body []byte) ([]xml.Token, []int, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	var tokens []xml.Token
+	offsets := []int{0}
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			return tokens, offsets, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		tokens = append(tokens, xml.CopyToken(token))
+		offsets = append(offsets, int(decoder.InputOffset()))
+	}
+}
@@ -0,0 +1,118 @@
+package fuzz
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPartBuilders(t *testing.T) {
+	soap := `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><m:GetUser xmlns:m="urn:users"><m:Id>1</m:Id><m:Name>a &amp; b</m:Name></m:GetUser></soap:Body></soap:Envelope>`
+	tests := []struct {
+		part        string
+		url         string
+		contentType string
+		body        string
+		// key is mutated to value
+		key, value string
+		// keys are the parsed parameters
+		keys     []string
+		expected string
+	}{
+		{
+			part: "query", url: "http://example.com/?a=%2F&flag&b=x+y",
+			key: "b", value: "1'", keys: []string{"a", "flag", "b"},
+			expected: "a=%2F&flag&b=1%27",
+		},
+		{
+			part: "query", url: "http://example.com/?a=%2F&flag&b=x+y",
+			key: "flag", value: "<", keys: []string{"a", "flag", "b"},
+			expected: "a=%2F&flag=%3C&b=x+y",
+		},
+		{
+			part: "form", url: "http://example.com/", body: "user=a%20b&pass=x\r\n",
+			key: "pass", value: "y&z", keys: []string{"user", "pass"},
+			expected: "user=a%20b&pass=y%26z\r\n",
+		},
+		{
+			part: "xml", url: "http://example.com/", body: soap,
+			key: "Envelope.Body.GetUser.Name", value: "<x>", keys: []string{"Envelope.Body.GetUser.Id", "Envelope.Body.GetUser.Name"},
+			expected: strings.Replace(soap, "a &amp; b", "&lt;x&gt;", 1),
+		},
+		{
+			part: "path", url: "http://example.com/api/users/1",
+			key: "3", value: "2", keys: []string{"1", "2", "3"},
+			expected: "/api/users/2",
+		},
+		{
+			part: "json", url: "http://example.com/", body: `{"user":{"id":1,"tags":["a"]}}`,
+			key: "user.tags.0", value: "b", keys: []string{"user.id", "user.tags.0"},
+			expected: `{"user":{"id":1,"tags":["b"]}}`,
+		},
+		{
+			part: "body", url: "http://example.com/", contentType: "application/x-www-form-urlencoded", body: "a=1",
+			key: "a", value: "2", keys: []string{"a"},
+			expected: "a=2",
+		},
+	}
+	for _, test := range tests {
+		base, err := http.NewRequest("POST", test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.contentType != "" {
+			base.Header.Set("Content-Type", test.contentType)
+		}
+		part, err := partBuilders[test.part](base, []byte(test.body))
+		if err != nil || part == nil {
+			t.Fatalf("%s: could not parse the part: %v", test.part, err)
+		}
+		var keys []string
+		params := part.clone()
+		for i, p := range params {
+			keys = append(keys, p.key)
+			if p.key == test.key {
+				params[i].value = test.value
+			}
+		}
+		if strings.Join(keys, ",") != strings.Join(test.keys, ",") {
+			t.Errorf("%s: expected the keys %v, got %v", test.part, test.keys, keys)
+		}
+
+		req, err := part.build(params)
+		if err != nil {
+			t.Fatalf("%s: %s", test.part, err)
+		}
+		var got string
+		switch test.part {
+		case "query":
+			got = req.URL.RawQuery
+		case "path":
+			got = req.URL.Path
+		default:
+			body, _ := ioutil.ReadAll(req.Body)
+			got = string(body)
+			if req.ContentLength != int64(len(body)) {
+				t.Errorf("%s: content length %d of a %d bytes body", test.part, req.ContentLength, len(body))
+			}
+		}
+		if got != test.expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", test.part, test.expected, got)
+		}
+
+		// unchanged parameters rebuild the original request
+		req, _ = part.build(part.clone())
+		switch test.part {
+		case "query":
+			if req.URL.RawQuery != base.URL.RawQuery {
+				t.Errorf("query: expected the original query, got %s", req.URL.RawQuery)
+			}
+		case "form", "xml", "body":
+			if body, _ := ioutil.ReadAll(req.Body); string(body) != test.body {
+				t.Errorf("%s: expected the original body, got %s", test.part, body)
+			}
+		}
+	}
+}
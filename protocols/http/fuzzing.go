@@ -0,0 +1,85 @@
+package http
+
+import (
+	"io/ioutil"
+
+	"github.com/chainreactors/neutron/common"
+	"github.com/chainreactors/neutron/protocols"
+	"github.com/chainreactors/neutron/protocols/http/fuzz"
+)
+
+// executeFuzzingRules sends every mutation of the generated base request, the operators
+// are executed on each mutated response. It returns true if any mutation matched.
+func (r *Request) executeFuzzingRules(input *protocols.ScanContext, base *generatedRequest, previous map[string]interface{}, callback protocols.OutputEventCallback, reqcount int) (bool, error) {
+	if base.cancel != nil {
+		base.cancel()
+	}
+	// the base request is never sent, every mutation takes its own slot
+	base.slot.free()
+	var body []byte
+	if base.request.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(base.request.Body)
+		base.request.Body.Close()
+		if err != nil {
+			return false, err
+		}
+	}
+
+	values := base.Vars()
+	var gotMatches bool
+	var requestErr error
+	for _, rule := range r.Fuzzing {
+		payloads := make([]string, 0, len(rule.Fuzz))
+		for _, payload := range rule.Fuzz {
+			evaluated, err := common.Evaluate(payload, values)
+			if err != nil {
+				return gotMatches, err
+			}
+			payloads = append(payloads, evaluated)
+		}
+
+		err := rule.Execute(base.request, body, payloads, func(mutation *fuzz.Mutation) bool {
+			generated := &generatedRequest{
+				original:  r,
+				meta:      base.meta,
+				request:   mutation.Request,
+				transport: base.transport,
+				session:   base.session,
+				token:     base.token,
+				timeout:   base.timeout,
+				slot:      acquireSlot(r.options.Options.RateLimit, mutation.Request.URL.Host),
+				dynamicValues: common.MergeMaps(base.dynamicValues, map[string]interface{}{
+					"fuzz_part":  mutation.Part,
+					"fuzz_key":   mutation.Key,
+					"fuzz_value": mutation.Payload,
+				}),
+			}
+			// every mutation gets its own timeout, the base request is never sent
+			r.setContext(generated)
+			var matched bool
+			err := r.execute(input, generated, previous, func(event *protocols.InternalWrappedEvent) {
+				if event.OperatorsResult != nil && event.OperatorsResult.Matched {
+					matched = true
+				}
+				callback(event)
+			}, reqcount)
+			generated.slot.free()
+			if err != nil {
+				common.Debug("fuzzing %s %s failed, %s", mutation.Part, mutation.Key, err.Error())
+				requestErr = err
+			}
+			if matched {
+				gotMatches = true
+			}
+			return gotMatches && r.StopAtFirstMatch
+		})
+		if err != nil {
+			return gotMatches, err
+		}
+		if gotMatches && r.StopAtFirstMatch {
+			break
+		}
+	}
+	return gotMatches, requestErr
+}
@@ -0,0 +1,70 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/chainreactors/neutron/operators"
+	"github.com/chainreactors/neutron/protocols"
+)
+
+func TestRequestHooks(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.URL.Path+" "+r.Header.Get("X-Signature"))
+		w.Write([]byte("welcome"))
+	}))
+	defer server.Close()
+
+	request := &Request{
+		Path:   []string{"{{BaseURL}}/a", "{{BaseURL}}/b"},
+		Method: "GET",
+		Operators: operators.Operators{
+			// signed is set by the after response hook
+			Matchers: []*operators.Matcher{{Type: "dsl", DSL: []string{`signed == "/a"`}}},
+		},
+	}
+	vetoed := errors.New("vetoed")
+	var calls []string
+	options := &protocols.ExecuterOptions{Options: &protocols.Options{Timeout: 5}}
+	options.AddBeforeRequest(func(req *http.Request) error {
+		calls = append(calls, "sign "+req.URL.Path)
+		req.Header.Set("X-Signature", "signed")
+		return nil
+	}, func(req *http.Request) error {
+		calls = append(calls, "veto "+req.URL.Path)
+		if req.URL.Path == "/b" {
+			return vetoed
+		}
+		return nil
+	})
+	options.AddAfterResponse(func(resp *http.Response, event protocols.InternalEvent) {
+		calls = append(calls, "response "+resp.Request.URL.Path)
+		event["signed"] = resp.Request.URL.Path
+	})
+	if err := request.Compile(options); err != nil {
+		t.Fatal(err)
+	}
+
+	var matches int
+	err := request.ExecuteWithResults(protocols.NewScanContext(server.URL, nil), nil, map[string]interface{}{}, func(event *protocols.InternalWrappedEvent) {
+		if event.OperatorsResult != nil && event.OperatorsResult.Matched {
+			matches++
+		}
+	})
+	if err != vetoed {
+		t.Errorf("expected the veto error, got %v", err)
+	}
+	if expected := []string{"/a signed"}; !reflect.DeepEqual(received, expected) {
+		t.Errorf("expected the vetoed request not to be sent, got %v", received)
+	}
+	if expected := []string{"sign /a", "veto /a", "response /a", "sign /b", "veto /b"}; !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected the hooks %v, got %v", expected, calls)
+	}
+	if matches != 1 {
+		t.Errorf("expected the after response hook variable to match, got %d matches", matches)
+	}
+}
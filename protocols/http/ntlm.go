@@ -0,0 +1,221 @@
+package http
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// ntlm negotiate flags
+const (
+	ntlmNegotiateUnicode             = 0x00000001
+	ntlmRequestTarget                = 0x00000004
+	ntlmNegotiateNTLM                = 0x00000200
+	ntlmNegotiateAlwaysSign          = 0x00008000
+	ntlmNegotiateExtendedSessionSec  = 0x00080000
+	ntlmNegotiateTargetInfo          = 0x00800000
+	ntlmNegotiate128                 = 0x20000000
+	ntlmNegotiate56                  = 0x80000000
+	ntlmDefaultFlags                 = ntlmNegotiateUnicode | ntlmRequestTarget | ntlmNegotiateNTLM | ntlmNegotiateAlwaysSign | ntlmNegotiateExtendedSessionSec | ntlmNegotiateTargetInfo | ntlmNegotiate128 | ntlmNegotiate56
+	ntlmAvTimestamp                  = 7
+	ntlmAvEOL                        = 0
+	ntlmSignature                    = "NTLMSSP\x00"
+	ntlmChallengeMinLength           = 48
+	ntlmFiletimeEpochDelta           = 116444736000000000
+	ntlmAuthenticateMessageHeaderLen = 64
+)
+
+// ntlmNegotiateMessage returns the type 1 message of the handshake
+func ntlmNegotiateMessage() []byte {
+	msg := make([]byte, 32)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 1)
+	binary.LittleEndian.PutUint32(msg[12:], ntlmDefaultFlags)
+	return msg
+}
+
+// ntlmChallenge is the parsed type 2 message of the server
+type ntlmChallenge struct {
+	flags           uint32
+	serverChallenge []byte
+	targetInfo      []byte
+}
+
+func parseNTLMChallenge(msg []byte) (*ntlmChallenge, error) {
+	if len(msg) < ntlmChallengeMinLength || !bytes.HasPrefix(msg, []byte(ntlmSignature)) || binary.LittleEndian.Uint32(msg[8:]) != 2 {
+		return nil, errors.New("invalid ntlm challenge message")
+	}
+	challenge := &ntlmChallenge{
+		flags:           binary.LittleEndian.Uint32(msg[20:]),
+		serverChallenge: msg[24:32],
+	}
+	length := int(binary.LittleEndian.Uint16(msg[40:]))
+	offset := int(binary.LittleEndian.Uint32(msg[44:]))
+	if length > 0 {
+		if offset+length > len(msg) {
+			return nil, errors.New("invalid ntlm target info")
+		}
+		challenge.targetInfo = msg[offset : offset+length]
+	}
+	return challenge, nil
+}
+
+// timestamp returns the server timestamp of the target info, if any
+func (c *ntlmChallenge) timestamp() ([]byte, bool) {
+	info := c.targetInfo
+	for len(info) >= 4 {
+		id := binary.LittleEndian.Uint16(info)
+		length := int(binary.LittleEndian.Uint16(info[2:]))
+		if id == ntlmAvEOL || 4+length > len(info) {
+			break
+		}
+		if id == ntlmAvTimestamp && length == 8 {
+			return info[4:12], true
+		}
+		info = info[4+length:]
+	}
+	return nil, false
+}
+
+// ntlmAuthenticateMessage returns the NTLMv2 type 3 message answering the challenge
+func ntlmAuthenticateMessage(challenge *ntlmChallenge, domain, username, password string) ([]byte, error) {
+	clientChallenge := make([]byte, 8)
+	if _, err := rand.Read(clientChallenge); err != nil {
+		return nil, err
+	}
+	timestamp, ok := challenge.timestamp()
+	if !ok {
+		timestamp = make([]byte, 8)
+		binary.LittleEndian.PutUint64(timestamp, uint64(time.Now().UnixNano()/100+ntlmFiletimeEpochDelta))
+	}
+	responseKey := ntlmv2Hash(domain, username, password)
+	ntResponse := ntlmv2Response(responseKey, challenge.serverChallenge, clientChallenge, timestamp, challenge.targetInfo)
+	lmResponse := append(hmacMD5(responseKey, challenge.serverChallenge, clientChallenge), clientChallenge...)
+
+	payloads := [][]byte{lmResponse, ntResponse, utf16le(domain), utf16le(username), utf16le(""), nil}
+	msg := make([]byte, ntlmAuthenticateMessageHeaderLen)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 3)
+	offset := ntlmAuthenticateMessageHeaderLen
+	for i, payload := range payloads {
+		field := msg[12+8*i:]
+		binary.LittleEndian.PutUint16(field, uint16(len(payload)))
+		binary.LittleEndian.PutUint16(field[2:], uint16(len(payload)))
+		binary.LittleEndian.PutUint32(field[4:], uint32(offset))
+		offset += len(payload)
+	}
+	binary.LittleEndian.PutUint32(msg[60:], challenge.flags&ntlmDefaultFlags)
+	for _, payload := range payloads {
+		msg = append(msg, payload...)
+	}
+	return msg, nil
+}
+
+// ntlmv2Hash is the NTLMv2 response key: HMAC_MD5(MD4(password), UPPER(user) + domain)
+func ntlmv2Hash(domain, username, password string) []byte {
+	ntHash := md4Sum(utf16le(password))
+	return hmacMD5(ntHash[:], utf16le(strings.ToUpper(username)+domain))
+}
+
+// ntlmv2Response returns NTProofStr + blob
+func ntlmv2Response(responseKey, serverChallenge, clientChallenge, timestamp, targetInfo []byte) []byte {
+	blob := &bytes.Buffer{}
+	blob.Write([]byte{1, 1, 0, 0, 0, 0, 0, 0})
+	blob.Write(timestamp)
+	blob.Write(clientChallenge)
+	blob.Write([]byte{0, 0, 0, 0})
+	blob.Write(targetInfo)
+	blob.Write([]byte{0, 0, 0, 0})
+	proof := hmacMD5(responseKey, serverChallenge, blob.Bytes())
+	return append(proof, blob.Bytes()...)
+}
+
+func hmacMD5(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(md5.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+func utf16le(s string) []byte {
+	encoded := utf16.Encode([]rune(s))
+	buf := make([]byte, 2*len(encoded))
+	for i, r := range encoded {
+		binary.LittleEndian.PutUint16(buf[2*i:], r)
+	}
+	return buf
+}
+
+var (
+	md4Shifts = [3][4]int{{3, 7, 11, 19}, {3, 5, 9, 13}, {3, 9, 11, 15}}
+	md4Orders = [3][16]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+		{0, 4, 8, 12, 1, 5, 9, 13, 2, 6, 10, 14, 3, 7, 11, 15},
+		{0, 8, 4, 12, 2, 10, 6, 14, 1, 9, 5, 13, 3, 11, 7, 15},
+	}
+	md4Constants = [3]uint32{0, 0x5a827999, 0x6ed9eba1}
+)
+
+func md4Round(round int, x, y, z uint32) uint32 {
+	switch round {
+	case 0:
+		return x&y | ^x&z
+	case 1:
+		return x&y | x&z | y&z
+	default:
+		return x ^ y ^ z
+	}
+}
+
+// md4Sum is the RFC 1320 MD4 digest, only needed for the ntlm hash
+func md4Sum(data []byte) [16]byte {
+	a, b, c, d := uint32(0x67452301), uint32(0xefcdab89), uint32(0x98badcfe), uint32(0x10325476)
+
+	msg := append(append([]byte{}, data...), 0x80)
+	for len(msg)%64 != 56 {
+		msg = append(msg, 0)
+	}
+	var length [8]byte
+	binary.LittleEndian.PutUint64(length[:], uint64(len(data))*8)
+	msg = append(msg, length[:]...)
+
+	var x [16]uint32
+	for block := 0; block < len(msg); block += 64 {
+		for i := range x {
+			x[i] = binary.LittleEndian.Uint32(msg[block+4*i:])
+		}
+		aa, bb, cc, dd := a, b, c, d
+		for round := 0; round < 3; round++ {
+			for i, k := range md4Orders[round] {
+				s := md4Shifts[round][i%4]
+				add := x[k] + md4Constants[round]
+				switch i % 4 {
+				case 0:
+					a = bits.RotateLeft32(a+md4Round(round, b, c, d)+add, s)
+				case 1:
+					d = bits.RotateLeft32(d+md4Round(round, a, b, c)+add, s)
+				case 2:
+					c = bits.RotateLeft32(c+md4Round(round, d, a, b)+add, s)
+				case 3:
+					b = bits.RotateLeft32(b+md4Round(round, c, d, a)+add, s)
+				}
+			}
+		}
+		a, b, c, d = a+aa, b+bb, c+cc, d+dd
+	}
+
+	var sum [16]byte
+	binary.LittleEndian.PutUint32(sum[0:], a)
+	binary.LittleEndian.PutUint32(sum[4:], b)
+	binary.LittleEndian.PutUint32(sum[8:], c)
+	binary.LittleEndian.PutUint32(sum[12:], d)
+	return sum
+}
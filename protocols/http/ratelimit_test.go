@@ -0,0 +1,86 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chainreactors/neutron/operators"
+	"github.com/chainreactors/neutron/protocols"
+	"github.com/chainreactors/neutron/protocols/http/fuzz"
+)
+
+func TestRateLimitTimeout(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte("welcome"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		request *Request
+		limit   *protocols.RateLimit
+		hits    int32
+		// elapsed is the minimum duration of the rate limit waits
+		elapsed time.Duration
+	}{
+		{
+			// the second request waits 2.5s for the limit, longer than its timeout
+			name:    "timeout",
+			request: &Request{Path: []string{"{{BaseURL}}/a", "{{BaseURL}}/b"}, Method: "GET"},
+			limit:   &protocols.RateLimit{PerHostRPS: 0.4},
+			hits:    2,
+			elapsed: 2 * time.Second,
+		},
+		{
+			name:    "annotated timeout",
+			request: &Request{Raw: []string{"@timeout: 1s\nGET /a HTTP/1.1\nHost: {{Hostname}}\n\n", "@timeout: 1s\nGET /b HTTP/1.1\nHost: {{Hostname}}\n\n"}},
+			limit:   &protocols.RateLimit{PerHostRPS: 0.4},
+			hits:    2,
+			elapsed: 2 * time.Second,
+		},
+		{
+			// the base request isn't sent, every mutation waits for the limit
+			name: "fuzzing",
+			request: &Request{
+				Path:    []string{"{{BaseURL}}/?a=1&b=2&c=3"},
+				Method:  "GET",
+				Fuzzing: []*fuzz.Rule{{Part: "query", Fuzz: []string{"x"}}},
+			},
+			limit:   &protocols.RateLimit{PerHostRPS: 10, PerHostMaxInFlight: 1},
+			hits:    3,
+			elapsed: 250 * time.Millisecond,
+		},
+	}
+	for _, test := range tests {
+		atomic.StoreInt32(&hits, 0)
+		test.request.Operators = operators.Operators{
+			Matchers: []*operators.Matcher{{Type: "word", Part: "body", Words: []string{"welcome"}}},
+		}
+		options := &protocols.ExecuterOptions{Options: &protocols.Options{Timeout: 1, RateLimit: test.limit}}
+		if err := test.request.Compile(options); err != nil {
+			t.Fatal(err)
+		}
+		var matches int32
+		start := time.Now()
+		err := test.request.ExecuteWithResults(protocols.NewScanContext(server.URL, nil), nil, map[string]interface{}{}, func(event *protocols.InternalWrappedEvent) {
+			if event.OperatorsResult != nil && event.OperatorsResult.Matched {
+				matches++
+			}
+		})
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if count := atomic.LoadInt32(&hits); count != test.hits || matches != test.hits {
+			t.Errorf("%s: expected %d matched requests, got %d requests and %d matches", test.name, test.hits, count, matches)
+		}
+		if elapsed := time.Since(start); elapsed < test.elapsed {
+			t.Errorf("%s: expected the rate limit to wait %s, took %s", test.name, test.elapsed, elapsed)
+		}
+	}
+}
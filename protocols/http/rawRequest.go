@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"github.com/chainreactors/neutron/common"
+	"github.com/chainreactors/neutron/protocols"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -135,6 +136,34 @@ read_line:
 	return rawRequest, nil
 }
 
+// ParseRawRequest parses a captured raw http request into a base request.
+// baseURL is the scheme and host the request was sent to, when empty the Host header is used over http.
+func ParseRawRequest(request, baseURL string) (*protocols.BaseRequest, error) {
+	if !strings.Contains(request, "\r\n") {
+		request = strings.Replace(request, "\n", "\r\n", -1)
+	}
+	if !strings.Contains(request, "\r\n\r\n") {
+		request += "\r\n\r\n"
+	}
+	if baseURL == "" {
+		baseURL = "http://"
+	}
+	raw, err := parseRaw(request, baseURL, false)
+	if err != nil {
+		return nil, err
+	}
+	fullURL := raw.FullURL
+	if strings.HasPrefix(raw.Path, "http") {
+		fullURL = raw.Path
+	} else if strings.HasPrefix(raw.FullURL, "http:///") {
+		if raw.Headers["Host"] == "" {
+			return nil, fmt.Errorf("could not find the host of the request")
+		}
+		fullURL = "http://" + raw.Headers["Host"] + raw.Path
+	}
+	return protocols.NewBaseRequest(raw.Method, fullURL, raw.Headers, raw.Data), nil
+}
+
 func (raw rawRequest) makeRequest() (*http.Request, error) {
 	//var body io.ReadCloser
 	//
@@ -0,0 +1,91 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/chainreactors/neutron/protocols"
+)
+
+// maxRedirectBodySize is the maximum size of a recorded redirect body
+const maxRedirectBodySize = 1 << 20
+
+type redirectChainKey struct{}
+
+// redirectHop is an intermediate response of a followed redirect
+type redirectHop struct {
+	Method     string
+	URL        string
+	Proto      string
+	Status     string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// redirectChain records the followed redirects of a request, hops are added
+// sequentially by the client so no locking is needed
+type redirectChain struct {
+	hops []*redirectHop
+}
+
+// withRedirectChain attaches a new redirect chain to the request context
+func withRedirectChain(req *http.Request) (*http.Request, *redirectChain) {
+	chain := &redirectChain{}
+	return req.WithContext(context.WithValue(req.Context(), redirectChainKey{}, chain)), chain
+}
+
+// recordRedirect records the response that led to req, it is called by CheckRedirect
+// once the redirect is going to be followed
+func recordRedirect(req *http.Request) {
+	chain, ok := req.Context().Value(redirectChainKey{}).(*redirectChain)
+	if !ok || req.Response == nil {
+		return
+	}
+	resp := req.Response
+	hop := &redirectHop{
+		Proto:      resp.Proto,
+		Status:     resp.Status,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+	}
+	if resp.Request != nil {
+		hop.Method = resp.Request.Method
+		hop.URL = resp.Request.URL.String()
+	}
+	if resp.Body != nil {
+		// the read part is put back, the client still drains and closes the body itself
+		hop.Body, _ = ioutil.ReadAll(io.LimitReader(resp.Body, maxRedirectBodySize))
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(hop.Body), resp.Body), resp.Body}
+	}
+	chain.hops = append(chain.hops, hop)
+}
+
+// toDSLMap adds the redirect variables to the event, redirect_status_code_N and redirect_location_N
+// start at 1 for the first hop, they don't collide with the req-condition history variables
+func (c *redirectChain) toDSLMap(data protocols.InternalEvent) {
+	var raw strings.Builder
+	for i, hop := range c.hops {
+		data[fmt.Sprintf("redirect_location_%d", i+1)] = hop.Header.Get("Location")
+		data[fmt.Sprintf("redirect_status_code_%d", i+1)] = hop.StatusCode
+
+		raw.WriteString(fmt.Sprintf("%s %s\r\n", hop.Method, hop.URL))
+		raw.WriteString(fmt.Sprintf("%s %s\r\n", hop.Proto, hop.Status))
+		for k, v := range hop.Header {
+			raw.WriteString(fmt.Sprintf("%s: %s\r\n", k, strings.Join(v, " ")))
+		}
+		raw.WriteString("\r\n")
+		raw.Write(hop.Body)
+		raw.WriteString("\r\n\r\n")
+	}
+	data["redirect_chain"] = raw.String()
+	data["redirect_count"] = len(c.hops)
+}
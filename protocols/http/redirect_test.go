@@ -0,0 +1,77 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chainreactors/neutron/operators"
+	"github.com/chainreactors/neutron/protocols"
+)
+
+func TestRedirectChain(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusFound)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusMovedPermanently)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer server.Close()
+
+	request := &Request{
+		Path:         []string{"{{BaseURL}}/a", "{{BaseURL}}/c"},
+		Method:       "GET",
+		Redirects:    true,
+		ReqCondition: true,
+		Operators: operators.Operators{
+			// the numbered dsl variable turns the req-condition history on
+			Matchers: []*operators.Matcher{{Type: "dsl", DSL: []string{"status_code_1 == 200"}}},
+		},
+	}
+	options := &protocols.ExecuterOptions{Options: &protocols.Options{Timeout: 5}}
+	var events []protocols.InternalEvent
+	options.AddAfterResponse(func(resp *http.Response, event protocols.InternalEvent) {
+		events = append(events, event)
+	})
+	if err := request.Compile(options); err != nil {
+		t.Fatal(err)
+	}
+	err := request.ExecuteWithResults(protocols.NewScanContext(server.URL, nil), nil, map[string]interface{}{}, func(event *protocols.InternalWrappedEvent) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+
+	first := events[0]
+	tests := []struct {
+		event protocols.InternalEvent
+		key   string
+		value interface{}
+	}{
+		{first, "redirect_count", 2},
+		{first, "redirect_status_code_1", 302},
+		{first, "redirect_location_1", "/b"},
+		{first, "redirect_status_code_2", 301},
+		{first, "redirect_location_2", "/c"},
+		// the req-condition history keeps the status code of the final response
+		{first, "status_code_1", 200},
+		{events[1], "status_code_1", 200},
+		{events[1], "status_code_2", 200},
+		{events[1], "redirect_status_code_1_1", 302},
+		{events[1], "redirect_count_1", 2},
+	}
+	for _, test := range tests {
+		if value := test.event[test.key]; value != test.value {
+			t.Errorf("%s: expected %v, got %v", test.key, test.value, value)
+		}
+	}
+	if _, ok := events[1]["redirect_status_code_1"]; ok {
+		t.Error("the second request didn't redirect, it should not have redirect_status_code_1")
+	}
+}
@@ -9,11 +9,13 @@ import (
 	"github.com/chainreactors/neutron/common/dsl"
 	"github.com/chainreactors/neutron/operators"
 	"github.com/chainreactors/neutron/protocols"
+	"github.com/chainreactors/neutron/protocols/http/fuzz"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -37,6 +39,12 @@ type Request struct {
 	Method string `json:"method" yaml:"method"`
 	// Body is an optional parameter which contains the request body for POST methods, etc
 	Body string `json:"body" yaml:"body"`
+	// BodyForm is an url encoded form body, keys and values support {{variables}}
+	BodyForm map[string]string `json:"body-form,omitempty" yaml:"body-form,omitempty"`
+	// BodyJSON is a json body, string keys and values support {{variables}}
+	BodyJSON interface{} `json:"body-json,omitempty" yaml:"body-json,omitempty"`
+	// BodyMultipart are the fields of a multipart/form-data body, every field supports {{variables}}
+	BodyMultipart []*MultipartField `json:"body-multipart,omitempty" yaml:"body-multipart,omitempty"`
 	// Path contains the path/s for the request variables
 	Payloads map[string]interface{} `json:"payloads" yaml:"payloads"`
 	// Headers contains headers to send with the request
@@ -65,6 +73,25 @@ type Request struct {
 	//   StopAtFirstMatch stops the execution of the requests and template as soon as a match is found.
 	StopAtFirstMatch bool `json:"stop-at-first-match" yaml:"stop-at-first-match"`
 
+	// Fuzzing are the rules mutating the generated requests, operators are executed on every mutation.
+	// Without path or raw requests the rules are applied on {{BaseURL}}.
+	Fuzzing []*fuzz.Rule `json:"fuzzing,omitempty" yaml:"fuzzing,omitempty"`
+
+	// Analyzer re-sends the request with several [SLEEPTIME] delays and fits the response time against them.
+	// Without matchers the analyzer alone decides the match.
+	Analyzer *Analyzer `json:"analyzer,omitempty" yaml:"analyzer,omitempty"`
+
+	// Auth is the http authentication of the request, it overrides the scan level auth
+	Auth *protocols.AuthConfig `json:"auth,omitempty" yaml:"auth,omitempty"`
+
+	// Favicon fetches the icons of the page, favicon_mmh3, favicon_md5, favicon_url, title and body_hash
+	// are added to the response variables for the fingerprint matchers
+	Favicon bool `json:"favicon,omitempty" yaml:"favicon,omitempty"`
+
+	// ProtocolVersion is the http version of the request: h1, h2 (over tls) or h2c (cleartext prior knowledge).
+	// Default keeps the client transport.
+	ProtocolVersion string `json:"protocol-version,omitempty" yaml:"protocol-version,omitempty"`
+
 	IterateAll        bool                 `yaml:"iterate-all,omitempty" json:"iterate-all,omitempty"`
 	generator         *protocols.Generator // optional, only enabled when using payloads
 	httpClient        *http.Client
@@ -73,6 +100,10 @@ type Request struct {
 	attackType        protocols.Type
 	totalRequests     int
 
+	protocolVersion string
+	cookieJar       http.CookieJar
+	// favicons caches the favicon variables by scheme://host
+	favicons   sync.Map
 	globalVars map[string]interface{}
 	options    *protocols.ExecuterOptions
 	//Result            *protocols.Result
@@ -85,6 +116,18 @@ func (r *Request) Type() protocols.ProtocolType {
 
 // Match matches a generic data response again a given matcher
 func (r *Request) Match(data map[string]interface{}, matcher *operators.Matcher) (bool, []string) {
+	return r.match(data, matcher, 0)
+}
+
+// matchFunc returns the match function of the response of the reqcount request
+func (r *Request) matchFunc(reqcount int) func(data map[string]interface{}, matcher *operators.Matcher) (bool, []string) {
+	return func(data map[string]interface{}, matcher *operators.Matcher) (bool, []string) {
+		return r.match(data, matcher, reqcount)
+	}
+}
+
+// match matches the response of the reqcount request, 0 if unknown, the unindexed parts are its parts
+func (r *Request) match(data map[string]interface{}, matcher *operators.Matcher, reqcount int) (bool, []string) {
 	item, ok := r.getMatchPart(matcher.Part, data)
 	if !ok {
 		return false, []string{}
@@ -111,24 +154,46 @@ func (r *Request) Match(data map[string]interface{}, matcher *operators.Matcher)
 		return matcher.ResultWithMatchedSnippet(matcher.MatchBinary(item))
 	case operators.DSLMatcher:
 		return matcher.Result(matcher.MatchDSL(data)), nil
-
+	case operators.SimilarityMatcher:
+		if reqcount > 0 && partIndex(matcher.Part, reqcount) == partIndex(matcher.Compare, reqcount) {
+			// e.g. body compared to body_1 on the first request, the response would be compared with itself
+			return false, []string{}
+		}
+		compared, ok := r.getMatchPart(matcher.Compare, data)
+		if !ok {
+			return false, []string{}
+		}
+		return matcher.Result(matcher.MatchSimilarity(item, compared)), nil
 	}
 	return false, []string{}
 }
 
+// partIndex returns the index of the request of a part, the unindexed parts are of the reqcount request
+func partIndex(part string, reqcount int) int {
+	if index, ok := operators.PartIndex(part); ok {
+		return index
+	}
+	return reqcount
+}
+
 // Extract performs extracting operation for an extractor on model and returns true or false.
 func (r *Request) Extract(data map[string]interface{}, extractor *operators.Extractor) map[string]struct{} {
+	return operators.ExtractedSet(r.ExtractValues(data, extractor))
+}
+
+// ExtractValues performs extracting operation for an extractor on model and returns the values in order.
+func (r *Request) ExtractValues(data map[string]interface{}, extractor *operators.Extractor) []operators.Extracted {
 	item, ok := r.getMatchPart(extractor.Part, data)
 	if !ok {
 		return nil
 	}
 	switch extractor.GetType() {
 	case operators.RegexExtractor:
-		return extractor.ExtractRegex(item)
+		return extractor.ExtractRegexValues(item)
 	case operators.KValExtractor:
-		return extractor.ExtractKval(data)
+		return extractor.ExtractKvalValues(data)
 	case operators.DSLExtractor:
-		return extractor.ExtractDSL(data)
+		return extractor.ExtractDSLValues(data)
 		//case operators.XPathExtractor:
 		//	return extractor.ExtractXPath(item)
 		//case operators.JSONExtractor:
@@ -205,17 +270,24 @@ func (r *Request) MakeResultEventItem(wrapped *protocols.InternalWrappedEvent) *
 	data := &protocols.ResultEvent{
 		TemplateID: common.ToString(wrapped.InternalEvent["template-id"]),
 		//Info:             wrapped.InternalEvent["template-info"].(map[string]interface{}),
-		Type:             "http",
-		Host:             common.ToString(wrapped.InternalEvent["host"]),
-		Matched:          common.ToString(wrapped.InternalEvent["matched"]),
-		Metadata:         wrapped.OperatorsResult.PayloadValues,
-		ExtractedResults: wrapped.OperatorsResult.OutputExtracts,
-		Timestamp:        time.Now(),
-		IP:               common.ToString(wrapped.InternalEvent["ip"]),
+		Type:              "http",
+		Host:              common.ToString(wrapped.InternalEvent["host"]),
+		Matched:           common.ToString(wrapped.InternalEvent["matched"]),
+		Metadata:          wrapped.OperatorsResult.PayloadValues,
+		ExtractedResults:  wrapped.OperatorsResult.OutputExtracts,
+		StructuredResults: wrapped.OperatorsResult.StructuredExtracts,
+		Timestamp:         time.Now(),
+		IP:                common.ToString(wrapped.InternalEvent["ip"]),
 	}
 	return data
 }
 
+// SetCookieJar shares a cookie jar with the other requests of the template, it is used with
+// cookie-reuse and must be called before Compile
+func (r *Request) SetCookieJar(jar http.CookieJar) {
+	r.cookieJar = jar
+}
+
 // requests returns the total number of requests the YAML rule will perform
 func (r *Request) Requests() int {
 	if r.generator != nil {
@@ -238,9 +310,15 @@ func (r *Request) Compile(options *protocols.ExecuterOptions) error {
 		MaxRedirects:    r.MaxRedirects,
 		FollowRedirects: r.Redirects || r.HostRedirects,
 		CookieReuse:     r.CookieReuse,
+		CookieJar:       r.cookieJar,
+		Scope:           options.Options.Scope,
+		RateLimit:       options.Options.RateLimit,
 	}
 	r.httpClient = createClient(connectionConfiguration)
 
+	if err := r.validateBody(); err != nil {
+		return err
+	}
 	if r.Body != "" && !strings.Contains(r.Body, "\r\n") {
 		r.Body = strings.Replace(r.Body, "\n", "\r\n", -1)
 	}
@@ -252,6 +330,46 @@ func (r *Request) Compile(options *protocols.ExecuterOptions) error {
 		}
 	}
 
+	var err error
+	if r.protocolVersion, err = normalizeProtocolVersion(r.ProtocolVersion); err != nil {
+		return err
+	}
+	if r.protocolVersion != "" {
+		// fail at compile time when the protocol is not supported by this build
+		if _, err = getTransport(transportOptions{proto: r.protocolVersion}); err != nil {
+			return err
+		}
+	}
+
+	if r.Auth != nil {
+		if err = r.Auth.Compile(); err != nil {
+			return err
+		}
+	}
+	if options.Options.Auth != nil {
+		if err = options.Options.Auth.Compile(); err != nil {
+			return err
+		}
+	}
+
+	for _, rule := range r.Fuzzing {
+		if err := rule.Compile(); err != nil {
+			return err
+		}
+	}
+	if len(r.Fuzzing) > 0 && len(r.Path) == 0 && len(r.Raw) == 0 {
+		r.Path = []string{"{{BaseURL}}"}
+	}
+
+	if r.Analyzer != nil {
+		if err = r.Analyzer.Compile(); err != nil {
+			return err
+		}
+		if len(r.Matchers) == 0 {
+			r.Matchers = append(r.Matchers, analyzerMatcher())
+		}
+	}
+
 	r.globalVars = map[string]interface{}{
 		"randstr": dsl.RandStr(8),
 		"randnum": dsl.RandNum(4),
@@ -263,6 +381,7 @@ func (r *Request) Compile(options *protocols.ExecuterOptions) error {
 		if compileErr := compiled.Compile(); compileErr != nil {
 			return compileErr
 		}
+		compiled.Explain = options.Options.Explain
 		r.CompiledOperators = compiled
 	}
 
@@ -296,7 +415,6 @@ func (r *Request) Compile(options *protocols.ExecuterOptions) error {
 			}
 		}
 
-		var err error
 		r.generator, err = protocols.NewGenerator(r.Payloads, r.attackType)
 		if err != nil {
 			return err
@@ -320,13 +438,18 @@ func (r *Request) ExecuteRequestWithResults(input *protocols.ScanContext, dynami
 	variablesMap := r.options.Variables.Evaluate(common.MergeMaps(dynamicValues, previous))
 	dynamicValues = common.MergeMaps(variablesMap, dynamicValues)
 	generator := r.newGenerator(input.Payloads)
+	baseURL := input.Input
+	if input.Request != nil && len(r.Fuzzing) == 0 {
+		// path templates are applied relative to the captured request path
+		baseURL = input.Request.BaseURL()
+	}
 	requestCount := 1
 	var requestErr error
 	var gotDynamicValues map[string][]string
 	for {
 		// returns two values, error and skip, which skips the execution for the request instance.
 		executeFunc := func(data string, payloads, dynamicValue map[string]interface{}) (bool, error) {
-			generatedHttpRequest, err := generator.Make(input.Input, data, payloads, dynamicValue, r.globalVars)
+			generatedHttpRequest, err := generator.Make(baseURL, data, payloads, dynamicValue, r.globalVars)
 			if err != nil {
 				if err == io.EOF {
 					return true, nil
@@ -334,18 +457,39 @@ func (r *Request) ExecuteRequestWithResults(input *protocols.ScanContext, dynami
 
 				return true, err
 			}
+			if input.Request != nil {
+				r.applyBaseRequest(generatedHttpRequest.request, input.Request)
+			}
+			// the session and the token are resolved before the slot is taken, the login sends requests to the same host
+			if err := r.authenticate(generatedHttpRequest); err != nil {
+				common.Debug("%s authentication failed, %s", generatedHttpRequest.request.URL, err.Error())
+				requestErr = err
+				return false, nil
+			}
+			// the limit is keyed on the host the request is sent to, raw requests may target another host than the input
+			generatedHttpRequest.slot = acquireSlot(r.options.Options.RateLimit, generatedHttpRequest.request.URL.Host)
+			defer generatedHttpRequest.slot.free()
+			// the timeout starts once the slot is granted, the rate limit waits don't count against it
+			r.setContext(generatedHttpRequest)
 			if generatedHttpRequest.request.Header.Get("User-Agent") == "" {
 				generatedHttpRequest.request.Header.Set("User-Agent", ua)
 			}
 			var gotMatches bool
-			err = r.executeRequest(input, generatedHttpRequest, previous, func(event *protocols.InternalWrappedEvent) {
+			requestCallback := func(event *protocols.InternalWrappedEvent) {
 				// Add the extracts to the dynamic values if any.
 				if event.OperatorsResult != nil {
 					gotMatches = event.OperatorsResult.Matched
 					gotDynamicValues = common.MergeMapsMany(event.OperatorsResult.DynamicValues, gotDynamicValues)
 				}
 				callback(event)
-			}, generator.currentIndex)
+			}
+			if len(r.Fuzzing) > 0 {
+				var fuzzMatches bool
+				fuzzMatches, err = r.executeFuzzingRules(input, generatedHttpRequest, previous, requestCallback, generator.currentIndex)
+				gotMatches = gotMatches || fuzzMatches
+			} else {
+				err = r.execute(input, generatedHttpRequest, previous, requestCallback, generator.currentIndex)
+			}
 
 			// If a variable is unresolved, skip all further requests
 			if err == errStopExecution {
@@ -395,11 +539,28 @@ func (r *Request) ExecuteRequestWithResults(input *protocols.ScanContext, dynami
 }
 
 func (r *Request) executeRequest(input *protocols.ScanContext, request *generatedRequest, previousEvent map[string]interface{}, callback protocols.OutputEventCallback, reqcount int) error {
+	if err := r.options.Options.Scope.CheckURL(request.request.URL); err != nil {
+		r.reportScopeViolation(input, err)
+		return err
+	}
+	if err := r.options.RunBeforeRequest(request.request); err != nil {
+		common.Debug("%s request vetoed, %s", request.request.URL, err.Error())
+		return err
+	}
+	if request.cancel != nil {
+		defer request.cancel()
+	}
+	client := r.client(request)
+	var chain *redirectChain
+	request.request, chain = withRedirectChain(request.request)
 	timeStart := time.Now()
-	resp, err := r.httpClient.Do(request.request)
+	resp, err := r.do(client, request)
 	common.Debug("request %s %v %v", request.request.Method, request.request.URL, request.dynamicValues)
 	common.Dump(request.request)
 	if err != nil {
+		if errors.Is(err, protocols.ErrOutOfScope) {
+			r.reportScopeViolation(input, err)
+		}
 		common.Debug("%s nuclei request failed, %s", request.request.URL, err.Error())
 		return err
 	}
@@ -416,6 +577,12 @@ func (r *Request) executeRequest(input *protocols.ScanContext, request *generate
 	}
 	finalEvent := make(map[string]interface{})
 	outputEvent := r.responseToDSLMap(request.request, resp, input.Input, matchedURL, duration, request.dynamicValues)
+	chain.toDSLMap(outputEvent)
+	if r.Favicon {
+		for k, v := range r.fingerprintVars(input, request, matchedURL, common.ToString(outputEvent["body"])) {
+			outputEvent[k] = v
+		}
+	}
 	for k, v := range previousEvent {
 		finalEvent[k] = v
 	}
@@ -432,12 +599,16 @@ func (r *Request) executeRequest(input *protocols.ScanContext, request *generate
 		}
 	}
 	finalEvent = common.MergeMaps(finalEvent, request.Vars())
+	r.options.RunAfterResponse(resp, finalEvent)
 	common.Dump(finalEvent)
 
 	event := &protocols.InternalWrappedEvent{InternalEvent: finalEvent}
 	if r.CompiledOperators != nil {
 		var ok bool
-		event.OperatorsResult, ok = r.CompiledOperators.Execute(finalEvent, r.Match, r.Extract)
+		event.OperatorsResult, ok = r.CompiledOperators.ExecuteValues(finalEvent, r.matchFunc(reqcount), r.ExtractValues)
+		if event.OperatorsResult != nil {
+			input.LogTrace(event.OperatorsResult.Trace)
+		}
 		if ok && event.OperatorsResult != nil {
 			event.OperatorsResult.PayloadValues = request.dynamicValues
 			event.Results = r.MakeResultEvent(event)
@@ -448,6 +619,22 @@ func (r *Request) executeRequest(input *protocols.ScanContext, request *generate
 	return err
 }
 
+// client returns the http client of the generated request, with its transport override if any
+func (r *Request) client(request *generatedRequest) *http.Client {
+	if request.transport == nil {
+		return r.httpClient
+	}
+	override := *r.httpClient
+	override.Transport = request.transport
+	return &override
+}
+
+// reportScopeViolation reports a blocked out of scope request to the scan context
+func (r *Request) reportScopeViolation(input *protocols.ScanContext, err error) {
+	common.NeutronLog.Warnf("blocked http request, %s", err.Error())
+	input.LogError(err)
+}
+
 // responseToDSLMap converts an HTTP response to a map for use in DSL matching
 func (r *Request) responseToDSLMap(req *http.Request, resp *http.Response, host, matched string, duration time.Duration, extra map[string]interface{}) protocols.InternalEvent {
 	data := make(protocols.InternalEvent, 12+len(extra)+len(resp.Header)+len(resp.Cookies()))
@@ -462,6 +649,7 @@ func (r *Request) responseToDSLMap(req *http.Request, resp *http.Response, host,
 	data["type"] = r.Type().String()
 	data["matched"] = matched
 	data["status_code"] = resp.StatusCode
+	data["proto"] = resp.Proto
 	data["duration"] = duration.Seconds()
 	var respRaw bytes.Buffer
 	respRaw.WriteString(fmt.Sprintf("%s %s\r\n", resp.Proto, resp.Status))
@@ -501,9 +689,9 @@ func (r *Request) GetID() string {
 	return r.ID
 }
 
-func (r *Request) Context() context.Context {
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(r.options.Options.Timeout)*time.Second)
-	return ctx
+// newContext returns the timeout context of a request, cancel must be called once the response is read
+func (r *Request) newContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), time.Duration(r.options.Options.Timeout)*time.Second)
 }
 
 var (
@@ -521,7 +709,7 @@ func (request *Request) NeedsRequestCondition() bool {
 		if checkRequestConditionExpressions(matcher.DSL...) {
 			return true
 		}
-		if checkRequestConditionExpressions(matcher.Part) {
+		if checkRequestConditionExpressions(matcher.Part, matcher.Compare) {
 			return true
 		}
 	}
@@ -554,6 +742,17 @@ type generatedRequest struct {
 	//pipelinedClient *rawhttp.PipelineClient
 	request       *http.Request
 	dynamicValues map[string]interface{}
+	// transport overrides the client transport, set by the @tls-sni and @proxy annotations
+	transport *http.Transport
+	// cancel releases the request context
+	cancel context.CancelFunc
+	// timeout is set by the @timeout annotation, it overrides the default timeout
+	timeout time.Duration
+	// session and token are resolved before the in-flight slot is taken, see authenticate
+	session *protocols.Session
+	token   string
+	// slot is the in-flight slot of the request host
+	slot *slot
 }
 
 func (gr *generatedRequest) Vars() map[string]interface{} {
@@ -2,9 +2,9 @@ package http
 
 import (
 	"context"
+	"fmt"
 	"github.com/chainreactors/neutron/common"
 	"net"
-	"net/http"
 	"regexp"
 	"strings"
 	"time"
@@ -17,17 +17,18 @@ var (
 	// special values:
 	// request.host: takes the value from the host header
 	// target: overiddes with the specific value
-	//reSniAnnotation = regexp.MustCompile(`(?m)^@tls-sni:\s*(.+)\s*$`)
+	reSniAnnotation = regexp.MustCompile(`(?m)^@tls-sni:\s*(.+)\s*$`)
 	// @timeout:duration overrides the input timout with a custom duration
 	reTimeoutAnnotation = regexp.MustCompile(`(?m)^@timeout:\s*(.+)\s*$`)
 	// @once sets the request to be executed only once for a specific URL
-	//reOnceAnnotation = regexp.MustCompile(`(?m)^@once\s*$`)
+	reOnceAnnotation = regexp.MustCompile(`(?m)^@once\s*$`)
+	// @proxy:url overrides the proxy of the request
+	reProxyAnnotation = regexp.MustCompile(`(?m)^@proxy:\s*(.+)\s*$`)
 )
 
 // parseAnnotations and override requests settings
-func (r *Request) parseAnnotations(rawRequest string, request *http.Request) (*http.Request, bool) {
-	// parse request for known ovverride annotations
-	var modified bool
+func (r *Request) parseAnnotations(rawRequest string, generated *generatedRequest) error {
+	request := generated.request
 	// @Host:target
 	if hosts := reHostAnnotation.FindStringSubmatch(rawRequest); len(hosts) > 0 {
 		value := strings.TrimSpace(hosts[1])
@@ -57,37 +58,67 @@ func (r *Request) parseAnnotations(rawRequest string, request *http.Request) (*h
 			hostPort = net.JoinHostPort(hostPort, port)
 		}
 		request.URL.Host = hostPort
-		modified = true
-
 	}
 
+	opts := transportOptions{proto: r.protocolVersion}
 	// @tls-sni:target
-	//if hosts := reSniAnnotation.FindStringSubmatch(rawRequest); len(hosts) > 0 {
-	//	value := strings.TrimSpace(hosts[1])
-	//	value = stringsutil.TrimPrefixAny(value, "http://", "https://")
-	//	if idxForwardSlash := strings.Index(value, "/"); idxForwardSlash >= 0 {
-	//		value = value[:idxForwardSlash]
-	//	}
-	//
-	//	if stringsutil.EqualFoldAny(value, "request.host") {
-	//		value = request.Host
-	//	}
-	//	ctx := context.WithValue(request.Context(), fastdialer.SniName, value)
-	//	request = request.Clone(ctx)
-	//	modified = true
-	//}
+	if hosts := reSniAnnotation.FindStringSubmatch(rawRequest); len(hosts) > 0 {
+		value := strings.TrimSpace(hosts[1])
+		value = common.TrimPrefixAny(value, "http://", "https://")
+		if idxForwardSlash := strings.Index(value, "/"); idxForwardSlash >= 0 {
+			value = value[:idxForwardSlash]
+		}
+
+		if strings.EqualFold(value, "request.host") {
+			value = request.Host
+			if value == "" {
+				value = request.URL.Host
+			}
+		}
+		if host, _, err := net.SplitHostPort(value); err == nil {
+			value = host
+		}
+		opts.sni = value
+	}
+
+	// @proxy:url
+	if proxies := reProxyAnnotation.FindStringSubmatch(rawRequest); len(proxies) > 0 {
+		opts.proxy = strings.TrimSpace(proxies[1])
+	}
+	if opts != (transportOptions{}) {
+		tr, err := getTransport(opts)
+		if err != nil {
+			return fmt.Errorf("could not create request transport: %w", err)
+		}
+		generated.transport = tr
+	}
 
 	// @timeout:duration
 	if duration := reTimeoutAnnotation.FindStringSubmatch(rawRequest); len(duration) > 0 {
-		modified = true
-
 		value := strings.TrimSpace(duration[1])
 		if parsed, err := time.ParseDuration(value); err == nil {
-			ctx, _ := context.WithTimeout(context.Background(), parsed)
-			request = request.WithContext(ctx)
-		} else {
-			request = request.WithContext(request.Context())
+			generated.timeout = parsed
 		}
 	}
-	return request, modified
+	generated.request = request
+	return nil
+}
+
+// setContext sets the timeout context of the generated request, the @timeout annotation overrides
+// the default timeout. It is called once the in-flight slot is held, waiting for it doesn't count.
+func (r *Request) setContext(generated *generatedRequest) {
+	if generated.cancel != nil {
+		return
+	}
+	ctx, cancel := r.newContext()
+	if generated.timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), generated.timeout)
+	}
+	generated.request = generated.request.WithContext(ctx)
+	generated.cancel = cancel
+}
+
+// isOnceRequest returns true if the raw request is annotated with @once
+func isOnceRequest(rawRequest string) bool {
+	return reOnceAnnotation.MatchString(rawRequest)
 }
@@ -0,0 +1,168 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chainreactors/neutron/operators"
+	"github.com/chainreactors/neutron/protocols"
+)
+
+func TestParseAnnotations(t *testing.T) {
+	tests := []struct {
+		annotation string
+		host       string
+		sni        string
+		proxy      string
+		timeout    time.Duration
+	}{
+		{annotation: "@Host: https://other.com", host: "other.com:8443"},
+		{annotation: "@tls-sni: request.host", host: "example.com:8443", sni: "vhost.example.com"},
+		{annotation: "@tls-sni: https://sni.example.com:443/path", host: "example.com:8443", sni: "sni.example.com"},
+		{annotation: "@proxy: http://127.0.0.1:8080", host: "example.com:8443", proxy: "http://127.0.0.1:8080"},
+		{annotation: "@timeout: 50ms", host: "example.com:8443", timeout: 50 * time.Millisecond},
+		{annotation: "@timeout: invalid", host: "example.com:8443"},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest("GET", "https://example.com:8443/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Host = "vhost.example.com:8443"
+		generated := &generatedRequest{request: req}
+		r := &Request{options: &protocols.ExecuterOptions{Options: &protocols.Options{Timeout: 5}}}
+		if err := r.parseAnnotations(test.annotation+"\nGET / HTTP/1.1\nHost: vhost.example.com\n\n", generated); err != nil {
+			t.Errorf("%s: %s", test.annotation, err)
+			continue
+		}
+		if generated.request.URL.Host != test.host {
+			t.Errorf("%s: expected the host %s, got %s", test.annotation, test.host, generated.request.URL.Host)
+		}
+
+		var sni, proxy string
+		if generated.transport != nil {
+			sni = generated.transport.TLSClientConfig.ServerName
+			if generated.transport.Proxy != nil {
+				if proxyURL, _ := generated.transport.Proxy(req); proxyURL != nil {
+					proxy = proxyURL.String()
+				}
+			}
+		}
+		if sni != test.sni || proxy != test.proxy {
+			t.Errorf("%s: expected the sni %q and the proxy %q, got %q and %q", test.annotation, test.sni, test.proxy, sni, proxy)
+		}
+
+		if generated.timeout != test.timeout {
+			t.Errorf("%s: expected a timeout %s, got %s", test.annotation, test.timeout, generated.timeout)
+			continue
+		}
+		// the annotated timeout replaces the default one once the request is sent
+		r.setContext(generated)
+		deadline, ok := generated.request.Context().Deadline()
+		if !ok || generated.cancel == nil {
+			t.Errorf("%s: the request has no deadline", test.annotation)
+			continue
+		}
+		if test.timeout > 0 && time.Until(deadline) > test.timeout {
+			t.Errorf("%s: expected a timeout of %s, got %s", test.annotation, test.timeout, time.Until(deadline))
+		}
+		generated.cancel()
+	}
+}
+
+func TestExecuteAnnotations(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		raw  []string
+		hits map[string]int
+		// matched is the number of matched responses
+		matched int
+	}{
+		{
+			name: "once",
+			raw: []string{
+				"@once\nGET /login HTTP/1.1\nHost: {{Hostname}}\n\n",
+				"GET /item/{{id}} HTTP/1.1\nHost: {{Hostname}}\n\n",
+			},
+			hits:    map[string]int{"/login": 1, "/item/1": 1, "/item/2": 1, "/item/3": 1},
+			matched: 4,
+		},
+		{
+			name:    "timeout",
+			raw:     []string{"@timeout: 50ms\nGET /slow HTTP/1.1\nHost: {{Hostname}}\n\n"},
+			hits:    map[string]int{"/slow": 3},
+			matched: 0,
+		},
+		{
+			name:    "default timeout",
+			raw:     []string{"GET /slow HTTP/1.1\nHost: {{Hostname}}\n\n"},
+			hits:    map[string]int{"/slow": 3},
+			matched: 3,
+		},
+	}
+	for _, test := range tests {
+		mu.Lock()
+		hits = make(map[string]int)
+		mu.Unlock()
+		request := &Request{
+			Raw:      test.raw,
+			Payloads: map[string]interface{}{"id": []string{"1", "2", "3"}},
+			Operators: operators.Operators{
+				Matchers: []*operators.Matcher{{Type: "word", Part: "body", Words: []string{"ok"}}},
+			},
+		}
+		options := &protocols.ExecuterOptions{Options: &protocols.Options{Timeout: 5}}
+		// the contexts of the requests must be released once the responses are read
+		var contexts []context.Context
+		options.AddBeforeRequest(func(req *http.Request) error {
+			contexts = append(contexts, req.Context())
+			return nil
+		})
+		if err := request.Compile(options); err != nil {
+			t.Fatalf("%s: could not compile request: %s", test.name, err)
+		}
+		var matched int
+		request.ExecuteWithResults(protocols.NewScanContext(server.URL, nil), nil, map[string]interface{}{}, func(event *protocols.InternalWrappedEvent) {
+			if event.OperatorsResult != nil && event.OperatorsResult.Matched {
+				matched++
+			}
+		})
+		// the slow handlers may still be running after a timeout
+		time.Sleep(250 * time.Millisecond)
+		mu.Lock()
+		for path, count := range test.hits {
+			if hits[path] != count {
+				t.Errorf("%s: expected %d requests to %s, got %d", test.name, count, path, hits[path])
+			}
+		}
+		if len(hits) != len(test.hits) {
+			t.Errorf("%s: expected the requests %v, got %v", test.name, test.hits, hits)
+		}
+		mu.Unlock()
+		if matched != test.matched {
+			t.Errorf("%s: expected %d matches, got %d", test.name, test.matched, matched)
+		}
+		for i, ctx := range contexts {
+			if ctx.Err() == nil {
+				t.Errorf("%s: the context of the request %d wasn't released", test.name, i)
+			}
+		}
+	}
+}
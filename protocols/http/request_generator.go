@@ -1,7 +1,9 @@
 package http
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
@@ -19,6 +21,8 @@ type requestGenerator struct {
 	request          *Request
 	payloadIterator  *protocols.Iterator
 	rawRequest       *rawRequest
+	// once are the @once requests already returned, the generator lives for a single target
+	once map[string]struct{}
 }
 
 // newGenerator creates a NewGenerator request generator instance
@@ -79,9 +83,12 @@ func (r *requestGenerator) nextValue() (value string, payloads map[string]interf
 	}
 
 	if shouldContinue {
-		//if r.hasMarker(request, Once) {
-		//	r.applyMark(request, Once)
-		//}
+		if len(r.request.Raw) > 0 && isOnceRequest(request) {
+			if r.once == nil {
+				r.once = make(map[string]struct{})
+			}
+			r.once[request] = struct{}{}
+		}
 		if hasPayloadIterator {
 			return request, r.currentPayloads, r.okCurrentPayload
 		}
@@ -96,10 +103,10 @@ func (r *requestGenerator) nextValue() (value string, payloads map[string]interf
 // at end of each iteration payload is incremented
 func (r *requestGenerator) findNextIteration(sequence []string, index int) (string, int, bool) {
 	for i, request := range sequence[index:] {
-		//if r.wasMarked(request, Once) {
-		//	// if request contains flowmark i.e `@once` and is marked skip it
-		//	continue
-		//}
+		if _, ok := r.once[request]; ok {
+			// if request contains `@once` and was already returned skip it
+			continue
+		}
 		return request, index + i, true
 
 	}
@@ -184,12 +191,17 @@ func (r *requestGenerator) makeHTTPRequestFromModel(data string, values, dynamic
 	if err != nil {
 		return nil, err
 	}
-	req = req.WithContext(r.request.Context())
 	request, err := r.fillRequest(req, values)
 	if err != nil {
 		return nil, err
 	}
-	return &generatedRequest{request: request, original: r.request, dynamicValues: dynamicValues, meta: values}, nil
+	generatedRequest := &generatedRequest{request: request, original: r.request, dynamicValues: dynamicValues, meta: values}
+	if r.request.protocolVersion != "" {
+		if generatedRequest.transport, err = getTransport(transportOptions{proto: r.request.protocolVersion}); err != nil {
+			return nil, err
+		}
+	}
+	return generatedRequest, nil
 }
 
 // makeHTTPRequestFromRaw creates a *http.Request from a raw request
@@ -240,12 +252,9 @@ func (r *requestGenerator) makeHTTPRequestFromRaw(baseURL, data string, values,
 		dynamicValues: dynamicValues,
 	}
 
-	if reqWithAnnotations, hasAnnotations := r.request.parseAnnotations(data, req); hasAnnotations {
-		generatedRequest.request = reqWithAnnotations
-	} else {
-		generatedRequest.request = request.WithContext(r.request.Context())
+	if err := r.request.parseAnnotations(data, generatedRequest); err != nil {
+		return nil, err
 	}
-
 	return generatedRequest, nil
 }
 
@@ -276,6 +285,33 @@ func (r *requestGenerator) fillRequest(req *http.Request, values map[string]inte
 			return nil, err
 		}
 		req.Body = NopCloser(strings.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return NopCloser(strings.NewReader(body)), nil
+		}
+		req.ContentLength = int64(len(body))
+	}
+	// structured bodies set their content type unless the template sets one,
+	// multipart bodies always do as the boundary is generated
+	body, contentType, err := r.request.buildBody(values)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Body = NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return NopCloser(bytes.NewReader(body)), nil
+		}
+		req.ContentLength = int64(len(body))
+		if len(r.request.BodyMultipart) > 0 {
+			for key := range req.Header {
+				if strings.EqualFold(key, "Content-Type") {
+					delete(req.Header, key)
+				}
+			}
+		}
+		if !hasHeader(req, "Content-Type") {
+			req.Header.Set("Content-Type", contentType)
+		}
 	}
 	//if !r.request.Unsafe {
 	//	setHeader(req, "User-Agent", common.GetRandom())
@@ -296,6 +332,77 @@ func (r *requestGenerator) fillRequest(req *http.Request, values map[string]inte
 //	req.header = rawreq.headers
 //}
 
+// applyBaseRequest reuses the headers of the captured request (auth, cookies...) the template doesn't set,
+// they are only sent to the captured host. Requests to the captured url that don't define their own
+// method or body (fuzzing included) reuse the captured method and body.
+func (r *Request) applyBaseRequest(req *http.Request, base *protocols.BaseRequest) {
+	baseURL, err := url.Parse(base.URL)
+	if err != nil || !strings.EqualFold(req.URL.Host, baseURL.Host) {
+		return
+	}
+	for key, value := range base.Headers {
+		if strings.EqualFold(key, "Cookie") {
+			mergeCookies(req, value)
+			continue
+		}
+		if !hasHeader(req, key) {
+			req.Header[key] = []string{value}
+		}
+	}
+
+	if len(r.Raw) > 0 || r.Body != "" || (r.Method != "" && len(r.Fuzzing) == 0) {
+		return
+	}
+	if req.URL.String() != baseURL.String() {
+		return
+	}
+	req.Method = base.Method
+	if base.Body != "" {
+		body := base.Body
+		req.Body = NopCloser(strings.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return NopCloser(strings.NewReader(body)), nil
+		}
+		req.ContentLength = int64(len(body))
+	}
+}
+
+// hasHeader returns true if the request has the header, template headers are not canonicalized
+func hasHeader(req *http.Request, name string) bool {
+	for key := range req.Header {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeCookies adds the captured cookies the request doesn't already send
+func mergeCookies(req *http.Request, cookies string) {
+	existing := make(map[string]struct{})
+	for _, cookie := range req.Cookies() {
+		existing[cookie.Name] = struct{}{}
+	}
+	var missing []string
+	for _, pair := range strings.Split(cookies, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name := strings.SplitN(pair, "=", 2)[0]
+		if _, ok := existing[name]; !ok {
+			missing = append(missing, pair)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	if current := req.Header.Get("Cookie"); current != "" {
+		missing = append([]string{current}, missing...)
+	}
+	req.Header.Set("Cookie", strings.Join(missing, "; "))
+}
+
 // setHeader sets some headers only if the header wasn't supplied by the user
 func setHeader(req *http.Request, name, value string) {
 	if _, ok := req.Header[name]; !ok {
@@ -0,0 +1,87 @@
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/chainreactors/neutron/protocols"
+)
+
+func TestApplyBaseRequest(t *testing.T) {
+	base := protocols.NewBaseRequest("POST", "http://example.com/login?next=%2F", map[string]string{
+		"Cookie":       "sid=1; lang=en",
+		"Content-Type": "application/x-www-form-urlencoded",
+		"user-agent":   "captured",
+	}, "user=admin")
+	tests := []struct {
+		name    string
+		request *Request
+		url     string
+		headers map[string]string
+		// method and body are the sent method and body
+		method, body string
+	}{
+		{
+			name:    "replayed",
+			request: &Request{},
+			url:     "http://example.com/login?next=%2F",
+			headers: map[string]string{"Cookie": "sid=2; lang=en", "Content-Type": "application/x-www-form-urlencoded", "User-Agent": "template"},
+			method:  "POST", body: "user=admin",
+		},
+		{
+			name:    "other path",
+			request: &Request{},
+			url:     "http://example.com/admin",
+			headers: map[string]string{"Cookie": "sid=2; lang=en", "Content-Type": "application/x-www-form-urlencoded", "User-Agent": "template"},
+			method:  "GET",
+		},
+		{
+			name:    "template method",
+			request: &Request{Method: "GET"},
+			url:     "http://example.com/login?next=%2F",
+			headers: map[string]string{"Cookie": "sid=2; lang=en", "Content-Type": "application/x-www-form-urlencoded", "User-Agent": "template"},
+			method:  "GET",
+		},
+		{
+			name:    "template body",
+			request: &Request{Body: "user=guest"},
+			url:     "http://example.com/login?next=%2F",
+			headers: map[string]string{"Cookie": "sid=2; lang=en", "Content-Type": "application/x-www-form-urlencoded", "User-Agent": "template"},
+			method:  "GET",
+		},
+		{
+			name:    "other host",
+			request: &Request{},
+			url:     "http://other.com/login?next=%2F",
+			headers: map[string]string{"Cookie": "sid=2", "Content-Type": "", "User-Agent": "template"},
+			method:  "GET",
+		},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest("GET", test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the template headers are kept, the missing cookies are added
+		req.Header.Set("Cookie", "sid=2")
+		req.Header.Set("User-Agent", "template")
+		test.request.applyBaseRequest(req, base)
+
+		for name, value := range test.headers {
+			if got := req.Header.Get(name); got != value {
+				t.Errorf("%s: expected the %s header %q, got %q", test.name, name, value, got)
+			}
+		}
+		if req.Method != test.method {
+			t.Errorf("%s: expected the method %s, got %s", test.name, test.method, req.Method)
+		}
+		var body []byte
+		if req.Body != nil {
+			body, _ = ioutil.ReadAll(req.Body)
+		}
+		if string(body) != test.body || (test.body != "" && req.ContentLength != int64(len(test.body))) {
+			t.Errorf("%s: expected the body %q, got %q (%d)", test.name, test.body, body, req.ContentLength)
+		}
+	}
+}
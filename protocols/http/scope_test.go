@@ -0,0 +1,87 @@
+package http
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/chainreactors/neutron/operators"
+	"github.com/chainreactors/neutron/protocols"
+)
+
+func TestScopeRedirect(t *testing.T) {
+	var outsideHits int32
+	outside := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&outsideHits, 1)
+		w.Write([]byte("ok"))
+	}))
+	defer outside.Close()
+	inside := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/leave" {
+			http.Redirect(w, r, outside.URL+"/", http.StatusFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer inside.Close()
+	_, port, _ := net.SplitHostPort(inside.Listener.Addr().String())
+
+	tests := []struct {
+		path    string
+		matched bool
+	}{
+		{"/stay", true},
+		{"/leave", false},
+	}
+	for _, test := range tests {
+		request := &Request{
+			Path:      []string{"{{BaseURL}}" + test.path},
+			Method:    "GET",
+			Redirects: true,
+			Operators: operators.Operators{
+				Matchers: []*operators.Matcher{{Type: "word", Part: "body", Words: []string{"ok"}}},
+			},
+		}
+		scope := &protocols.Scope{AllowedCIDRs: []string{"127.0.0.1"}, AllowedPorts: []string{port}}
+		if err := request.Compile(&protocols.ExecuterOptions{Options: &protocols.Options{Timeout: 5, Scope: scope}}); err != nil {
+			t.Fatal(err)
+		}
+		var errs []error
+		scan := protocols.NewScanContext(inside.URL, nil)
+		scan.OnError = func(err error) {
+			errs = append(errs, err)
+		}
+		var matched bool
+		request.ExecuteWithResults(scan, nil, map[string]interface{}{}, func(event *protocols.InternalWrappedEvent) {
+			matched = matched || (event.OperatorsResult != nil && event.OperatorsResult.Matched)
+		})
+		if matched != test.matched {
+			t.Errorf("%s: expected matched %v, got %v", test.path, test.matched, matched)
+		}
+		if !test.matched && (len(errs) != 1 || !errors.Is(errs[0], protocols.ErrOutOfScope)) {
+			t.Errorf("%s: expected an out of scope error, got %v", test.path, errs)
+		}
+	}
+	if hits := atomic.LoadInt32(&outsideHits); hits != 0 {
+		t.Errorf("the out of scope redirect was followed %d times", hits)
+	}
+
+	// the target itself is checked before sending anything
+	request := &Request{Path: []string{"{{BaseURL}}/"}, Method: "GET"}
+	scope := &protocols.Scope{DeniedHosts: []string{"127.0.0.1"}}
+	if err := request.Compile(&protocols.ExecuterOptions{Options: &protocols.Options{Timeout: 5, Scope: scope}}); err != nil {
+		t.Fatal(err)
+	}
+	var blocked bool
+	scan := protocols.NewScanContext(outside.URL, nil)
+	scan.OnError = func(err error) {
+		blocked = errors.Is(err, protocols.ErrOutOfScope)
+	}
+	request.ExecuteWithResults(scan, nil, map[string]interface{}{}, func(*protocols.InternalWrappedEvent) {})
+	if !blocked || atomic.LoadInt32(&outsideHits) != 0 {
+		t.Error("expected the denied target to be blocked")
+	}
+}
@@ -0,0 +1,114 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/chainreactors/neutron/common"
+	"github.com/chainreactors/neutron/protocols"
+)
+
+// slot is the in-flight slot of a request on its host
+type slot struct {
+	limit   *protocols.RateLimit
+	host    string
+	release func()
+}
+
+// acquireSlot blocks until a request to host is allowed and returns its held slot
+func acquireSlot(limit *protocols.RateLimit, host string) *slot {
+	s := &slot{limit: limit, host: host}
+	s.acquire()
+	return s
+}
+
+func (s *slot) acquire() {
+	if s != nil && s.release == nil {
+		s.release = s.limit.Acquire(s.host)
+	}
+}
+
+// free releases the slot if it is held, it can be acquired again
+func (s *slot) free() {
+	if s != nil && s.release != nil {
+		s.release()
+		s.release = nil
+	}
+}
+
+// authenticate resolves the session and the bearer token of the request before its in-flight slot is
+// taken, a login sends requests to the same host and would wait for the held slot forever
+func (r *Request) authenticate(request *generatedRequest) error {
+	req := request.request
+	if manager := r.options.Session; manager != nil {
+		session, err := manager.Get(req.URL)
+		if err != nil {
+			return fmt.Errorf("could not login on %s: %w", req.URL.Host, err)
+		}
+		request.session = session
+	}
+	if auth := r.getAuth(); auth != nil && auth.Type == protocols.AuthBearer && auth.TokenProvider != nil {
+		token, err := common.Evaluate(auth.Token, request.Vars())
+		if err != nil {
+			return err
+		}
+		if token == "" {
+			if token, err = auth.TokenProvider(req.URL.Scheme + "://" + req.URL.Host); err != nil {
+				return fmt.Errorf("could not get bearer token: %w", err)
+			}
+		}
+		request.token = token
+	}
+	return nil
+}
+
+// do sends the request with the session of its host and the configured authentication,
+// an expired session is refreshed and the request is sent again once
+func (r *Request) do(client *http.Client, request *generatedRequest) (*http.Response, error) {
+	manager := r.options.Session
+	if manager == nil {
+		return r.doAuth(client, request)
+	}
+	req := request.request
+	session := request.session
+	if session == nil {
+		var err error
+		if session, err = manager.Get(req.URL); err != nil {
+			return nil, fmt.Errorf("could not login on %s: %w", req.URL.Host, err)
+		}
+	}
+	if err := replayable(req); err != nil {
+		return nil, err
+	}
+	header := req.Header.Clone()
+	manager.Apply(req, session)
+	resp, err := r.doAuth(client, request)
+	if err != nil || !manager.Expired(resp) {
+		return resp, err
+	}
+
+	// the login sends requests to the host, the slot is released while it runs
+	request.slot.free()
+	session, err = manager.Refresh(req.URL, session)
+	request.slot.acquire()
+	if err != nil {
+		// the expired response is kept, operators still run on it
+		common.Debug("could not refresh session of %s, %s", req.URL.Host, err.Error())
+		return resp, nil
+	}
+	drain(resp)
+	next := req.Clone(req.Context())
+	next.Header = header
+	if req.GetBody != nil {
+		if next.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	manager.Apply(next, session)
+	request.request = next
+	request.session = session
+	if request.slot == nil {
+		r.options.Options.RateLimit.Wait(req.URL.Host)
+	}
+	return r.doAuth(client, request)
+}
@@ -0,0 +1,183 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chainreactors/neutron/operators"
+	"github.com/chainreactors/neutron/protocols"
+)
+
+func TestSessionRefresh(t *testing.T) {
+	var logins, valid int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("sid")
+		switch {
+		case r.URL.Path == "/login":
+			w.Write([]byte("login page"))
+		case r.URL.Path == "/private":
+			// a page the session can't access, it doesn't mean the session expired
+			w.WriteHeader(http.StatusForbidden)
+		case err != nil || cookie.Value != strconv.Itoa(int(atomic.LoadInt32(&valid))):
+			http.Redirect(w, r, "/login", http.StatusFound)
+		default:
+			w.Write([]byte("welcome"))
+		}
+	}))
+	defer server.Close()
+
+	manager := protocols.NewSessionManager(func(target string) (*protocols.Session, error) {
+		sid := strconv.Itoa(int(atomic.AddInt32(&logins, 1)))
+		return &protocols.Session{Cookies: []*http.Cookie{{Name: "sid", Value: sid}}}, nil
+	})
+	tests := []struct {
+		path string
+		// valid is the session id the server accepts
+		valid   int32
+		matched bool
+		logins  int32
+	}{
+		{"/admin", 1, true, 1},
+		{"/private", 1, false, 1},
+		// the session expired, the redirect to the login page logs in again
+		{"/admin", 2, true, 2},
+		{"/admin", 2, true, 2},
+	}
+	for i, test := range tests {
+		atomic.StoreInt32(&valid, test.valid)
+		request := &Request{
+			Path:      []string{"{{BaseURL}}" + test.path},
+			Method:    "GET",
+			Redirects: true,
+			Operators: operators.Operators{
+				Matchers: []*operators.Matcher{{Type: "word", Part: "body", Words: []string{"welcome"}}},
+			},
+		}
+		options := &protocols.ExecuterOptions{Options: &protocols.Options{Timeout: 5}, Session: manager}
+		if err := request.Compile(options); err != nil {
+			t.Fatal(err)
+		}
+		var matched bool
+		err := request.ExecuteWithResults(protocols.NewScanContext(server.URL, nil), nil, map[string]interface{}{}, func(event *protocols.InternalWrappedEvent) {
+			matched = event.OperatorsResult != nil && event.OperatorsResult.Matched
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if matched != test.matched {
+			t.Errorf("%d %s: expected matched %v, got %v", i, test.path, test.matched, matched)
+		}
+		if count := atomic.LoadInt32(&logins); count != test.logins {
+			t.Errorf("%d %s: expected %d logins, got %d", i, test.path, test.logins, count)
+		}
+	}
+}
+
+func TestSessionRateLimit(t *testing.T) {
+	var expired int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			w.Write([]byte("sid=1"))
+		case "/admin":
+			if atomic.CompareAndSwapInt32(&expired, 1, 0) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte("welcome"))
+		case "/api":
+			if r.Header.Get("Authorization") == "Bearer sid=1" {
+				w.Write([]byte("welcome"))
+			}
+		}
+	}))
+	defer server.Close()
+
+	// the logins share the options, and the host slot, of the requests they log in for
+	options := &protocols.Options{Timeout: 5, RateLimit: &protocols.RateLimit{PerHostMaxInFlight: 1}}
+	login := func(target string) (string, error) {
+		request := &Request{
+			Path:   []string{"{{BaseURL}}/login"},
+			Method: "GET",
+			Operators: operators.Operators{
+				Matchers: []*operators.Matcher{{Type: "word", Part: "body", Words: []string{"sid"}}},
+			},
+		}
+		if err := request.Compile(&protocols.ExecuterOptions{Options: options}); err != nil {
+			return "", err
+		}
+		var body string
+		err := request.ExecuteWithResults(protocols.NewScanContext(target, nil), nil, map[string]interface{}{}, func(event *protocols.InternalWrappedEvent) {
+			body, _ = event.InternalEvent["body"].(string)
+		})
+		if err == nil && body == "" {
+			err = fmt.Errorf("could not login on %s", target)
+		}
+		return body, err
+	}
+	var logins int32
+	manager := protocols.NewSessionManager(func(target string) (*protocols.Session, error) {
+		atomic.AddInt32(&logins, 1)
+		sid, err := login(target)
+		if err != nil {
+			return nil, err
+		}
+		return &protocols.Session{Headers: map[string]string{"X-Session": sid}}, nil
+	})
+
+	tests := []struct {
+		name    string
+		path    string
+		expire  bool
+		session *protocols.SessionManager
+		auth    *protocols.AuthConfig
+	}{
+		{"session", "/admin", false, manager, nil},
+		// the 401 refreshes the session, the login runs while the request waits for its slot
+		{"refresh", "/admin", true, manager, nil},
+		{"token", "/api", false, nil, &protocols.AuthConfig{Type: protocols.AuthBearer, TokenProvider: login}},
+	}
+	for _, test := range tests {
+		if test.expire {
+			atomic.StoreInt32(&expired, 1)
+		}
+		request := &Request{
+			Path:   []string{"{{BaseURL}}" + test.path},
+			Method: "GET",
+			Auth:   test.auth,
+			Operators: operators.Operators{
+				Matchers: []*operators.Matcher{{Type: "word", Part: "body", Words: []string{"welcome"}}},
+			},
+		}
+		if err := request.Compile(&protocols.ExecuterOptions{Options: options, Session: test.session}); err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan bool, 1)
+		go func() {
+			var matched bool
+			err := request.ExecuteWithResults(protocols.NewScanContext(server.URL, nil), nil, map[string]interface{}{}, func(event *protocols.InternalWrappedEvent) {
+				matched = event.OperatorsResult != nil && event.OperatorsResult.Matched
+			})
+			if err != nil {
+				t.Error(err)
+			}
+			done <- matched
+		}()
+		select {
+		case matched := <-done:
+			if !matched {
+				t.Errorf("%s: expected a match", test.name)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%s: the request is waiting for its own slot", test.name)
+		}
+	}
+	if count := atomic.LoadInt32(&logins); count != 2 {
+		t.Errorf("expected 2 logins, got %d", count)
+	}
+}
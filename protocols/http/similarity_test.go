@@ -0,0 +1,42 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chainreactors/neutron/operators"
+	"github.com/chainreactors/neutron/protocols"
+)
+
+func TestSimilarityRequestIndex(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("access denied"))
+	}))
+	defer server.Close()
+
+	request := &Request{
+		Path:   []string{"{{BaseURL}}/a", "{{BaseURL}}/b"},
+		Method: "GET",
+		Operators: operators.Operators{
+			// on the first request body is body_1, the response isn't compared with itself
+			Matchers: []*operators.Matcher{{Type: "similarity", Part: "body", Compare: "body_1", Threshold: 0.9}},
+		},
+	}
+	if err := request.Compile(&protocols.ExecuterOptions{Options: &protocols.Options{Timeout: 5}}); err != nil {
+		t.Fatal(err)
+	}
+	var matched []string
+	err := request.ExecuteWithResults(protocols.NewScanContext(server.URL, nil), nil, map[string]interface{}{}, func(event *protocols.InternalWrappedEvent) {
+		if event.OperatorsResult != nil && event.OperatorsResult.Matched {
+			matched = append(matched, strings.TrimPrefix(event.InternalEvent["matched"].(string), server.URL))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matched) != 1 || matched[0] != "/b" {
+		t.Errorf("expected only the second response to match the first, got %v", matched)
+	}
+}
@@ -0,0 +1,132 @@
+package http
+
+import (
+	"container/list"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const (
+	// protocolHTTP1 forces http/1.1, also over tls
+	protocolHTTP1 = "h1"
+	// protocolHTTP2 negotiates http2 over tls with alpn
+	protocolHTTP2 = "h2"
+	// protocolH2C speaks http2 with prior knowledge over cleartext
+	protocolH2C = "h2c"
+)
+
+// normalizeProtocolVersion validates a protocol-version value, empty keeps the default transport
+func normalizeProtocolVersion(version string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(version)) {
+	case "":
+		return "", nil
+	case "h1", "http1", "http/1.1":
+		return protocolHTTP1, nil
+	case "h2", "http2":
+		return protocolHTTP2, nil
+	case "h2c":
+		return protocolH2C, nil
+	}
+	return "", fmt.Errorf("unknown protocol-version: %s", version)
+}
+
+// MaxTransports is the number of per-request transports kept, the least recently used one is
+// evicted and its idle connections are closed. Requests sharing the same overrides share their connections.
+var MaxTransports = 64
+
+// transports caches the per-request transports keyed by their overrides, e.g. one per @tls-sni host
+var transports = &transportCache{order: list.New(), entries: make(map[string]*list.Element)}
+
+// transportCache is a bounded LRU cache of transports, it is safe for concurrent use
+type transportCache struct {
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type transportItem struct {
+	key       string
+	transport *http.Transport
+}
+
+func (c *transportCache) get(key string) (*http.Transport, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*transportItem).transport, true
+}
+
+// add caches the transport unless another one was added for the key, the cached transport is returned
+func (c *transportCache) add(key string, tr *http.Transport) *http.Transport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.order.MoveToFront(element)
+		return element.Value.(*transportItem).transport
+	}
+	c.entries[key] = c.order.PushFront(&transportItem{key: key, transport: tr})
+	for c.order.Len() > MaxTransports && c.order.Len() > 1 {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		item := oldest.Value.(*transportItem)
+		delete(c.entries, item.key)
+		// the requests still using the transport keep their connections until they are idle
+		item.transport.CloseIdleConnections()
+	}
+	return tr
+}
+
+// transportOptions are the per-request transport overrides of annotations
+type transportOptions struct {
+	// sni is the tls server name, empty keeps the request host
+	sni string
+	// proxy is the proxy url, empty keeps the default proxy
+	proxy string
+	// proto is the normalized protocol version, empty keeps the default protocols
+	proto string
+}
+
+func (o transportOptions) key() string {
+	return o.sni + "|" + o.proxy + "|" + o.proto
+}
+
+// getTransport returns a clone of DefaultTransport with the overrides applied
+func getTransport(opts transportOptions) (*http.Transport, error) {
+	if cached, ok := transports.get(opts.key()); ok {
+		return cached, nil
+	}
+	tr := DefaultTransport.Clone()
+	if opts.sni != "" {
+		tr.TLSClientConfig.ServerName = opts.sni
+	}
+	if opts.proxy != "" {
+		proxyURL, err := url.Parse(opts.proxy)
+		if err != nil {
+			return nil, err
+		}
+		tr.Proxy = http.ProxyURL(proxyURL)
+	}
+	switch opts.proto {
+	case protocolHTTP1:
+		// a non-nil empty map disables the http2 upgrade
+		tr.ForceAttemptHTTP2 = false
+		tr.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+		tr.TLSClientConfig.NextProtos = []string{"http/1.1"}
+	case protocolHTTP2:
+		// the custom dialer and tls config of DefaultTransport disable http2 unless forced
+		tr.ForceAttemptHTTP2 = true
+	case protocolH2C:
+		if err := configureH2C(tr); err != nil {
+			return nil, err
+		}
+	}
+	return transports.add(opts.key(), tr), nil
+}
@@ -0,0 +1,13 @@
+//go:build go1.24
+
+package http
+
+import "net/http"
+
+// configureH2C makes the transport speak http2 with prior knowledge over cleartext
+func configureH2C(tr *http.Transport) error {
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	tr.Protocols = protocols
+	return nil
+}
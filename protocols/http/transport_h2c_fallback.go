@@ -0,0 +1,13 @@
+//go:build !go1.24
+
+package http
+
+import (
+	"errors"
+	"net/http"
+)
+
+// configureH2C needs http.Protocols, it is only available from go1.24
+func configureH2C(tr *http.Transport) error {
+	return errors.New("h2c protocol version requires go1.24 or newer")
+}
@@ -0,0 +1,98 @@
+//go:build go1.24
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chainreactors/neutron/operators"
+	"github.com/chainreactors/neutron/protocols"
+)
+
+func executeProtocolRequest(t *testing.T, target, version string) string {
+	request := &Request{
+		Path:            []string{"{{BaseURL}}"},
+		Method:          "GET",
+		ProtocolVersion: version,
+		Operators: operators.Operators{
+			Matchers: []*operators.Matcher{{Type: "word", Part: "body", Words: []string{"ok"}}},
+		},
+	}
+	options := &protocols.ExecuterOptions{Options: &protocols.Options{Timeout: 5}}
+	if err := request.Compile(options); err != nil {
+		t.Fatalf("could not compile request: %s", err)
+	}
+	var proto string
+	err := request.ExecuteWithResults(protocols.NewScanContext(target, nil), nil, map[string]interface{}{}, func(event *protocols.InternalWrappedEvent) {
+		proto = fmt.Sprint(event.InternalEvent["proto"])
+	})
+	if err != nil {
+		t.Fatalf("could not execute %s request: %s", version, err)
+	}
+	return proto
+}
+
+func TestProtocolVersion(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	tlsServer := httptest.NewUnstartedServer(handler)
+	tlsServer.EnableHTTP2 = true
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+
+	h2cServer := httptest.NewUnstartedServer(handler)
+	h2cServer.Config.Protocols = new(http.Protocols)
+	h2cServer.Config.Protocols.SetHTTP1(true)
+	h2cServer.Config.Protocols.SetUnencryptedHTTP2(true)
+	h2cServer.Start()
+	defer h2cServer.Close()
+
+	tests := []struct {
+		target  string
+		version string
+		proto   string
+	}{
+		{tlsServer.URL, "", "HTTP/1.1"},
+		{tlsServer.URL, "h1", "HTTP/1.1"},
+		{tlsServer.URL, "h2", "HTTP/2.0"},
+		{h2cServer.URL, "h1", "HTTP/1.1"},
+		{h2cServer.URL, "h2c", "HTTP/2.0"},
+	}
+	for _, test := range tests {
+		if proto := executeProtocolRequest(t, test.target, test.version); proto != test.proto {
+			t.Errorf("protocol-version %q: got %s, expected %s", test.version, proto, test.proto)
+		}
+	}
+}
+
+func TestTransportCacheBounds(t *testing.T) {
+	defer func(max int) { MaxTransports = max }(MaxTransports)
+	MaxTransports = 2
+
+	first, err := getTransport(transportOptions{sni: "a.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := getTransport(transportOptions{sni: "a.example.com"}); cached != first {
+		t.Error("the transport of the same overrides wasn't shared")
+	}
+	// a.example.com is used again, b.example.com is the least recently used transport
+	getTransport(transportOptions{sni: "b.example.com"})
+	getTransport(transportOptions{sni: "a.example.com"})
+	getTransport(transportOptions{sni: "c.example.com"})
+
+	if transports.order.Len() != 2 || len(transports.entries) != 2 {
+		t.Errorf("expected 2 cached transports, got %d", transports.order.Len())
+	}
+	if _, ok := transports.get(transportOptions{sni: "b.example.com"}.key()); ok {
+		t.Error("the least recently used transport was kept")
+	}
+	if cached, ok := transports.get(transportOptions{sni: "a.example.com"}.key()); !ok || cached != first {
+		t.Error("the recently used transport was evicted")
+	}
+}
@@ -1,6 +1,7 @@
 package network
 
 import (
+	"crypto/tls"
 	"net"
 	"time"
 )
@@ -14,3 +15,22 @@ func Get() (*net.Dialer, error) {
 	}
 	return dialer, nil
 }
+
+// dial connects to the address, out of scope addresses are never dialed.
+// tls:// addresses are encrypted, the certificate isn't verified as for http.
+func (r *Request) dial(address string, shouldUseTLS bool) (net.Conn, error) {
+	if err := r.options.Options.Scope.CheckAddress(address); err != nil {
+		return nil, err
+	}
+	if !shouldUseTLS {
+		return r.dialer.Dial("tcp", address)
+	}
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS10,
+		InsecureSkipVerify: true,
+	}
+	if host, _, err := net.SplitHostPort(address); err == nil && net.ParseIP(host) == nil {
+		config.ServerName = host
+	}
+	return tls.DialWithDialer(r.dialer, "tcp", address, config)
+}
@@ -0,0 +1,122 @@
+package network
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chainreactors/neutron/operators"
+	"github.com/chainreactors/neutron/protocols"
+)
+
+func TestDialScope(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	accepted := make(chan struct{}, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- struct{}{}
+			conn.Write([]byte("pong"))
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	tests := []struct {
+		scope   *protocols.Scope
+		blocked bool
+	}{
+		{&protocols.Scope{AllowedPorts: []string{port}}, false},
+		{&protocols.Scope{AllowedCIDRs: []string{"127.0.0.0/8"}, DeniedHosts: []string{"127.0.0.1"}}, true},
+		{&protocols.Scope{AllowedPorts: []string{"1-2"}}, true},
+	}
+	for i, test := range tests {
+		request := &Request{Address: []string{"{{Hostname}}"}, Inputs: []*Input{{Data: "ping"}}}
+		if err := request.Compile(&protocols.ExecuterOptions{Options: &protocols.Options{Timeout: 5, Scope: test.scope}}); err != nil {
+			t.Fatal(err)
+		}
+		var errs []error
+		scan := protocols.NewScanContext(listener.Addr().String(), nil)
+		scan.OnError = func(err error) {
+			errs = append(errs, err)
+		}
+		if err := request.ExecuteWithResults(scan, nil, map[string]interface{}{}, func(*protocols.InternalWrappedEvent) {}); err != nil {
+			t.Fatal(err)
+		}
+
+		var dialed bool
+		select {
+		case <-accepted:
+			dialed = true
+		case <-time.After(100 * time.Millisecond):
+		}
+		if dialed == test.blocked {
+			t.Errorf("scope %d: expected blocked %v, the address was dialed: %v", i, test.blocked, dialed)
+		}
+		if test.blocked && (len(errs) != 1 || !errors.Is(errs[0], protocols.ErrOutOfScope)) {
+			t.Errorf("scope %d: expected an out of scope error, got %v", i, errs)
+		}
+	}
+}
+
+func TestDialTLS(t *testing.T) {
+	var requests int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte("welcome"))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	tests := []struct {
+		scope   *protocols.Scope
+		blocked bool
+	}{
+		{&protocols.Scope{AllowedPorts: []string{port}}, false},
+		{&protocols.Scope{DeniedHosts: []string{"127.0.0.1"}}, true},
+	}
+	for i, test := range tests {
+		request := &Request{
+			Address:  []string{"tls://{{Hostname}}"},
+			Inputs:   []*Input{{Data: "GET / HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n"}},
+			ReadSize: 4096,
+			Operators: operators.Operators{
+				Matchers: []*operators.Matcher{{Type: "word", Words: []string{"200 OK", "welcome"}, Condition: "and"}},
+			},
+		}
+		if err := request.Compile(&protocols.ExecuterOptions{Options: &protocols.Options{Timeout: 5, Scope: test.scope}}); err != nil {
+			t.Fatal(err)
+		}
+		var errs []error
+		scan := protocols.NewScanContext(server.Listener.Addr().String(), nil)
+		scan.OnError = func(err error) {
+			errs = append(errs, err)
+		}
+		var matched bool
+		if err := request.ExecuteWithResults(scan, nil, map[string]interface{}{}, func(event *protocols.InternalWrappedEvent) {
+			matched = event.OperatorsResult != nil && event.OperatorsResult.Matched
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if matched == test.blocked {
+			t.Errorf("scope %d: expected matched %v over tls", i, !test.blocked)
+		}
+		if test.blocked && (len(errs) != 1 || !errors.Is(errs[0], protocols.ErrOutOfScope)) {
+			t.Errorf("scope %d: expected an out of scope error, got %v", i, errs)
+		}
+	}
+	if count := atomic.LoadInt32(&requests); count != 1 {
+		t.Errorf("expected the out of scope address not to be dialed, got %d requests", count)
+	}
+}
@@ -0,0 +1,93 @@
+package network
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/chainreactors/neutron/operators"
+	"github.com/chainreactors/neutron/protocols"
+)
+
+func TestNetworkHooks(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := make(chan string, 2)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+			data, _ := ioutil.ReadAll(conn)
+			received <- string(data)
+			conn.Write([]byte("pong " + string(data)))
+			conn.Close()
+		}
+	}()
+
+	for _, veto := range []bool{false, true} {
+		request := &Request{
+			Address: []string{"{{Hostname}}"},
+			Inputs:  []*Input{{Data: "ping"}},
+			Operators: operators.Operators{
+				// hooked is set by the after response hook
+				Matchers: []*operators.Matcher{{Type: "word", Part: "hooked", Words: []string{"pong ping signed"}}},
+			},
+		}
+		var calls []string
+		options := &protocols.ExecuterOptions{Options: &protocols.Options{Timeout: 5}}
+		options.AddBeforeNetworkRequest(func(req *protocols.NetworkRequest) error {
+			calls = append(calls, "sign "+string(req.Data))
+			req.Data = append(req.Data, " signed"...)
+			return nil
+		}, func(req *protocols.NetworkRequest) error {
+			calls = append(calls, "veto "+string(req.Data))
+			if veto {
+				return errors.New("vetoed")
+			}
+			return nil
+		})
+		options.AddAfterNetworkResponse(func(resp *protocols.NetworkResponse, event protocols.InternalEvent) {
+			calls = append(calls, "response "+string(resp.Data))
+			event["hooked"] = string(resp.Data)
+		})
+		if err := request.Compile(options); err != nil {
+			t.Fatal(err)
+		}
+		var matched bool
+		err := request.ExecuteWithResults(protocols.NewScanContext(listener.Addr().String(), nil), nil, map[string]interface{}{}, func(event *protocols.InternalWrappedEvent) {
+			matched = event.OperatorsResult != nil && event.OperatorsResult.Matched
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []string{"sign ping", "veto ping signed", "response pong ping signed"}
+		expectedData := "ping signed"
+		if veto {
+			expected, expectedData = expected[:2], ""
+		}
+		if !reflect.DeepEqual(calls, expected) {
+			t.Errorf("veto %v: expected the hooks %v, got %v", veto, expected, calls)
+		}
+		select {
+		case data := <-received:
+			if data != expectedData {
+				t.Errorf("veto %v: expected the data %q to be written, got %q", veto, expectedData, data)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("veto %v: the address wasn't dialed", veto)
+		}
+		if matched == veto {
+			t.Errorf("veto %v: expected matched %v", veto, !veto)
+		}
+	}
+}
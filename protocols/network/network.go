@@ -64,10 +64,10 @@ func (r *Request) GetID() string {
 
 // Compile compiles the protocol request for further execution.
 func (r *Request) Compile(options *protocols.ExecuterOptions) error {
-	var shouldUseTLS bool
 	var err error
 	r.options = options
 	for _, address := range r.Address {
+		var shouldUseTLS bool
 		// check if the connection should be encrypted
 		if strings.HasPrefix(address, "tls://") {
 			shouldUseTLS = true
@@ -118,6 +118,7 @@ func (r *Request) Compile(options *protocols.ExecuterOptions) error {
 		if err := compiled.Compile(); err != nil {
 			return err
 		}
+		compiled.Explain = options.Options.Explain
 		r.CompiledOperators = compiled
 	}
 	return nil
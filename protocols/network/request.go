@@ -57,17 +57,22 @@ func (r *Request) Match(data map[string]interface{}, matcher *operators.Matcher)
 
 // Extract performs extracting operation for an extractor on model and returns true or false.
 func (r *Request) Extract(data map[string]interface{}, extractor *operators.Extractor) map[string]struct{} {
+	return operators.ExtractedSet(r.ExtractValues(data, extractor))
+}
+
+// ExtractValues performs extracting operation for an extractor on model and returns the values in order.
+func (r *Request) ExtractValues(data map[string]interface{}, extractor *operators.Extractor) []operators.Extracted {
 	item, ok := r.getMatchPart(extractor.Part, data)
 	if !ok {
 		return nil
 	}
 	switch extractor.GetType() {
 	case operators.RegexExtractor:
-		return extractor.ExtractRegex(item)
+		return extractor.ExtractRegexValues(item)
 	case operators.KValExtractor:
-		return extractor.ExtractKval(data)
+		return extractor.ExtractKvalValues(data)
 	case operators.DSLExtractor:
-		return extractor.ExtractDSL(data)
+		return extractor.ExtractDSLValues(data)
 	}
 	return nil
 }
@@ -86,6 +91,10 @@ func (r *Request) ExecuteWithResults(input *protocols.ScanContext, dynamicValues
 		actualAddress := common.Replace(kv.address, variables)
 		err = r.executeAddress(input, variables, actualAddress, address, kv.tls, dynamicValues, callback)
 		if err != nil {
+			if errors.Is(err, protocols.ErrOutOfScope) {
+				common.NeutronLog.Warnf("blocked network request, %s", err.Error())
+				input.LogError(err)
+			}
 			continue
 		}
 	}
@@ -120,21 +129,21 @@ func (r *Request) executeAddress(input *protocols.ScanContext, variables map[str
 				break
 			}
 			value = common.MergeMaps(value, payloads)
-			if err := r.executeRequestWithPayloads(variables, actualAddress, address, input.Input, shouldUseTLS, value, dynamicValues, callback); err != nil {
+			if err := r.executeRequestWithPayloads(variables, actualAddress, address, input, shouldUseTLS, value, dynamicValues, callback); err != nil {
 				return err
 			}
 		}
 	} else {
 		value := protocols.CopyMap(payloads)
 
-		if err := r.executeRequestWithPayloads(variables, actualAddress, address, input.Input, shouldUseTLS, value, dynamicValues, callback); err != nil {
+		if err := r.executeRequestWithPayloads(variables, actualAddress, address, input, shouldUseTLS, value, dynamicValues, callback); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (r *Request) executeRequestWithPayloads(variables map[string]interface{}, actualAddress, address, input string, shouldUseTLS bool, payloads map[string]interface{}, dynamicValues map[string]interface{}, callback protocols.OutputEventCallback) error {
+func (r *Request) executeRequestWithPayloads(variables map[string]interface{}, actualAddress, address string, scan *protocols.ScanContext, shouldUseTLS bool, payloads map[string]interface{}, dynamicValues map[string]interface{}, callback protocols.OutputEventCallback) error {
 	var (
 		//hostname string
 		conn net.Conn
@@ -145,11 +154,9 @@ func (r *Request) executeRequestWithPayloads(variables map[string]interface{}, a
 	//	hostname = host
 	//}
 
-	if shouldUseTLS {
-		//conn, err = r.dialer.DialTLS(context.Background(), "tcp", actualAddress)
-	} else {
-		conn, err = r.dialer.Dial("tcp", actualAddress)
-	}
+	release := r.options.Options.RateLimit.Acquire(actualAddress)
+	defer release()
+	conn, err = r.dial(actualAddress, shouldUseTLS)
 	if err != nil {
 		return err
 	}
@@ -186,7 +193,11 @@ func (r *Request) executeRequestWithPayloads(variables map[string]interface{}, a
 		//}
 		//reqBuilder.Write(finalData)
 
-		_, err = conn.Write([]byte(finalData))
+		networkRequest := &protocols.NetworkRequest{Address: actualAddress, TLS: shouldUseTLS, Data: []byte(finalData)}
+		if err := r.options.RunBeforeNetworkRequest(networkRequest); err != nil {
+			return err
+		}
+		_, err = conn.Write(networkRequest.Data)
 		if err != nil {
 			return err
 		}
@@ -206,7 +217,7 @@ func (r *Request) executeRequestWithPayloads(variables map[string]interface{}, a
 
 			// Run any internal extractors for the request here and add found values to map.
 			if r.CompiledOperators != nil {
-				values := r.CompiledOperators.ExecuteInternalExtractors(map[string]interface{}{input.Name: bufferStr}, r.Extract)
+				values := r.CompiledOperators.ExecuteInternalExtractorsValues(map[string]interface{}{input.Name: bufferStr}, r.ExtractValues)
 				for k, v := range values {
 					payloads[k] = v
 				}
@@ -274,9 +285,14 @@ func (r *Request) executeRequestWithPayloads(variables map[string]interface{}, a
 	//for k, v := range inputEvents {
 	//	outputEvent[k] = v
 	//}
+	outputEvent := protocols.InternalEvent{"data": responseBuilder.String()}
+	r.options.RunAfterNetworkResponse(&protocols.NetworkResponse{Address: actualAddress, Data: []byte(responseBuilder.String())}, outputEvent)
 	event := &protocols.InternalWrappedEvent{InternalEvent: dynamicValues}
 	if r.CompiledOperators != nil {
-		result, ok := r.CompiledOperators.Execute(map[string]interface{}{"data": responseBuilder.String()}, r.Match, r.Extract)
+		result, ok := r.CompiledOperators.ExecuteValues(outputEvent, r.Match, r.ExtractValues)
+		if result != nil {
+			scan.LogTrace(result.Trace)
+		}
 		if ok && result != nil {
 			event.OperatorsResult = result
 			event.OperatorsResult.PayloadValues = payloads
@@ -331,12 +347,13 @@ func (r *Request) MakeResultEventItem(wrapped *protocols.InternalWrappedEvent) *
 		TemplateID: common.ToString(wrapped.InternalEvent["template-id"]),
 		//TemplatePath:     common.ToString(wrapped.InternalEvent["template-path"]),
 		//Info:             wrapped.InternalEvent["template-info"].(model.Info),
-		Type:             common.ToString(wrapped.InternalEvent["type"]),
-		Host:             common.ToString(wrapped.InternalEvent["host"]),
-		Matched:          common.ToString(wrapped.InternalEvent["matched"]),
-		ExtractedResults: wrapped.OperatorsResult.OutputExtracts,
-		Metadata:         wrapped.OperatorsResult.PayloadValues,
-		Timestamp:        time.Now(),
+		Type:              common.ToString(wrapped.InternalEvent["type"]),
+		Host:              common.ToString(wrapped.InternalEvent["host"]),
+		Matched:           common.ToString(wrapped.InternalEvent["matched"]),
+		ExtractedResults:  wrapped.OperatorsResult.OutputExtracts,
+		StructuredResults: wrapped.OperatorsResult.StructuredExtracts,
+		Metadata:          wrapped.OperatorsResult.PayloadValues,
+		Timestamp:         time.Now(),
 		//MatcherStatus:    true,
 		IP: common.ToString(wrapped.InternalEvent["ip"]),
 		//Request:          common.ToString(wrapped.InternalEvent["request"]),
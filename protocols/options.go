@@ -5,4 +5,12 @@ type Options struct {
 	AttackType  string
 	Opsec       bool
 	Timeout     int
+	// Scope restricts the hosts requests are allowed to reach, nil disables scope enforcement
+	Scope *Scope
+	// RateLimit limits the request rate, nil disables rate limiting
+	RateLimit *RateLimit
+	// Auth is the http authentication applied to the requests without their own auth block
+	Auth *AuthConfig
+	// Explain records the trace of the matchers and extractors of every response, see ScanContext.Traces
+	Explain bool
 }
@@ -13,6 +13,18 @@ type ExecuterOptions struct {
 	Variables    Variable
 	varsPayloads map[string]interface{}
 	Options      *Options
+
+	// BeforeRequest hooks run in order before every http request, an error vetoes the request
+	BeforeRequest []BeforeRequestHook
+	// AfterResponse hooks run in order after every http response
+	AfterResponse []AfterResponseHook
+	// BeforeNetworkRequest hooks run in order before every network write, an error vetoes the request
+	BeforeNetworkRequest []BeforeNetworkRequestHook
+	// AfterNetworkResponse hooks run in order after every network response
+	AfterNetworkResponse []AfterNetworkResponseHook
+
+	// Session shares a login session across the templates using these options, nil disables it
+	Session *SessionManager
 }
 
 // Executer is an interface implemented any protocol based request executer.
@@ -72,6 +84,8 @@ type ResultEvent struct {
 	Matched string `json:"matched,omitempty"`
 	// ExtractedResults contains the extraction result from the inputs.
 	ExtractedResults []string `json:"extracted_results,omitempty"`
+	// StructuredResults are the key/value pairs of the named regex groups, one map per match.
+	StructuredResults []map[string]string `json:"structured_results,omitempty"`
 	// Request is the optional dumped request for the match.
 	//Request string `json:"request,omitempty"`
 	// Response is the optional dumped response for the match.
@@ -0,0 +1,139 @@
+package protocols
+
+import (
+	"math"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit limits the request rate of every template sharing the options.
+//
+// Limits are applied uniformly to http, raw http and network requests, zero values disable a limit.
+type RateLimit struct {
+	// GlobalRPS is the maximum number of requests per second across all hosts
+	GlobalRPS float64 `json:"global-rps,omitempty" yaml:"global-rps,omitempty"`
+	// PerHostRPS is the maximum number of requests per second to a single host
+	PerHostRPS float64 `json:"per-host-rps,omitempty" yaml:"per-host-rps,omitempty"`
+	// PerHostMaxInFlight is the maximum number of concurrent requests to a single host
+	PerHostMaxInFlight int `json:"per-host-max-in-flight,omitempty" yaml:"per-host-max-in-flight,omitempty"`
+
+	once   sync.Once
+	global *tokenBucket
+	mu     sync.Mutex
+	hosts  map[string]*hostLimiter
+}
+
+type hostLimiter struct {
+	bucket   *tokenBucket
+	inflight chan struct{}
+}
+
+func (l *RateLimit) init() {
+	l.once.Do(func() {
+		if l.GlobalRPS > 0 {
+			l.global = newTokenBucket(l.GlobalRPS)
+		}
+		l.hosts = make(map[string]*hostLimiter)
+	})
+}
+
+func (l *RateLimit) host(host string) *hostLimiter {
+	l.init()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limiter, ok := l.hosts[host]
+	if !ok {
+		limiter = &hostLimiter{}
+		if l.PerHostRPS > 0 {
+			limiter.bucket = newTokenBucket(l.PerHostRPS)
+		}
+		if l.PerHostMaxInFlight > 0 {
+			limiter.inflight = make(chan struct{}, l.PerHostMaxInFlight)
+		}
+		l.hosts[host] = limiter
+	}
+	return limiter
+}
+
+// Acquire blocks until a request to host is allowed and returns the function releasing its in-flight slot.
+//
+// The slot is meant to be held until the response is read, host is the host the request is sent to.
+func (l *RateLimit) Acquire(host string) (release func()) {
+	if l == nil {
+		return func() {}
+	}
+	limiter := l.host(normalizeHost(host))
+	if limiter.inflight != nil {
+		limiter.inflight <- struct{}{}
+	}
+	l.wait(limiter)
+	var released sync.Once
+	return func() {
+		released.Do(func() {
+			if limiter.inflight != nil {
+				<-limiter.inflight
+			}
+		})
+	}
+}
+
+// Wait blocks until an additional request to host is allowed by the rps limits,
+// it is used for requests sent while a slot is already held (redirects, handshakes...).
+func (l *RateLimit) Wait(host string) {
+	if l == nil {
+		return
+	}
+	l.wait(l.host(normalizeHost(host)))
+}
+
+func (l *RateLimit) wait(limiter *hostLimiter) {
+	if limiter.bucket != nil {
+		limiter.bucket.wait()
+	}
+	if l.global != nil {
+		l.global.wait()
+	}
+}
+
+// normalizeHost returns the hostname of an url, a host:port address or a hostname
+func normalizeHost(host string) string {
+	if strings.Contains(host, "://") {
+		if parsed, err := url.Parse(host); err == nil {
+			host = parsed.Host
+		}
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// tokenBucket is a token bucket with a burst of one token, tokens are reserved
+// in advance so concurrent callers are served in order.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	return &tokenBucket{rate: rate, tokens: 1, last: time.Now()}
+}
+
+func (b *tokenBucket) wait() {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens = math.Min(1, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+	time.Sleep(delay)
+}
@@ -0,0 +1,69 @@
+package protocols
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimitPerHost(t *testing.T) {
+	limit := &RateLimit{PerHostRPS: 10}
+	start := time.Now()
+	// the first request of a host is free, the urls and addresses of a host share its limit
+	for _, host := range []string{"example.com", "http://EXAMPLE.com:8080/path", "example.com:443", "other.com:80"} {
+		limit.Acquire(host)()
+	}
+	elapsed := time.Since(start)
+	if elapsed < 180*time.Millisecond || elapsed > 400*time.Millisecond {
+		t.Errorf("expected 2 waits of 100ms on example.com, took %s", elapsed)
+	}
+
+	var empty *RateLimit
+	empty.Acquire("example.com")()
+	empty.Wait("example.com")
+}
+
+func TestRateLimitGlobal(t *testing.T) {
+	limit := &RateLimit{GlobalRPS: 20}
+	start := time.Now()
+	for _, host := range []string{"a.com", "b.com", "c.com"} {
+		limit.Wait(host)
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("expected the hosts to share the global limit, took %s", elapsed)
+	}
+}
+
+func TestRateLimitInFlight(t *testing.T) {
+	limit := &RateLimit{PerHostMaxInFlight: 1}
+	release := limit.Acquire("example.com:80")
+
+	// another host has its own slots
+	done := make(chan struct{})
+	go func() {
+		limit.Acquire("other.com")()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("other.com is blocked by the slot of example.com")
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		limit.Acquire("http://example.com/")()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("the second request acquired the single slot of example.com")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release()
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("the released slot was not acquired")
+	}
+}
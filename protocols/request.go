@@ -0,0 +1,58 @@
+package protocols
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// BaseRequest is a captured http request (HAR, Burp...) used as the base of the generated requests
+type BaseRequest struct {
+	Method string
+	// URL is the full url of the request, including the query
+	URL string
+	// Headers are the request headers, cookies included, Host and Content-Length are dropped
+	Headers map[string]string
+	Body    string
+}
+
+// NewBaseRequest creates a base request, headers that depend on the url or the body are dropped
+func NewBaseRequest(method, rawURL string, headers map[string]string, body string) *BaseRequest {
+	if method == "" {
+		method = http.MethodGet
+	}
+	req := &BaseRequest{Method: strings.ToUpper(method), URL: rawURL, Headers: make(map[string]string, len(headers)), Body: body}
+	for key, value := range headers {
+		switch http.CanonicalHeaderKey(key) {
+		case "", "Host", "Content-Length", "Connection":
+			continue
+		}
+		// http2 pseudo headers of HAR captures
+		if strings.HasPrefix(key, ":") {
+			continue
+		}
+		req.Headers[key] = value
+	}
+	return req
+}
+
+// Header returns the value of a header, the name is case insensitive
+func (b *BaseRequest) Header(name string) string {
+	for key, value := range b.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// BaseURL returns the url of the request without query and fragment
+func (b *BaseRequest) BaseURL() string {
+	parsed, err := url.Parse(b.URL)
+	if err != nil {
+		return b.URL
+	}
+	parsed.RawQuery = ""
+	parsed.Fragment = ""
+	return parsed.String()
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+
+	"github.com/chainreactors/neutron/operators"
 )
 
 type ScanContext struct {
@@ -12,6 +14,8 @@ type ScanContext struct {
 	// exported / configurable fields
 	Input    string
 	Payloads map[string]interface{}
+	// Request is the captured request the input comes from, nil for plain url inputs
+	Request *BaseRequest
 	// callbacks or hooks
 	OnError  func(error)
 	OnResult func(e *InternalWrappedEvent)
@@ -20,6 +24,7 @@ type ScanContext struct {
 	errors   []error
 	warnings []string
 	events   []*InternalWrappedEvent
+	traces   []*operators.Trace
 
 	// might not be required but better to sync
 	m sync.Mutex
@@ -30,6 +35,11 @@ func NewScanContext(input string, payloads map[string]interface{}) *ScanContext
 	return &ScanContext{Input: input, Payloads: payloads}
 }
 
+// NewScanContextWithRequest creates a new scan context using a captured request as input
+func NewScanContextWithRequest(request *BaseRequest, payloads map[string]interface{}) *ScanContext {
+	return &ScanContext{Input: request.URL, Payloads: payloads, Request: request}
+}
+
 // GenerateResult returns final results slice from all events
 func (s *ScanContext) GenerateResult() []*ResultEvent {
 	s.m.Lock()
@@ -88,6 +98,23 @@ func (s *ScanContext) LogWarning(format string, args ...interface{}) {
 	}
 }
 
+// LogTrace records the trace of the operators on a response, in explain mode
+func (s *ScanContext) LogTrace(trace *operators.Trace) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if trace == nil {
+		return
+	}
+	s.traces = append(s.traces, trace)
+}
+
+// Traces returns the traces of the responses in order, matched or not
+func (s *ScanContext) Traces() []*operators.Trace {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return append([]*operators.Trace(nil), s.traces...)
+}
+
 // aggregateResults aggregates results from multiple events
 func aggregateResults(events []*InternalWrappedEvent) []*ResultEvent {
 	var results []*ResultEvent
@@ -0,0 +1,265 @@
+package protocols
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrOutOfScope is the error wrapped by every scope violation
+var ErrOutOfScope = errors.New("out of scope")
+
+// Scope restricts the hosts and ports that generated requests are allowed to reach.
+//
+// Empty allow lists allow everything, DeniedHosts always takes precedence.
+type Scope struct {
+	// AllowedCIDRs are the ip ranges allowed, e.g. 10.0.0.0/8 or 192.168.1.1
+	AllowedCIDRs []string `json:"allowed-cidrs,omitempty" yaml:"allowed-cidrs,omitempty"`
+	// AllowedDomains are the domain globs allowed, e.g. *.example.com
+	AllowedDomains []string `json:"allowed-domains,omitempty" yaml:"allowed-domains,omitempty"`
+	// AllowedPorts are the ports or port ranges allowed, e.g. 443 or 8000-9000
+	AllowedPorts []string `json:"allowed-ports,omitempty" yaml:"allowed-ports,omitempty"`
+	// DeniedHosts are ips, cidrs or domain globs that must never be reached
+	DeniedHosts []string `json:"denied-hosts,omitempty" yaml:"denied-hosts,omitempty"`
+
+	once        sync.Once
+	compileErr  error
+	allowedNets []*net.IPNet
+	deniedNets  []*net.IPNet
+	deniedGlobs []string
+	ports       [][2]int
+	resolved    sync.Map
+}
+
+// ScopeError is returned when a request targets an out of scope host
+type ScopeError struct {
+	Host   string
+	Port   string
+	Reason string
+}
+
+func (e *ScopeError) Error() string {
+	target := e.Host
+	if e.Port != "" {
+		target = net.JoinHostPort(e.Host, e.Port)
+	}
+	return fmt.Sprintf("%s is out of scope: %s", target, e.Reason)
+}
+
+func (e *ScopeError) Unwrap() error {
+	return ErrOutOfScope
+}
+
+// Compile parses the scope rules, it is called automatically on the first check
+func (s *Scope) Compile() error {
+	s.once.Do(func() {
+		s.compileErr = s.compile()
+	})
+	return s.compileErr
+}
+
+func (s *Scope) compile() error {
+	for _, cidr := range s.AllowedCIDRs {
+		ipnet, err := parseCIDR(cidr)
+		if err != nil {
+			return err
+		}
+		s.allowedNets = append(s.allowedNets, ipnet)
+	}
+	for i, domain := range s.AllowedDomains {
+		s.AllowedDomains[i] = strings.ToLower(strings.TrimSpace(domain))
+	}
+	for _, host := range s.DeniedHosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if ipnet, err := parseCIDR(host); err == nil {
+			s.deniedNets = append(s.deniedNets, ipnet)
+		} else {
+			s.deniedGlobs = append(s.deniedGlobs, host)
+		}
+	}
+	for _, port := range s.AllowedPorts {
+		portRange, err := parsePortRange(port)
+		if err != nil {
+			return err
+		}
+		s.ports = append(s.ports, portRange)
+	}
+	return nil
+}
+
+// CheckURL checks that the host and port of an url are in scope
+func (s *Scope) CheckURL(u *url.URL) error {
+	if s == nil || u == nil {
+		return nil
+	}
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "http":
+			port = "80"
+		}
+	}
+	return s.Check(u.Hostname(), port)
+}
+
+// CheckAddress checks that a host:port address is in scope
+func (s *Scope) CheckAddress(address string) error {
+	if s == nil {
+		return nil
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return s.Check(address, "")
+	}
+	return s.Check(host, port)
+}
+
+// Check checks that a host and an optional port are in scope
+func (s *Scope) Check(host, port string) error {
+	if s == nil {
+		return nil
+	}
+	if err := s.Compile(); err != nil {
+		return err
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+
+	if port != "" && len(s.ports) > 0 && !s.portAllowed(port) {
+		return &ScopeError{Host: host, Port: port, Reason: "port not allowed"}
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		for _, glob := range s.deniedGlobs {
+			if matchDomain(glob, host) {
+				return &ScopeError{Host: host, Port: port, Reason: "host denied by " + glob}
+			}
+		}
+	}
+
+	var ips []net.IP
+	if ip != nil {
+		ips = []net.IP{ip}
+	} else if len(s.deniedNets) > 0 || (len(s.allowedNets) > 0 && !s.domainAllowed(host)) {
+		ips = s.resolve(host)
+	}
+
+	for _, addr := range ips {
+		for _, ipnet := range s.deniedNets {
+			if ipnet.Contains(addr) {
+				return &ScopeError{Host: host, Port: port, Reason: "ip denied by " + ipnet.String()}
+			}
+		}
+	}
+
+	if len(s.allowedNets) == 0 && len(s.AllowedDomains) == 0 {
+		return nil
+	}
+	if ip == nil && s.domainAllowed(host) {
+		return nil
+	}
+	if len(ips) == 0 {
+		return &ScopeError{Host: host, Port: port, Reason: "host not allowed"}
+	}
+	for _, addr := range ips {
+		if !s.ipAllowed(addr) {
+			return &ScopeError{Host: host, Port: port, Reason: addr.String() + " not in allowed cidrs"}
+		}
+	}
+	return nil
+}
+
+func (s *Scope) domainAllowed(host string) bool {
+	for _, glob := range s.AllowedDomains {
+		if matchDomain(glob, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scope) ipAllowed(ip net.IP) bool {
+	for _, ipnet := range s.allowedNets {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scope) portAllowed(port string) bool {
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return false
+	}
+	for _, portRange := range s.ports {
+		if p >= portRange[0] && p <= portRange[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve resolves and caches the ips of a domain, unresolvable domains return nil
+func (s *Scope) resolve(host string) []net.IP {
+	if cached, ok := s.resolved.Load(host); ok {
+		return cached.([]net.IP)
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		ips = nil
+	}
+	s.resolved.Store(host, ips)
+	return ips
+}
+
+func matchDomain(glob, host string) bool {
+	if glob == host {
+		return true
+	}
+	matched, err := path.Match(glob, host)
+	return err == nil && matched
+}
+
+func parseCIDR(cidr string) (*net.IPNet, error) {
+	cidr = strings.TrimSpace(cidr)
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid cidr: %s", cidr)
+		}
+		if ip.To4() != nil {
+			cidr += "/32"
+		} else {
+			cidr += "/128"
+		}
+	}
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cidr: %s", cidr)
+	}
+	return ipnet, nil
+}
+
+func parsePortRange(port string) ([2]int, error) {
+	parts := strings.SplitN(strings.TrimSpace(port), "-", 2)
+	start, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return [2]int{}, fmt.Errorf("invalid port: %s", port)
+	}
+	end := start
+	if len(parts) == 2 {
+		end, err = strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || end < start {
+			return [2]int{}, fmt.Errorf("invalid port range: %s", port)
+		}
+	}
+	return [2]int{start, end}, nil
+}
@@ -0,0 +1,67 @@
+package protocols
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func TestScope(t *testing.T) {
+	// domains are only resolved with cidr rules, the scopes are split to keep the test offline
+	ips := &Scope{
+		AllowedCIDRs: []string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"},
+		AllowedPorts: []string{"80", "443", "8000-8100"},
+		DeniedHosts:  []string{"10.0.0.1"},
+	}
+	domains := &Scope{
+		AllowedDomains: []string{"*.example.com", "example.org"},
+		DeniedHosts:    []string{"admin.example.com", "*.internal.example.com"},
+	}
+	tests := []struct {
+		scope   *Scope
+		target  string
+		allowed bool
+	}{
+		{ips, "http://10.1.2.3", true},
+		{ips, "https://192.168.1.1", true},
+		{ips, "http://192.168.1.2", false},
+		{ips, "http://[fd00::1]:8080", true},
+		{domains, "https://www.example.com/path", true},
+		{domains, "http://example.org:8100", true},
+		{domains, "http://sub.example.org", false},
+		{domains, "http://example.com", false},
+		// ports
+		{ips, "http://10.1.2.3:8101", false},
+		{ips, "http://10.1.2.3:22", false},
+		{ips, "10.1.2.3:8000", true},
+		{ips, "10.1.2.3:22", false},
+		// denied hosts take precedence over the allowed cidrs and domains
+		{ips, "http://10.0.0.1", false},
+		{ips, "10.0.0.1:80", false},
+		{domains, "https://admin.example.com", false},
+		{domains, "https://db.internal.example.com", false},
+		{domains, "https://ADMIN.example.com", false},
+	}
+	for _, test := range tests {
+		var err error
+		if u, parseErr := url.Parse(test.target); parseErr == nil && u.Scheme != "" && u.Host != "" {
+			err = test.scope.CheckURL(u)
+		} else {
+			err = test.scope.CheckAddress(test.target)
+		}
+		if (err == nil) != test.allowed {
+			t.Errorf("%s: expected allowed %v, got %v", test.target, test.allowed, err)
+		}
+		if err != nil && !errors.Is(err, ErrOutOfScope) {
+			t.Errorf("%s: expected an out of scope error, got %v", test.target, err)
+		}
+	}
+
+	var empty *Scope
+	if err := empty.Check("10.0.0.1", "22"); err != nil {
+		t.Errorf("a nil scope allows everything, got %v", err)
+	}
+	if err := (&Scope{AllowedPorts: []string{"http"}}).Check("10.0.0.1", "80"); err == nil || errors.Is(err, ErrOutOfScope) {
+		t.Errorf("expected an invalid port error, got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package protocols
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// defaultExpiredStatus are the status codes of an expired session when ExpiredStatus is empty
+var defaultExpiredStatus = []int{http.StatusUnauthorized}
+
+// Session is the login state of a host
+type Session struct {
+	// Cookies are sent with every request to the host, cookies set by the request itself take precedence
+	Cookies []*http.Cookie
+	// Headers are added to the requests that don't set them, e.g. Authorization
+	Headers map[string]string
+	// Values are the values extracted by the login
+	Values map[string]string
+
+	generation int
+}
+
+// SessionManager logs in once per host and shares the session with every template using the options.
+//
+// The session is refreshed once per request when the response matches the expiry condition,
+// a redirect to the login page or one of the ExpiredStatus codes (401 by default).
+type SessionManager struct {
+	// Login logs in on a target (scheme://host) and returns its session, e.g. templates.Template.SessionLogin
+	Login func(target string) (*Session, error)
+	// LoginPath marks the redirects to the login page, default is "login"
+	LoginPath string
+	// ExpiredStatus are the status codes of an expired session, default is 401
+	ExpiredStatus []int
+
+	mu       sync.Mutex
+	sessions map[string]*hostSession
+}
+
+// hostSession is the session of a host, its lock serializes the logins on the host only
+type hostSession struct {
+	mu      sync.Mutex
+	session *Session
+}
+
+// NewSessionManager creates a session manager using login
+func NewSessionManager(login func(target string) (*Session, error)) *SessionManager {
+	return &SessionManager{Login: login, sessions: make(map[string]*hostSession)}
+}
+
+// Get returns the session of the target host, logging in if needed
+func (m *SessionManager) Get(target *url.URL) (*Session, error) {
+	return m.get(target, nil)
+}
+
+// Refresh logs in again on the target host, unless the stale session was already refreshed
+func (m *SessionManager) Refresh(target *url.URL, stale *Session) (*Session, error) {
+	return m.get(target, stale)
+}
+
+func (m *SessionManager) host(target *url.URL) *hostSession {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sessions == nil {
+		m.sessions = make(map[string]*hostSession)
+	}
+	host := strings.ToLower(target.Host)
+	hs, ok := m.sessions[host]
+	if !ok {
+		hs = &hostSession{}
+		m.sessions[host] = hs
+	}
+	return hs
+}
+
+func (m *SessionManager) get(target *url.URL, stale *Session) (*Session, error) {
+	// concurrent requests to a host wait for a single login, other hosts aren't blocked
+	hs := m.host(target)
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	session := hs.session
+	if session != nil && (stale == nil || session.generation != stale.generation) {
+		return session, nil
+	}
+	fresh, err := m.Login(target.Scheme + "://" + target.Host)
+	if err != nil {
+		return nil, err
+	}
+	if session != nil {
+		fresh.generation = session.generation + 1
+	}
+	hs.session = fresh
+	return fresh, nil
+}
+
+// Apply adds the session cookies and headers to the request
+func (m *SessionManager) Apply(req *http.Request, session *Session) {
+	for key, value := range session.Headers {
+		if req.Header.Get(key) == "" {
+			req.Header.Set(key, value)
+		}
+	}
+	existing := make(map[string]struct{})
+	for _, cookie := range req.Cookies() {
+		existing[cookie.Name] = struct{}{}
+	}
+	for _, cookie := range session.Cookies {
+		if _, ok := existing[cookie.Name]; !ok {
+			req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+		}
+	}
+}
+
+// Expired returns true if the status code of the response is one of ExpiredStatus, or if the
+// response or any of the followed redirects is a redirect to the login page
+func (m *SessionManager) Expired(resp *http.Response) bool {
+	expiredStatus := m.ExpiredStatus
+	if len(expiredStatus) == 0 {
+		expiredStatus = defaultExpiredStatus
+	}
+	for _, status := range expiredStatus {
+		if resp.StatusCode == status {
+			return true
+		}
+	}
+	loginPath := m.LoginPath
+	if loginPath == "" {
+		loginPath = "login"
+	}
+	for hop := resp; hop != nil; {
+		if hop.StatusCode >= 300 && hop.StatusCode < 400 &&
+			strings.Contains(strings.ToLower(hop.Header.Get("Location")), strings.ToLower(loginPath)) {
+			return true
+		}
+		if hop.Request == nil {
+			break
+		}
+		hop = hop.Request.Response
+	}
+	return false
+}
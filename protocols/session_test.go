@@ -0,0 +1,88 @@
+package protocols
+
+import (
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSessionLoginPerHost(t *testing.T) {
+	blocked := make(chan struct{})
+	var logins int32
+	manager := NewSessionManager(func(target string) (*Session, error) {
+		atomic.AddInt32(&logins, 1)
+		if target == "http://slow" {
+			<-blocked
+		}
+		return &Session{Headers: map[string]string{"X-Target": target}}, nil
+	})
+	slow, _ := url.Parse("http://slow/a")
+	fast, _ := url.Parse("http://fast/a")
+
+	done := make(chan *Session)
+	go func() {
+		session, _ := manager.Get(slow)
+		done <- session
+	}()
+	for atomic.LoadInt32(&logins) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the pending login of slow doesn't block the other hosts
+	got := make(chan *Session)
+	go func() {
+		session, _ := manager.Get(fast)
+		got <- session
+	}()
+	select {
+	case session := <-got:
+		if session.Headers["X-Target"] != "http://fast" {
+			t.Errorf("unexpected session %v", session.Headers)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the login of a host is blocked by the login of another host")
+	}
+	close(blocked)
+	stale := <-done
+
+	// a refresh logs in once, the callers holding the stale session share the new one
+	first, _ := manager.Refresh(slow, stale)
+	second, _ := manager.Refresh(slow, stale)
+	if first != second || first == stale {
+		t.Error("expected a single refresh of the stale session")
+	}
+	if logins := atomic.LoadInt32(&logins); logins != 3 {
+		t.Errorf("expected 3 logins, got %d", logins)
+	}
+}
+
+func TestSessionExpired(t *testing.T) {
+	redirect := func(status int, location string) *http.Response {
+		resp := &http.Response{StatusCode: status, Header: http.Header{}}
+		resp.Header.Set("Location", location)
+		return resp
+	}
+	followed := &http.Response{StatusCode: 200, Request: &http.Request{Response: redirect(302, "/Login?next=/admin")}}
+
+	tests := []struct {
+		manager *SessionManager
+		resp    *http.Response
+		expired bool
+	}{
+		{&SessionManager{}, &http.Response{StatusCode: 401}, true},
+		{&SessionManager{}, &http.Response{StatusCode: 403}, false},
+		{&SessionManager{ExpiredStatus: []int{401}}, &http.Response{StatusCode: 401}, true},
+		{&SessionManager{ExpiredStatus: []int{401}}, &http.Response{StatusCode: 403}, false},
+		{&SessionManager{}, redirect(302, "/login"), true},
+		{&SessionManager{}, redirect(302, "/home"), false},
+		{&SessionManager{}, followed, true},
+		{&SessionManager{LoginPath: "/sso/"}, followed, false},
+	}
+	for i, test := range tests {
+		if expired := test.manager.Expired(test.resp); expired != test.expired {
+			t.Errorf("%d: expected expired %v, got %v", i, test.expired, expired)
+		}
+	}
+}
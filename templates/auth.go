@@ -0,0 +1,119 @@
+package templates
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/chainreactors/neutron/protocols"
+)
+
+// TokenProvider returns a bearer token provider for protocols.AuthConfig running this login template
+// on the target, the token is the first value of the named extractor. Tokens are cached per target.
+// The login template must not be compiled with the bearer auth it provides.
+func (t *Template) TokenProvider(extractor string) func(target string) (string, error) {
+	var mu sync.Mutex
+	tokens := make(map[string]string)
+	return func(target string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if token, ok := tokens[target]; ok {
+			return token, nil
+		}
+		result, err := t.Execute(target, nil)
+		if err != nil {
+			return "", err
+		}
+		if result == nil {
+			return "", fmt.Errorf("login template %s returned no result", t.Id)
+		}
+		values := result.DynamicValues[extractor]
+		if len(values) == 0 {
+			values = result.Extracts[extractor]
+		}
+		if len(values) == 0 {
+			return "", fmt.Errorf("login template %s did not extract %s", t.Id, extractor)
+		}
+		tokens[target] = values[0]
+		return values[0], nil
+	}
+}
+
+// SessionLogin returns a login function for protocols.SessionManager running this login template.
+// The session holds the cookies set by the login responses (redirects included) and the extracted
+// values, the value of tokenExtractor, if any, is sent as a bearer token.
+// The login template must be compiled with its own options, without the session manager.
+func (t *Template) SessionLogin(tokenExtractor string) func(target string) (*protocols.Session, error) {
+	var mu sync.Mutex
+	var collecting string
+	var cookies []*http.Cookie
+	t.Executor.Options().AddAfterResponse(func(resp *http.Response, event protocols.InternalEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		if collecting == "" || resp.Request == nil || !strings.EqualFold(resp.Request.URL.Host, collecting) {
+			return
+		}
+		for hop := resp; hop != nil; {
+			cookies = append(cookies, hop.Cookies()...)
+			if hop.Request == nil {
+				break
+			}
+			hop = hop.Request.Response
+		}
+	})
+
+	return func(target string) (*protocols.Session, error) {
+		parsed, err := url.Parse(target)
+		if err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		collecting, cookies = parsed.Host, nil
+		mu.Unlock()
+		result, err := t.Execute(target, nil)
+		mu.Lock()
+		collected := cookies
+		collecting, cookies = "", nil
+		mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+
+		session := &protocols.Session{Headers: make(map[string]string), Values: make(map[string]string)}
+		// later cookies override the earlier ones of the same name
+		seen := make(map[string]int)
+		for _, cookie := range collected {
+			if i, ok := seen[cookie.Name]; ok {
+				session.Cookies[i] = cookie
+				continue
+			}
+			seen[cookie.Name] = len(session.Cookies)
+			session.Cookies = append(session.Cookies, cookie)
+		}
+		if result != nil {
+			for name, values := range result.Extracts {
+				if len(values) > 0 {
+					session.Values[name] = values[0]
+				}
+			}
+			for name, values := range result.DynamicValues {
+				if len(values) > 0 {
+					session.Values[name] = values[0]
+				}
+			}
+		}
+		if tokenExtractor != "" {
+			token, ok := session.Values[tokenExtractor]
+			if !ok {
+				return nil, fmt.Errorf("login template %s did not extract %s", t.Id, tokenExtractor)
+			}
+			session.Headers["Authorization"] = "Bearer " + token
+		}
+		if len(session.Cookies) == 0 && len(session.Headers) == 0 {
+			return nil, fmt.Errorf("login template %s did not set any cookie or token", t.Id)
+		}
+		return session, nil
+	}
+}
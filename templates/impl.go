@@ -7,6 +7,7 @@ import (
 	"github.com/chainreactors/neutron/operators"
 	"github.com/chainreactors/neutron/protocols"
 	"github.com/chainreactors/neutron/protocols/executer"
+	"net/http/cookiejar"
 	"strings"
 )
 
@@ -29,21 +30,32 @@ func (t *Template) Compile(options *protocols.ExecuterOptions) error {
 		}
 	}
 
+	if err := t.validateDSL(); err != nil {
+		return err
+	}
+
 	if t.Variables.Len() > 0 {
 		options.Variables = t.Variables
 	}
 
 	if requestHTTP := t.GetRequests(); len(requestHTTP) > 0 {
+		// cookies are shared by all the requests of the template
+		jar, _ := cookiejar.New(nil)
 		for _, req := range requestHTTP {
+			if req.CookieReuse {
+				req.SetCookieJar(jar)
+			}
 			if req.Unsafe {
 				return fmt.Errorf("not impl unsafe request %s", req.Name)
 			}
+			req.TemplateID = t.Id
 			requests = append(requests, req)
 		}
 		t.Executor = executer.NewExecuter(requests, options)
 	}
 	if len(t.RequestsNetwork) > 0 {
 		for _, req := range t.RequestsNetwork {
+			req.TemplateID = t.Id
 			requests = append(requests, req)
 		}
 		t.Executor = executer.NewExecuter(requests, options)
@@ -62,9 +74,14 @@ func (t *Template) Compile(options *protocols.ExecuterOptions) error {
 }
 
 func (t *Template) Execute(input string, payload map[string]interface{}) (*operators.Result, error) {
+	return t.ExecuteContext(protocols.NewScanContext(input, payload))
+}
+
+// ExecuteContext executes the template on a scan context, e.g. one carrying a captured base request
+func (t *Template) ExecuteContext(input *protocols.ScanContext) (*operators.Result, error) {
 	if t.Executor.Options().Options.Opsec && t.Opsec {
 		common.Debug("(opsec!!!) skip template %s", t.Id)
 		return nil, protocols.OpsecError
 	}
-	return t.Executor.Execute(protocols.NewScanContext(input, payload))
+	return t.Executor.Execute(input)
 }
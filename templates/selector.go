@@ -0,0 +1,134 @@
+package templates
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/chainreactors/neutron/common"
+	"github.com/chainreactors/neutron/operators"
+)
+
+// Selection is a template selected for a target
+type Selection struct {
+	Template *Template
+	// Finger is the fingerprint that selected the template, empty for a generic template
+	Finger string
+}
+
+// SelectionResult is the result of a selected template
+type SelectionResult struct {
+	*Selection
+	Result *operators.Result
+	Err    error
+}
+
+// Selector selects the templates whose finger list intersects the fingerprints detected on a target.
+// Templates without fingers are generic, they run when no template is selected.
+type Selector struct {
+	// Generic runs the generic templates along with the selected templates too
+	Generic bool
+
+	templates []*Template
+	fingers   map[string][]*Template
+}
+
+// NewSelector indexes the compiled templates by their fingers
+func NewSelector(templates []*Template) *Selector {
+	s := &Selector{templates: templates, fingers: make(map[string][]*Template)}
+	for _, t := range templates {
+		for _, finger := range t.Fingers {
+			finger = normalizeFinger(finger)
+			if finger != "" {
+				s.fingers[finger] = append(s.fingers[finger], t)
+			}
+		}
+	}
+	return s
+}
+
+func normalizeFinger(finger string) string {
+	return strings.ToLower(strings.TrimSpace(finger))
+}
+
+func isGeneric(t *Template) bool {
+	for _, finger := range t.Fingers {
+		if normalizeFinger(finger) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Select returns the templates of the fingerprints, in the order of the templates. A template selected
+// by several fingerprints runs once, reported with the first of them. Fingerprints are case-insensitive.
+func (s *Selector) Select(fingers []string) []*Selection {
+	triggers := make(map[*Template]string)
+	for _, finger := range fingers {
+		for _, t := range s.fingers[normalizeFinger(finger)] {
+			if _, ok := triggers[t]; !ok {
+				triggers[t] = finger
+			}
+		}
+	}
+
+	var selections []*Selection
+	for _, t := range s.templates {
+		if finger, ok := triggers[t]; ok {
+			selections = append(selections, &Selection{Template: t, Finger: finger})
+		}
+	}
+	if len(selections) > 0 && !s.Generic {
+		return selections
+	}
+	for _, t := range s.templates {
+		if isGeneric(t) {
+			selections = append(selections, &Selection{Template: t})
+		}
+	}
+	return selections
+}
+
+// Execute runs the templates selected by the fingerprints on the input
+func (s *Selector) Execute(input string, payloads map[string]interface{}, fingers []string) []*SelectionResult {
+	var results []*SelectionResult
+	for _, selection := range s.Select(fingers) {
+		if selection.Finger != "" {
+			common.Debug("template %s selected by finger %s", selection.Template.Id, selection.Finger)
+		}
+		result, err := selection.Template.Execute(input, payloads)
+		results = append(results, &SelectionResult{Selection: selection, Result: result, Err: err})
+	}
+	return results
+}
+
+// DetectFingers runs fingerprint templates on the input and returns the fingerprints they detected.
+// A matched template reports the names of its matched matchers, or its id without named matchers.
+func DetectFingers(templates []*Template, input string, payloads map[string]interface{}) []string {
+	var fingers []string
+	seen := make(map[string]struct{})
+	add := func(finger string) {
+		if _, ok := seen[normalizeFinger(finger)]; !ok {
+			seen[normalizeFinger(finger)] = struct{}{}
+			fingers = append(fingers, finger)
+		}
+	}
+	for _, t := range templates {
+		result, err := t.Execute(input, payloads)
+		if err != nil || result == nil || !result.Matched {
+			continue
+		}
+		if len(result.Matches) == 0 {
+			add(t.Id)
+			continue
+		}
+		names := make([]string, 0, len(result.Matches))
+		for name := range result.Matches {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			add(name)
+		}
+	}
+	return fingers
+}
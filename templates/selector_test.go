@@ -0,0 +1,167 @@
+package templates
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/chainreactors/neutron/protocols"
+	"gopkg.in/yaml.v3"
+)
+
+func TestSelector(t *testing.T) {
+	tomcat := &Template{Id: "tomcat-manager", Fingers: []string{"Tomcat"}}
+	struts := &Template{Id: "struts-rce", Fingers: []string{"struts2", "tomcat"}}
+	generic := &Template{Id: "git-config"}
+	s := NewSelector([]*Template{tomcat, struts, generic})
+
+	selections := s.Select([]string{"nginx", "tomcat ", "struts2"})
+	if len(selections) != 2 || selections[0].Template != tomcat || selections[1].Template != struts {
+		t.Fatalf("unexpected selections %v", selections)
+	}
+	if selections[1].Finger != "tomcat " {
+		t.Errorf("expected struts-rce to be selected by the first finger, got %q", selections[1].Finger)
+	}
+
+	selections = s.Select([]string{"nginx"})
+	if len(selections) != 1 || selections[0].Template != generic || selections[0].Finger != "" {
+		t.Fatalf("expected the generic fallback, got %v", selections)
+	}
+
+	s.Generic = true
+	if selections = s.Select([]string{"struts2"}); len(selections) != 2 || selections[1].Template != generic {
+		t.Fatalf("expected the generic templates along with struts-rce, got %v", selections)
+	}
+}
+
+// compileTemplates compiles the yaml templates with the options
+func compileTemplates(t *testing.T, options *protocols.Options, sources ...string) []*Template {
+	var templates []*Template
+	for _, source := range sources {
+		template := &Template{}
+		if err := yaml.Unmarshal([]byte(source), template); err != nil {
+			t.Fatal(err)
+		}
+		if err := template.Compile(&protocols.ExecuterOptions{Options: options}); err != nil {
+			t.Fatalf("%s: %s", template.Id, err)
+		}
+		templates = append(templates, template)
+	}
+	return templates
+}
+
+func TestSelectorExecute(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.Write([]byte("tomcat manager"))
+	}))
+	defer server.Close()
+
+	templates := compileTemplates(t, &protocols.Options{Timeout: 5, Opsec: true}, `
+id: tomcat-manager
+finger: [Tomcat]
+http:
+  - method: GET
+    path: ["{{BaseURL}}/manager"]
+    matchers:
+      - type: word
+        words: [manager]`, `
+id: struts-rce
+finger: [struts2]
+opsec: true
+http:
+  - method: GET
+    path: ["{{BaseURL}}/struts"]
+    matchers:
+      - type: word
+        words: [rce]`, `
+id: git-config
+http:
+  - method: GET
+    path: ["{{BaseURL}}/.git/config"]
+    matchers:
+      - type: word
+        words: ["[core]"]`)
+
+	results := NewSelector(templates).Execute(server.URL, nil, []string{"TOMCAT", "struts2", "nginx"})
+	if len(results) != 2 || results[0].Template.Id != "tomcat-manager" || results[1].Template.Id != "struts-rce" {
+		t.Fatalf("unexpected results %v", results)
+	}
+	if results[0].Finger != "TOMCAT" || results[0].Err != nil || results[0].Result == nil || !results[0].Result.Matched {
+		t.Errorf("expected tomcat-manager to match, got %+v", results[0])
+	}
+	// the errors of the selected templates are reported, the other templates still run
+	if results[1].Finger != "struts2" || results[1].Err != protocols.OpsecError {
+		t.Errorf("expected the opsec error of struts-rce, got %+v", results[1])
+	}
+	if expected := []string{"/manager"}; !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected only the selected templates to send requests, got %v", paths)
+	}
+
+	// without a selected template the generic templates run
+	paths = nil
+	results = NewSelector(templates).Execute(server.URL, nil, []string{"nginx"})
+	if len(results) != 1 || results[0].Template.Id != "git-config" || results[0].Finger != "" || results[0].Err != nil {
+		t.Fatalf("expected the generic fallback, got %v", results)
+	}
+	if expected := []string{"/.git/config"}; !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected the generic template to send its request, got %v", paths)
+	}
+}
+
+func TestDetectFingers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Apache Tomcat, struts, Jetty"))
+	}))
+	defer server.Close()
+
+	templates := compileTemplates(t, &protocols.Options{Timeout: 5}, `
+id: java-fingers
+http:
+  - method: GET
+    path: ["{{BaseURL}}/"]
+    matchers:
+      - type: word
+        name: tomcat
+        words: [Apache Tomcat]
+      - type: word
+        name: struts2
+        words: [struts]
+      - type: word
+        name: weblogic
+        words: [WebLogic]`, `
+id: jetty
+http:
+  - method: GET
+    path: ["{{BaseURL}}/"]
+    matchers:
+      - type: word
+        words: [Jetty]`, `
+id: nginx
+http:
+  - method: GET
+    path: ["{{BaseURL}}/"]
+    matchers:
+      - type: word
+        words: [nginx]`, `
+id: Tomcat
+http:
+  - method: GET
+    path: ["{{BaseURL}}/"]
+    matchers:
+      - type: word
+        words: [Tomcat]`)
+
+	// the matched matchers names are sorted, a template without named matchers reports its id,
+	// and the fingers are unique regardless of their case
+	fingers := DetectFingers(templates, server.URL, nil)
+	if expected := []string{"struts2", "tomcat", "jetty"}; !reflect.DeepEqual(fingers, expected) {
+		t.Errorf("expected the fingers %v, got %v", expected, fingers)
+	}
+}
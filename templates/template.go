@@ -5,6 +5,7 @@ import (
 	"github.com/chainreactors/neutron/protocols/executer"
 	"github.com/chainreactors/neutron/protocols/http"
 	"github.com/chainreactors/neutron/protocols/network"
+	"gopkg.in/yaml.v3"
 )
 
 type Template struct {
@@ -34,6 +35,9 @@ type Template struct {
 	TotalRequests int `yaml:"-" json:"-"`
 	// Executor is the actual template executor for running template requests
 	Executor *executer.Executer `yaml:"-" json:"-"`
+
+	// node is the yaml source of the template, it locates the dsl issues
+	node *yaml.Node
 }
 
 func (t *Template) GetRequests() []*http.Request {
@@ -0,0 +1,331 @@
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/chainreactors/neutron/common"
+	"github.com/chainreactors/neutron/operators"
+	"github.com/chainreactors/neutron/protocols/http"
+	"gopkg.in/yaml.v3"
+)
+
+// builtinVariables are the variables every request gets from its input
+var builtinVariables = []string{
+	"BaseURL", "RootURL", "Hostname", "Host", "Port", "Path", "File", "Scheme",
+	"FQDN", "RDN", "DN", "TLD", "SD", "randstr", "randnum",
+	"fuzz_part", "fuzz_key", "fuzz_value",
+}
+
+// DSLIssue is a dsl problem of a template, warnings don't fail the compilation
+type DSLIssue struct {
+	// Line and Column are the position in the yaml source, 0 if unknown
+	Line   int
+	Column int
+	// Location is the path of the value in the template, e.g. http[0].path[1]
+	Location   string
+	Expression string
+	Err        error
+	Warning    bool
+}
+
+func (i *DSLIssue) Error() string {
+	var position string
+	switch {
+	case i.Line > 0 && i.Column > 0:
+		position = fmt.Sprintf("%d:%d: ", i.Line, i.Column)
+	case i.Line > 0:
+		position = fmt.Sprintf("%d: ", i.Line)
+	}
+	level := "error"
+	if i.Warning {
+		level = "warning"
+	}
+	return fmt.Sprintf("%s%s: %s %s in %q", position, i.Location, level, i.Err.Error(), i.Expression)
+}
+
+// UnmarshalYAML keeps the yaml node of the template, it gives the positions of the dsl issues
+func (t *Template) UnmarshalYAML(node *yaml.Node) error {
+	type plain Template
+	if err := node.Decode((*plain)(t)); err != nil {
+		return err
+	}
+	t.node = node
+	return nil
+}
+
+// ValidateDSL checks the functions and their arguments of every {{expression}} of the requests, and of
+// the matchers and extractors dsl. The {{expressions}} of the requests are reported as warnings, an
+// unknown function or variable there was always sent as is. Variables of the requests not produced by
+// the builtin variables, the payloads, the template variables or the extractors are warnings too.
+func (t *Template) ValidateDSL() []*DSLIssue {
+	v := &dslValidator{root: t.node, known: make(map[string]struct{})}
+	for _, name := range builtinVariables {
+		v.known[name] = struct{}{}
+	}
+	if t.Variables.Len() > 0 {
+		t.Variables.ForEach(func(key string, data interface{}) {
+			v.known[key] = struct{}{}
+		})
+	}
+
+	key := "http"
+	if len(t.RequestsHTTP) == 0 {
+		key = "requests"
+	}
+	requests := t.GetRequests()
+	for _, req := range requests {
+		v.addKnown(req.Payloads, &req.Operators)
+	}
+	for _, req := range t.RequestsNetwork {
+		v.addKnown(req.Payloads, &req.Operators)
+	}
+
+	if t.Variables.Len() > 0 {
+		t.Variables.ForEach(func(name string, data interface{}) {
+			v.checkPlaceholders(path{"variables", name}, common.ToString(data), true)
+		})
+	}
+	for i, req := range requests {
+		v.checkHTTP(path{key, i}, req)
+	}
+	for i, req := range t.RequestsNetwork {
+		base := path{"network", i}
+		for j, address := range req.Address {
+			v.checkPlaceholders(base.add("host", j), address, true)
+		}
+		for j, input := range req.Inputs {
+			v.checkPlaceholders(base.add("inputs", j, "data"), input.Data, true)
+		}
+		v.checkOperators(base, &req.Operators)
+	}
+	return v.issues
+}
+
+// validateDSL returns the dsl errors of the template, warnings are ignored
+func (t *Template) validateDSL() error {
+	var errs []string
+	for _, issue := range t.ValidateDSL() {
+		if !issue.Warning {
+			errs = append(errs, issue.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid dsl in template %s:\n%s", t.Id, strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// path is the location of a value in the template, made of mapping keys and sequence indexes
+type path []interface{}
+
+func (p path) add(elements ...interface{}) path {
+	next := make(path, len(p), len(p)+len(elements))
+	copy(next, p)
+	return append(next, elements...)
+}
+
+func (p path) String() string {
+	builder := &strings.Builder{}
+	for _, element := range p {
+		switch e := element.(type) {
+		case int:
+			builder.WriteString("[" + strconv.Itoa(e) + "]")
+		default:
+			if builder.Len() > 0 {
+				builder.WriteString(".")
+			}
+			builder.WriteString(fmt.Sprint(e))
+		}
+	}
+	return builder.String()
+}
+
+type dslValidator struct {
+	root   *yaml.Node
+	known  map[string]struct{}
+	issues []*DSLIssue
+}
+
+// addKnown adds the payloads and the variables produced by the extractors
+func (v *dslValidator) addKnown(payloads map[string]interface{}, ops *operators.Operators) {
+	for name := range payloads {
+		v.known[name] = struct{}{}
+	}
+	for _, extractor := range ops.Extractors {
+		if extractor.Name != "" {
+			v.known[extractor.Name] = struct{}{}
+		}
+		for _, regex := range extractor.Regex {
+			compiled, err := regexp.Compile(regex)
+			if err != nil {
+				continue
+			}
+			for _, name := range compiled.SubexpNames() {
+				if name != "" {
+					v.known[name] = struct{}{}
+				}
+			}
+		}
+	}
+}
+
+func (v *dslValidator) checkHTTP(base path, req *http.Request) {
+	for i, p := range req.Path {
+		v.checkPlaceholders(base.add("path", i), p, true)
+	}
+	for i, raw := range req.Raw {
+		v.checkPlaceholders(base.add("raw", i), raw, true)
+	}
+	for name, value := range req.Headers {
+		v.checkPlaceholders(base.add("headers", name), value, true)
+	}
+	v.checkPlaceholders(base.add("body"), req.Body, true)
+	for name, value := range req.BodyForm {
+		v.checkPlaceholders(base.add("body-form", name), name, true)
+		v.checkPlaceholders(base.add("body-form", name), value, true)
+	}
+	if req.BodyJSON != nil {
+		v.checkPlaceholders(base.add("body-json"), fmt.Sprint(req.BodyJSON), true)
+	}
+	for i, field := range req.BodyMultipart {
+		v.checkPlaceholders(base.add("body-multipart", i, "name"), field.Name, true)
+		v.checkPlaceholders(base.add("body-multipart", i, "filename"), field.Filename, true)
+		v.checkPlaceholders(base.add("body-multipart", i, "content"), field.Content, true)
+	}
+	for i, rule := range req.Fuzzing {
+		for j, payload := range rule.Fuzz {
+			v.checkPlaceholders(base.add("fuzzing", i, "fuzz", j), payload, true)
+		}
+	}
+	v.checkOperators(base, &req.Operators)
+}
+
+func (v *dslValidator) checkOperators(base path, ops *operators.Operators) {
+	for i, matcher := range ops.Matchers {
+		for j, expression := range matcher.DSL {
+			v.checkExpression(base.add("matchers", i, "dsl", j), expression)
+		}
+		for j, word := range matcher.Words {
+			v.checkPlaceholders(base.add("matchers", i, "words", j), word, false)
+		}
+	}
+	for i, extractor := range ops.Extractors {
+		for j, expression := range extractor.DSL {
+			v.checkExpression(base.add("extractors", i, "dsl", j), expression)
+		}
+		for j, transform := range extractor.Transform {
+			// a bare function name is called with the value
+			expression, err := operators.TransformExpression(transform)
+			if err != nil {
+				v.report(base.add("extractors", i, "transform", j), transform, 0, err, false)
+				continue
+			}
+			v.checkExpression(base.add("extractors", i, "transform", j), expression)
+		}
+		if extractor.Filter != "" {
+			v.checkExpression(base.add("extractors", i, "filter"), extractor.Filter)
+		}
+	}
+}
+
+// checkExpression checks a dsl expression of the operators, it must be valid
+func (v *dslValidator) checkExpression(p path, expression string) {
+	_, isDSL, err := common.InspectExpression(common.ResolvePlaceholders(expression))
+	if !isDSL {
+		err = fmt.Errorf("invalid dsl expression")
+	}
+	if err != nil {
+		v.report(p, expression, 0, err, false)
+	}
+}
+
+// checkPlaceholders checks the {{expressions}} of a value, the placeholders that aren't dsl are sent as is.
+// Its issues are warnings, templates with unresolved placeholders compiled before the check existed.
+func (v *dslValidator) checkPlaceholders(p path, value string, checkVariables bool) {
+	for offset := 0; ; {
+		start := strings.Index(value[offset:], common.ParenthesisOpen)
+		if start < 0 {
+			return
+		}
+		start += offset
+		end := strings.Index(value[start+len(common.ParenthesisOpen):], common.ParenthesisClose)
+		if end < 0 {
+			return
+		}
+		end += start + len(common.ParenthesisOpen)
+		inner := strings.TrimSpace(value[start+len(common.ParenthesisOpen) : end])
+		offset = end + len(common.ParenthesisClose)
+
+		variables, isDSL, err := common.InspectExpression(inner)
+		if err != nil {
+			v.report(p, value[start:offset], start, err, true)
+			continue
+		}
+		if !isDSL || !checkVariables {
+			continue
+		}
+		for _, variable := range variables {
+			if _, ok := v.known[variable]; !ok {
+				v.report(p, value[start:offset], start, fmt.Errorf("unknown variable %s", variable), true)
+			}
+		}
+	}
+}
+
+func (v *dslValidator) report(p path, expression string, offset int, err error, warning bool) {
+	issue := &DSLIssue{Location: p.String(), Expression: expression, Err: err, Warning: warning}
+	if node := locate(v.root, p); node != nil {
+		issue.Line, issue.Column = node.Line, node.Column
+		if node.Kind == yaml.ScalarNode && node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+			// block scalars start on the line after the indicator
+			issue.Line += 1 + strings.Count(node.Value[:minInt(offset, len(node.Value))], "\n")
+			issue.Column = 0
+		}
+	}
+	v.issues = append(v.issues, issue)
+}
+
+// locate returns the yaml node of a path, or nil
+func locate(node *yaml.Node, p path) *yaml.Node {
+	if node == nil {
+		return nil
+	}
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for _, element := range p {
+		switch e := element.(type) {
+		case int:
+			if node.Kind != yaml.SequenceNode || e >= len(node.Content) {
+				return nil
+			}
+			node = node.Content[e]
+		case string:
+			if node.Kind != yaml.MappingNode {
+				return nil
+			}
+			var found *yaml.Node
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == e {
+					found = node.Content[i+1]
+					break
+				}
+			}
+			if found == nil {
+				return nil
+			}
+			node = found
+		}
+	}
+	return node
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
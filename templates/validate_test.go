@@ -0,0 +1,102 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestValidateDSL(t *testing.T) {
+	tests := []struct {
+		name    string
+		request string
+		// issues are parts of the expected issues, in order
+		issues  []string
+		compile bool
+	}{
+		{
+			name: "unknown function in body",
+			request: `
+    method: POST
+    path: ["{{BaseURL}}/"]
+    body: "name={{dump(app)}}"`,
+			issues:  []string{"warning unknown function dump"},
+			compile: true,
+		},
+		{
+			name: "unknown variable in path",
+			request: `
+    method: GET
+    path: ["{{BaseURL}}/{{unknown}}"]`,
+			issues:  []string{"warning unknown variable unknown"},
+			compile: true,
+		},
+		{
+			name: "known variables",
+			request: `
+    method: POST
+    path: ["{{BaseURL}}/{{user}}"]
+    payloads:
+      user: [admin]
+    body-form:
+      "{{user}}": "{{md5(user)}}"`,
+			compile: true,
+		},
+		{
+			name: "body-form names and values are checked apart",
+			request: `
+    method: POST
+    path: ["{{BaseURL}}/"]
+    body-form:
+      "{{": "}}"`,
+			compile: true,
+		},
+		{
+			name: "invalid matcher dsl",
+			request: `
+    method: GET
+    path: ["{{BaseURL}}/"]
+    matchers:
+      - type: dsl
+        dsl: ["status_code == "]`,
+			issues:  []string{"error invalid dsl expression"},
+			compile: false,
+		},
+		{
+			name: "bare transforms are called with the value",
+			request: `
+    method: GET
+    path: ["{{BaseURL}}/"]
+    extractors:
+      - type: kval
+        kval: [server]
+        transform: [to_upper, trim]`,
+			issues:  []string{"error invalid transform function: trim expects 2 arguments"},
+			compile: false,
+		},
+	}
+	for _, test := range tests {
+		source := "id: test\ninfo:\n  name: test\nhttp:\n  -" + test.request + "\n"
+		template := &Template{}
+		if err := yaml.Unmarshal([]byte(source), template); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		issues := template.ValidateDSL()
+		if len(issues) != len(test.issues) {
+			t.Errorf("%s: expected %d issues, got %v", test.name, len(test.issues), issues)
+			continue
+		}
+		for i, issue := range issues {
+			if !strings.Contains(issue.Error(), test.issues[i]) {
+				t.Errorf("%s: expected %q in %q", test.name, test.issues[i], issue.Error())
+			}
+			if issue.Line == 0 {
+				t.Errorf("%s: expected the line of %q", test.name, issue.Error())
+			}
+		}
+		if err := template.Compile(nil); (err == nil) != test.compile {
+			t.Errorf("%s: expected compile %v, got %v", test.name, test.compile, err)
+		}
+	}
+}